@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/cache"
+	"github.com/lsierant/notes-gen/pkg/utils"
+	"log"
+	"time"
+)
+
+const megabyte = 1024 * 1024
+
+func main() {
+	cacheDir := flag.String("cacheDir", utils.DefaultCacheDir, "directory of the render cache")
+	maxSize := flag.Int64("maxSize", 0, "prune: evict least recently used images until the cache fits in this many MB, 0 means no limit")
+	maxAge := flag.Duration("maxAge", 0, `prune: evict images not used for longer than this, e.g. "720h", 0 means no limit`)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] stats|list|prune|clear\n", flag.CommandLine.Name())
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		log.Fatal("expected exactly one command")
+	}
+
+	renderCache, err := cache.Open(*cacheDir, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch flag.Arg(0) {
+	case "stats":
+		entries, err := renderCache.Entries()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var total int64
+		for _, entry := range entries {
+			total += entry.Size
+		}
+		fmt.Printf("%s: %d images, %s\n", *cacheDir, len(entries), formatSize(total))
+		if len(entries) > 0 {
+			fmt.Printf("least recently used: %s\n", entries[0].LastUsed.Format(time.RFC3339))
+			fmt.Printf("most recently used: %s\n", entries[len(entries)-1].LastUsed.Format(time.RFC3339))
+		}
+	case "list":
+		entries, err := renderCache.Entries()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, entry := range entries {
+			fmt.Printf("%s\t%s\t%s\n", entry.Key, formatSize(entry.Size), entry.LastUsed.Format(time.RFC3339))
+		}
+	case "prune":
+		if *maxSize <= 0 && *maxAge <= 0 {
+			log.Fatal("prune requires -maxSize or -maxAge")
+		}
+
+		removed, freed, err := renderCache.Prune(*maxSize*megabyte, *maxAge)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("removed %d images, freed %s\n", removed, formatSize(freed))
+	case "clear":
+		removed, freed, err := renderCache.Clear()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("removed %d images, freed %s\n", removed, formatSize(freed))
+	default:
+		flag.Usage()
+		log.Fatalf("unknown command: %s", flag.Arg(0))
+	}
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= megabyte:
+		return fmt.Sprintf("%.1f MB", float64(size)/megabyte)
+	case size >= 1024:
+		return fmt.Sprintf("%.1f kB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
@@ -5,7 +5,11 @@ import (
 	"crypto/md5"
 	"flag"
 	"fmt"
+	"github.com/lsierant/notes-gen/pkg/abc"
+	"github.com/lsierant/notes-gen/pkg/anki"
 	"github.com/lsierant/notes-gen/pkg/lilypond"
+	"github.com/lsierant/notes-gen/pkg/midi"
+	"github.com/lsierant/notes-gen/pkg/musicxml"
 	"github.com/lsierant/notes-gen/pkg/notes"
 	"github.com/lsierant/notes-gen/pkg/utils"
 	"io/ioutil"
@@ -31,12 +35,26 @@ func main() {
 	imageDir := flag.String("imageDir", "images", "destination directory for storing generated images")
 	deckFilePath := flag.String("deckFilePath", "deck.csv", "path to generated deck file")
 	htmlFilePath := flag.String("htmlFilePath", "", "path to generated html file with all images")
+	apkgPath := flag.String("apkgPath", "", "path to a generated .apkg package with the cards, images and sounds, ready to import into Anki")
+	deckName := flag.String("deckName", "notes-gen::chords", "name of the deck in the .apkg package")
 	parallel := flag.Int("parallel", runtime.NumCPU(), "level of parallelism, defaults to number of CPUs")
 	scaleFlag := flag.String("scale", "c major", `scale to use, e.g. "c flat major", "d minor", "c sharp minor", default: "c major"`)
 	accidentals := flag.Int("accidentals", 7, "filter scales up to given number of accidentals")
 	triads := flag.Bool("triads", false, "generate triads")
 	sevenths := flag.Bool("sevenths", false, "generate sevenths")
 	onePager := flag.Bool("onePager", false, "generate one pager instead of deck")
+	deckFlags := utils.RegisterDeckFlags(flag.CommandLine)
+	soundFlags := utils.RegisterSoundFlags(flag.CommandLine)
+	earFlags := utils.RegisterEarTrainingFlags(flag.CommandLine)
+	musicXMLFlag := flag.String("musicxml", "none", `write .musicxml files: "none", "also" to write them next to images, "only" to skip rendering images`)
+	abcFlag := flag.Bool("abc", false, "also write .abc files next to images")
+	annotationsFlag := flag.String("annotations", "", `comma separated labels printed with the chords: "roman" numerals and "figures" under the staves, chord "names" above them`)
+	highlightFlag := flag.String("highlight", "", `comma separated chord notes drawn highlighted: "root", "third", "fifth", "seventh", "leadingTone"`)
+	highlightColor := flag.String("highlightColor", lilypond.DefaultHighlightStyle.Color, "hex color of highlighted notes")
+	highlightHead := flag.String("highlightHead", "", `notehead of highlighted notes: "cross", "diamond", "triangle", "slash", "harmonic", empty keeps the default`)
+	highlightParenthesized := flag.Bool("highlightParenthesized", false, "put highlighted notes in parentheses")
+	rendererFlags := utils.RegisterRendererFlags(flag.CommandLine)
+	templateFlags := utils.RegisterTemplateFlags(flag.CommandLine)
 
 	flag.Parse()
 
@@ -45,21 +63,84 @@ func main() {
 		log.Fatal(err)
 	}
 
-	renderer := lilypond.Renderer{WorkingDir: *tmpDir}
+	renderer, err := rendererFlags.Renderer(*tmpDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	debugDir := rendererFlags.DebugDir()
+
+	renderOpts, err := rendererFlags.RenderOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	templates, err := templateFlags.Templates()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	deckFile, err := deckFlags.DeckFile()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	directions, err := deckFlags.Directions(anki.DirectionSize)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	musicXMLOutput, err := utils.ParseMusicXMLOutput(*musicXMLFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *apkgPath != "" && musicXMLOutput == utils.MusicXMLOutputOnly {
+		log.Fatal("the .apkg package needs rendered images, -apkgPath can't be used with -musicxml only")
+	}
+
+	annotations, err := lilypond.ParseChordAnnotations(*annotationsFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	annotations.Highlights, err = lilypond.ParseHighlights(*highlightFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	annotations.HighlightStyle = lilypond.NoteStyle{Color: *highlightColor, Head: lilypond.NoteHead(*highlightHead), Parenthesized: *highlightParenthesized}
+	if err := annotations.HighlightStyle.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	ear, err := earFlags.Options(soundFlags)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sound, err := soundFlags.Outputs()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	if *onePager {
 		if *triads {
-			renderAllDiatonicTriadsOnOnePage(ctx, renderer, *imageDir, scales)
+			renderAllDiatonicTriadsOnOnePage(ctx, renderer, renderOpts, templates, debugDir, annotations, *imageDir, scales, sound, ear, musicXMLOutput, *abcFlag)
 		} else if *sevenths {
-			renderAllDiatonicSeventhsWithoutFifthOnOnePage(ctx, renderer, *imageDir, scales)
+			renderAllDiatonicSeventhsWithoutFifthOnOnePage(ctx, renderer, renderOpts, templates, debugDir, annotations, *imageDir, scales, sound, ear, musicXMLOutput, *abcFlag)
 		}
 	} else {
 		if *triads {
 			triads := generateAllTriadsInScales(scales)
+			if ear != nil {
+				var unique []notes.Chord
+				for _, idx := range ear.Deduplicate(len(triads), func(idx int) string { return chordVoicingKey(triads[idx]) }) {
+					unique = append(unique, triads[idx])
+				}
+				triads = unique
+			}
 
 			if deckFilePath != nil && *deckFilePath != "" {
-				deckFileContent := prepareDeck(triads)
-				err = ioutil.WriteFile(*deckFilePath, []byte(deckFileContent), 0660)
+				err = deckFile.WriteFile(*deckFilePath, prepareDeck(triads, *imageDir, sound, ear != nil, directions))
 				if err != nil {
 					log.Fatalf("errors while rendering file:\n%v", err)
 				}
@@ -73,28 +154,45 @@ func main() {
 					log.Fatalf("errors while rendering html file:\n%v", err)
 				}
 			}
-			renderAllDiatonicTriadsAsSeparateImages(ctx, renderer, *imageDir, *parallel, triads)
+			renderAllDiatonicTriadsAsSeparateImages(ctx, renderer, renderOpts, templates, debugDir, annotations, *imageDir, *parallel, rendererFlags.BatchSize(), triads, sound, ear, musicXMLOutput, *abcFlag)
+
+			if *apkgPath != "" {
+				if err := writePackage(*apkgPath, *deckName, *imageDir, triads, directions); err != nil {
+					log.Fatal(err)
+				}
+				log.Printf("Written package: %s\n", *apkgPath)
+			}
 		}
 	}
 }
 
-func renderAllDiatonicTriadsOnOnePage(ctx context.Context, renderer lilypond.Renderer, destDir string, scales []notes.Scale) {
+func renderAllDiatonicTriadsOnOnePage(ctx context.Context, renderer lilypond.Renderer, renderOpts lilypond.RenderOptions, templates *lilypond.Templates, debugDir *lilypond.DebugDir, annotations lilypond.ChordAnnotations, destDir string, scales []notes.Scale, sound utils.SoundOutputs, ear *utils.EarTraining, musicXMLOutput utils.MusicXMLOutput, abcOutput bool) {
 	for s := 0; s < len(scales); s++ {
 		chords := notes.GenerateAllDiatonicTriadsInScale(scales[s])
 
 		chordFilePath := fmt.Sprintf("%s/ng-chord-all-%s.png", destDir, scales[s].Name)
 		fmt.Println(chordFilePath)
-		if _, err := os.Stat(chordFilePath); err == nil {
-			fmt.Printf("Skipping rendering: %s\n", chordFilePath)
+		if err := writeChordsSoundFiles(chords, chordFilePath, sound, ear); err != nil {
+			panic(err)
+		}
+
+		if err := writeChordsMusicXMLFile(chords, chordFilePath, musicXMLOutput); err != nil {
+			panic(err)
+		}
+
+		if err := writeChordsABCFile(chords, chordFilePath, abcOutput); err != nil {
+			panic(err)
+		}
+		if musicXMLOutput == utils.MusicXMLOutputOnly {
 			continue
 		}
 
 		multipleChords := lilypond.MultipleChords{
-			Scale:  scales[s].LilypondSymbol,
-			Chords: convertToLilypondChords(chords),
+			Key:    lilypond.ScaleKey(scales[s]),
+			Chords: convertToLilypondChords(chords, annotations),
 		}
 
-		err := renderChordAndWriteFile(ctx, renderer, multipleChords, chordFilePath)
+		err := renderChordAndWriteFile(ctx, renderer, renderOpts, templates, debugDir, multipleChords, chordFilePath)
 		if err != nil {
 			panic(err)
 		}
@@ -103,23 +201,33 @@ func renderAllDiatonicTriadsOnOnePage(ctx context.Context, renderer lilypond.Ren
 	fmt.Println("Done...")
 }
 
-func renderAllDiatonicSeventhsWithoutFifthOnOnePage(ctx context.Context, renderer lilypond.Renderer, destDir string, scales []notes.Scale) {
+func renderAllDiatonicSeventhsWithoutFifthOnOnePage(ctx context.Context, renderer lilypond.Renderer, renderOpts lilypond.RenderOptions, templates *lilypond.Templates, debugDir *lilypond.DebugDir, annotations lilypond.ChordAnnotations, destDir string, scales []notes.Scale, sound utils.SoundOutputs, ear *utils.EarTraining, musicXMLOutput utils.MusicXMLOutput, abcOutput bool) {
 	for s := 0; s < len(scales); s++ {
 		chords := notes.GenerateAllDiatonicSeventhsInScaleWithoutFifths(scales[s])
 
 		chordFilePath := fmt.Sprintf("%s/ng-chord-all-7w5-%s.png", destDir, scales[s].Name)
 		fmt.Println(chordFilePath)
-		if _, err := os.Stat(chordFilePath); err == nil {
-			fmt.Printf("Skipping rendering: %s\n", chordFilePath)
+		if err := writeChordsSoundFiles(chords, chordFilePath, sound, ear); err != nil {
+			panic(err)
+		}
+
+		if err := writeChordsMusicXMLFile(chords, chordFilePath, musicXMLOutput); err != nil {
+			panic(err)
+		}
+
+		if err := writeChordsABCFile(chords, chordFilePath, abcOutput); err != nil {
+			panic(err)
+		}
+		if musicXMLOutput == utils.MusicXMLOutputOnly {
 			continue
 		}
 
 		multipleChords := lilypond.MultipleChords{
-			Scale:  scales[s].LilypondSymbol,
-			Chords: convertToLilypondChords(chords),
+			Key:    lilypond.ScaleKey(scales[s]),
+			Chords: convertToLilypondChords(chords, annotations),
 		}
 
-		err := renderChordAndWriteFile(ctx, renderer, multipleChords, chordFilePath)
+		err := renderChordAndWriteFile(ctx, renderer, renderOpts, templates, debugDir, multipleChords, chordFilePath)
 		if err != nil {
 			panic(err)
 		}
@@ -137,30 +245,50 @@ func generateAllTriadsInScales(scales []notes.Scale) []notes.Chord {
 	return chords
 }
 
-func renderAllDiatonicTriadsAsSeparateImages(ctx context.Context, renderer lilypond.Renderer, destDir string, parallel int, chords []notes.Chord) {
-	err := utils.RunInParallel(ctx, len(chords), parallel, func(idx int) error {
-		chord := convertToLilypondChord(chords[idx])
-		multipleChords := lilypond.MultipleChords{
-			Scale:  chords[idx].Scale.LilypondSymbol,
-			Chords: []lilypond.SingleChord{chord},
-		}
+func renderAllDiatonicTriadsAsSeparateImages(ctx context.Context, renderer lilypond.Renderer, renderOpts lilypond.RenderOptions, templates *lilypond.Templates, debugDir *lilypond.DebugDir, annotations lilypond.ChordAnnotations, destDir string, parallel int, batchSize int, chords []notes.Chord, sound utils.SoundOutputs, ear *utils.EarTraining, musicXMLOutput utils.MusicXMLOutput, abcOutput bool) {
+	err := utils.RunInBatches(ctx, len(chords), batchSize, parallel, func(indices []int) []error {
+		errs := make([]error, len(indices))
+		var toRender []int
+		var multipleChords []lilypond.MultipleChords
+		var paths []string
+		for i, idx := range indices {
+			chordFilePath := chordFilePath(destDir, chords[idx])
+			fmt.Println(chordFilePath)
+
+			if err := writeChordsSoundFiles(chords[idx:idx+1], chordFilePath, sound, ear); err != nil {
+				errs[i] = err
+				continue
+			}
 
-		chordFilePath := chordFilePath(destDir, chords[idx])
-		fmt.Println(chordFilePath)
+			if err := writeChordsMusicXMLFile(chords[idx:idx+1], chordFilePath, musicXMLOutput); err != nil {
+				errs[i] = err
+				continue
+			}
 
-		if _, err := os.Stat(chordFilePath); err == nil {
-			fmt.Printf("Skipping rendering: %s\n", chordFilePath)
-			return nil
-		}
+			if err := writeChordsABCFile(chords[idx:idx+1], chordFilePath, abcOutput); err != nil {
+				errs[i] = err
+				continue
+			}
+			if musicXMLOutput == utils.MusicXMLOutputOnly {
+				continue
+			}
 
-		err := renderChordAndWriteFile(ctx, renderer, multipleChords, chordFilePath)
-		if err != nil {
-			panic(err)
+			toRender = append(toRender, i)
+			paths = append(paths, chordFilePath)
+			multipleChords = append(multipleChords, lilypond.MultipleChords{
+				Key:    lilypond.ScaleKey(chords[idx].Scale),
+				Chords: []lilypond.SingleChord{lilypond.NewAnnotatedSingleChord(chords[idx], annotations)},
+			})
 		}
 
-		fmt.Printf("[%d]%v, ", idx, chords[idx])
+		for j, err := range renderChordsAndWriteFiles(ctx, renderer, renderOpts, templates, debugDir, multipleChords, paths) {
+			errs[toRender[j]] = err
+			if err == nil {
+				fmt.Printf("[%d]%v, ", indices[toRender[j]], chords[indices[toRender[j]]])
+			}
+		}
 
-		return nil
+		return errs
 	})
 
 	if err != nil {
@@ -171,68 +299,119 @@ func renderAllDiatonicTriadsAsSeparateImages(ctx context.Context, renderer lilyp
 	fmt.Println("Done...")
 }
 
-func chordFilePath(imageDir string, chord notes.Chord) string {
-	return fmt.Sprintf("%s/%s", imageDir, fmt.Sprintf("%s.png", chordFileName(chord)))
-}
+func writeChordsSoundFiles(chords []notes.Chord, chordFilePath string, sound utils.SoundOutputs, ear *utils.EarTraining) error {
+	if len(chords) == 0 {
+		return nil
+	}
 
-func convertToLilypondChords(chord []notes.Chord) []lilypond.SingleChord {
-	var result []lilypond.SingleChord
-	for i := 0; i < len(chord); i++ {
-		result = append(result, convertToLilypondChord(chord[i]))
+	var sonorities []midi.Sonority
+	for i := 0; i < len(chords); i++ {
+		sonority := midi.ChordSonority(chords[i])
+		if ear != nil {
+			sonority = ear.Transpose(chordFilePath, sonority)
+		}
+		sonorities = append(sonorities, sonority)
 	}
 
-	return result
+	return sound.Write(chordFilePath, sonorities, chords[0].Scale)
 }
 
-func convertToLilypondChord(chord notes.Chord) lilypond.SingleChord {
-	chordOnClefs := notes.ChordToChordOnClefs(chord)
-	lilypondChord := lilypond.SingleChord{}
-
-	for i := 0; i < len(chordOnClefs.TrebleClefNotes); i++ {
-		lilypondChord.TrebleNotes = append(lilypondChord.TrebleNotes, chordOnClefs.TrebleClefNotes[i].LilypondSymbol())
+func writeChordsMusicXMLFile(chords []notes.Chord, chordFilePath string, musicXMLOutput utils.MusicXMLOutput) error {
+	if musicXMLOutput == utils.MusicXMLOutputNone {
+		return nil
 	}
 
-	if len(chordOnClefs.TrebleClefNotes) == 0 {
-		lilypondChord.TrebleRaw = "s4"
+	opts := musicxml.DefaultOptions
+	if len(chords) == 1 {
+		opts.Title = backText(chords[0])
 	}
 
-	for i := 0; i < len(chordOnClefs.BassClefNotes); i++ {
-		lilypondChord.BassNotes = append(lilypondChord.BassNotes, chordOnClefs.BassClefNotes[i].LilypondSymbol())
+	return musicxml.WriteFile(utils.ReplaceExtension(chordFilePath, ".musicxml"), musicxml.ChordsScore(chords, opts))
+}
+
+func writeChordsABCFile(chords []notes.Chord, chordFilePath string, abcOutput bool) error {
+	if !abcOutput {
+		return nil
 	}
 
-	if len(chordOnClefs.BassClefNotes) == 0 {
-		lilypondChord.BassRaw = "s4"
+	opts := abc.DefaultOptions
+	if len(chords) == 1 {
+		opts.Title = backText(chords[0])
 	}
 
-	return lilypondChord
+	return abc.WriteFile(utils.ReplaceExtension(chordFilePath, ".abc"), abc.ChordsTune(chords, opts))
 }
 
-func renderChordAndWriteFile(ctx context.Context, renderer lilypond.Renderer, chord lilypond.MultipleChords, chordFilePath string) error {
-	png, err := lilypond.RenderChordImage(ctx, &renderer, chord)
+func chordFilePath(imageDir string, chord notes.Chord) string {
+	return fmt.Sprintf("%s/%s", imageDir, fmt.Sprintf("%s.png", chordFileName(chord)))
+}
 
-	if err != nil {
-		return fmt.Errorf("failed to render lilypond image: %v", err)
+func convertToLilypondChords(chord []notes.Chord, annotations lilypond.ChordAnnotations) []lilypond.SingleChord {
+	var result []lilypond.SingleChord
+	for i := 0; i < len(chord); i++ {
+		result = append(result, lilypond.NewAnnotatedSingleChord(chord[i], annotations))
 	}
 
-	if debug {
-		source, err := lilypond.RenderChordSource(ctx, &renderer, chord)
+	return result
+}
+
+func renderChordAndWriteFile(ctx context.Context, renderer lilypond.Renderer, renderOpts lilypond.RenderOptions, templates *lilypond.Templates, debugDir *lilypond.DebugDir, chord lilypond.MultipleChords, chordFilePath string) error {
+	return renderChordsAndWriteFiles(ctx, renderer, renderOpts, templates, debugDir, []lilypond.MultipleChords{chord}, []string{chordFilePath})[0]
+}
+
+// renderChordsAndWriteFiles renders all images in one batch and returns an error for every image that failed.
+// The artifacts are written next to each other, named like the .png path.
+func renderChordsAndWriteFiles(ctx context.Context, renderer lilypond.Renderer, renderOpts lilypond.RenderOptions, templates *lilypond.Templates, debugDir *lilypond.DebugDir, chords []lilypond.MultipleChords, chordFilePaths []string) []error {
+	errs := make([]error, len(chords))
+	var sources []string
+	var sourceIndices []int
+	for i, chord := range chords {
+		source, err := templates.ChordSource(chord)
 		if err != nil {
-			return fmt.Errorf("failed to render lilypond source: %v", err)
+			errs[i] = fmt.Errorf("failed to render lilypond source: %v", err)
+			continue
 		}
 
-		if err = ioutil.WriteFile(chordFilePath+".ll", []byte(source), os.FileMode(0660)); err != nil {
-			return err
+		if debug {
+			if err = ioutil.WriteFile(chordFilePaths[i]+".ll", []byte(source), os.FileMode(0660)); err != nil {
+				errs[i] = err
+				continue
+			}
 		}
+		sources = append(sources, source)
+		sourceIndices = append(sourceIndices, i)
 	}
 
-	err = ioutil.WriteFile(chordFilePath, png, os.FileMode(0660))
-	if err != nil {
-		return fmt.Errorf("failed to write png file: %v", err)
+	for j, result := range lilypond.RenderBatch(ctx, renderer, sources, renderOpts) {
+		i := sourceIndices[j]
+		if result.Err != nil {
+			if err := debugDir.Record(chordFilePaths[i], sources[j], result.Err); err != nil {
+				log.Printf("failed to keep the source of %s: %v", chordFilePaths[i], err)
+			}
+			errs[i] = fmt.Errorf("failed to render lilypond image %s: %v", chordFilePaths[i], result.Err)
+			continue
+		}
+
+		if err := result.WriteFiles(utils.ReplaceExtension(chordFilePaths[i], "")); err != nil {
+			errs[i] = err
+			continue
+		}
+
+		log.Printf("Rendered file: %s\n", chordFilePaths[i])
 	}
 
-	log.Printf("Rendered file: %s\n", chordFilePath)
+	return errs
+}
+
+// writePackage writes the rendered images and sounds of the chords as an .apkg package.
+func writePackage(path string, deckName string, imageDir string, chords []notes.Chord, directions []anki.Direction) error {
+	deck := anki.Deck{Name: deckName, Directions: directions}
+	for _, chord := range chords {
+		imagePath := chordFilePath(imageDir, chord)
+		deck.Notes = append(deck.Notes, anki.ChordNote(chord, imagePath, utils.ExistingPath(utils.ReplaceExtension(imagePath, ".wav"))))
+	}
 
-	return nil
+	return deck.WritePackage(path)
 }
 
 func chordFileName(chord notes.Chord) string {
@@ -246,15 +425,51 @@ func chordFileName(chord notes.Chord) string {
 	return fmt.Sprintf("ng-chord-%s-%s", md5Hash, chordFileName)
 }
 
-func prepareDeck(chords []notes.Chord) string {
-	deckLines := make([]string, 0)
-
+// prepareDeck returns the cards of the chords in all directions ordered by their sort keys.
+func prepareDeck(chords []notes.Chord, imageDir string, sound utils.SoundOutputs, earTraining bool, directions []anki.Direction) []anki.Card {
+	var cards []anki.Card
 	for i := 0; i < len(chords); i++ {
-		deckLines = append(deckLines, deckLine(chords[i]))
+		cards = append(cards, chordCards(chords[i], imageDir, sound, earTraining, directions)...)
+	}
+
+	sort.SliceStable(cards, func(i int, j int) bool { return cards[i].SortKey < cards[j].SortKey })
+	return cards
+}
+
+// chordCards returns the cards of the chord, with ear training the notation card plays the chord instead.
+func chordCards(chord notes.Chord, imageDir string, sound utils.SoundOutputs, earTraining bool, directions []anki.Direction) []anki.Card {
+	imagePath := chordFilePath(imageDir, chord)
+	audioPath := ""
+	if sound.AudioOptions != nil {
+		audioPath = utils.ReplaceExtension(imagePath, ".wav")
+	}
+
+	cards := anki.DirectionCards(anki.Card{
+		Note:      anki.ChordNote(chord, imagePath, audioPath),
+		Front:     frontText(chord),
+		Back:      backText(chord),
+		ChordType: chord.Type.Description(),
+		SortKey:   chordSortKey(chord),
+	}, directions)
+	for i := range cards {
+		if earTraining && cards[i].Direction == anki.DirectionNotation {
+			cards[i].GUID = utils.EarTrainingGUID(cards[i].GUID)
+			cards[i].Front = soundText(chord)
+			cards[i].Back = fmt.Sprintf("%s<br>%s", frontText(chord), backText(chord))
+		}
 	}
 
-	sort.Strings(deckLines)
-	return strings.Join(deckLines, "\n")
+	return cards
+}
+
+// chordSortKey orders the chords by the number of accidentals of the scale, the scale, the root and the notes.
+func chordSortKey(chord notes.Chord) string {
+	key := fmt.Sprintf("%d %s %s", len(chord.Scale.NotesModified), chord.Scale.Name, chord.RootNote.BaseName)
+	for _, n := range chord.Notes {
+		// tone indices are offset to be positive and compare as text
+		key += fmt.Sprintf(" %03d", n.ToneIndex()+100)
+	}
+	return key
 }
 
 func prepareHtml(chords []notes.Chord) string {
@@ -297,8 +512,20 @@ func prepareHtml(chords []notes.Chord) string {
 `, strings.Join(lines, "\n"))
 }
 
-func deckLine(chord notes.Chord) string {
-	return fmt.Sprintf(`"%s";"%s"`, frontText(chord), backText(chord))
+func soundText(chord notes.Chord) string {
+	return fmt.Sprintf("[sound:%s.wav]", chordFileName(chord))
+}
+
+// chordVoicingKey is the same for all transpositions of a chord with the same type and spacing of notes.
+func chordVoicingKey(chord notes.Chord) string {
+	key := chord.Type.String()
+	for i := 1; i < len(chord.Notes); i++ {
+		key += fmt.Sprintf("_%d", chord.Notes[i-1].ToneIndex()-chord.Notes[i].ToneIndex())
+	}
+	bassAboveRoot := chord.Notes[len(chord.Notes)-1].ToneIndex() - chord.RootNote.ToneIndex()
+	key += fmt.Sprintf("_%d", (bassAboveRoot%12+12)%12)
+
+	return key
 }
 
 func backText(chord notes.Chord) string {
@@ -306,5 +533,5 @@ func backText(chord notes.Chord) string {
 }
 
 func frontText(chord notes.Chord) string {
-	return fmt.Sprintf("<img src=\"\"%s.png\"\">", chordFileName(chord))
+	return fmt.Sprintf(`<img src="%s.png">`, chordFileName(chord))
 }
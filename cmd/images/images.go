@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/images"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func main() {
+	imageDir := flag.String("imageDir", "images", "directory with the rendered .png images of a deck")
+	reportPath := flag.String("report", "", "path to the validation report, tab separated lines of image, issue and detail, empty prints it")
+	margin := flag.Int("margin", images.DefaultOptions.Margin, "process: whitespace in pixels kept around the notation")
+	height := flag.Int("height", 0, "process: height in pixels all images are padded to, 0 uses the tallest image")
+	retina := flag.Bool("retina", false, "process: keep the full resolution in name@2x.png and halve name.png, render with twice the usual -dpi")
+	wideFactor := flag.Float64("wideFactor", images.DefaultOptions.WideFactor, "report images wider than this many times the median width, 0 disables it")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] validate|process\n", flag.CommandLine.Name())
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		log.Fatal("expected exactly one command")
+	}
+
+	if *margin < 0 {
+		log.Fatalf("invalid margin: %d", *margin)
+	}
+
+	paths, err := deckImages(*imageDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts := images.Options{Margin: *margin, Height: *height, Retina: *retina, WideFactor: *wideFactor}
+	var report images.Report
+	switch flag.Arg(0) {
+	case "validate":
+		report = images.Validate(paths, opts)
+	case "process":
+		report, err = images.Process(paths, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		flag.Usage()
+		log.Fatalf("unknown command: %s", flag.Arg(0))
+	}
+
+	if err := writeReport(report, *reportPath); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d images, %d suspicious\n", report.Images, len(report.Findings))
+}
+
+// deckImages returns the images of the deck, without the @2x variants
+func deckImages(imageDir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(imageDir, "*.png"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images in %s: %v", imageDir, err)
+	}
+
+	var deck []string
+	for _, path := range paths {
+		if !strings.HasSuffix(path, "@2x.png") {
+			deck = append(deck, path)
+		}
+	}
+	sort.Strings(deck)
+
+	return deck, nil
+}
+
+func writeReport(report images.Report, path string) error {
+	if path == "" {
+		return report.Write(os.Stdout)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %v", err)
+	}
+	defer file.Close()
+
+	return report.Write(file)
+}
@@ -5,7 +5,11 @@ import (
 	"crypto/md5"
 	"flag"
 	"fmt"
+	"github.com/lsierant/notes-gen/pkg/abc"
+	"github.com/lsierant/notes-gen/pkg/anki"
 	"github.com/lsierant/notes-gen/pkg/lilypond"
+	"github.com/lsierant/notes-gen/pkg/midi"
+	"github.com/lsierant/notes-gen/pkg/musicxml"
 	"github.com/lsierant/notes-gen/pkg/notes"
 	"github.com/lsierant/notes-gen/pkg/utils"
 	"io/ioutil"
@@ -29,9 +33,24 @@ func main() {
 	imageDir := flag.String("imageDir", "images", "destination directory for storing generated images")
 	deckFilePath := flag.String("deckFilePath", "deck.csv", "path to generated deck file")
 	htmlFilePath := flag.String("htmlFilePath", "", "path to generated html file with all images")
+	apkgPath := flag.String("apkgPath", "", "path to a generated .apkg package with the cards, images and sounds, ready to import into Anki")
+	deckName := flag.String("deckName", "notes-gen::intervals", "name of the deck in the .apkg package")
 	parallel := flag.Int("parallel", runtime.NumCPU(), "level of parallelism, defaults to number of CPUs")
 	scaleFlag := flag.String("scale", "c major", `scale to use, e.g. "c flat major", "d minor", "c sharp minor", default: "c major"`)
 	accidentals := flag.Int("accidentals", 7, "filter scales up to given number of accidentals")
+	sizes := flag.String("sizes", "", `comma separated interval sizes to generate, e.g. "third,sixth", default: all`)
+	qualities := flag.String("qualities", "", `comma separated interval qualities to generate, e.g. "major,minor", default: all`)
+	clefs := flag.String("clefs", "", `comma separated clef layouts to generate: "treble", "bass", "cross", default: all`)
+	minDistance := flag.Int("minDistance", 0, "minimum interval distance in semitones")
+	maxDistance := flag.Int("maxDistance", 12, "maximum interval distance in semitones, 0 means no limit")
+	limit := flag.Int("limit", 0, "maximum number of cards, sampled evenly from all matching intervals, 0 means no limit")
+	deckFlags := utils.RegisterDeckFlags(flag.CommandLine)
+	soundFlags := utils.RegisterSoundFlags(flag.CommandLine)
+	earFlags := utils.RegisterEarTrainingFlags(flag.CommandLine)
+	musicXMLFlag := flag.String("musicxml", "none", `write .musicxml files: "none", "also" to write them next to images, "only" to skip rendering images`)
+	abcFlag := flag.Bool("abc", false, "also write .abc files next to images")
+	rendererFlags := utils.RegisterRendererFlags(flag.CommandLine)
+	templateFlags := utils.RegisterTemplateFlags(flag.CommandLine)
 
 	flag.Parse()
 
@@ -40,13 +59,67 @@ func main() {
 		log.Fatal(err)
 	}
 
-	renderer := lilypond.Renderer{WorkingDir: *tmpDir}
+	filter, err := utils.IntervalFilterFromFlags(*sizes, *qualities, *clefs, *minDistance, *maxDistance, *limit)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	renderer, err := rendererFlags.Renderer(*tmpDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	debugDir := rendererFlags.DebugDir()
+
+	renderOpts, err := rendererFlags.RenderOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	templates, err := templateFlags.Templates()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	deckFile, err := deckFlags.DeckFile()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	directions, err := deckFlags.Directions(anki.DirectionRoman, anki.DirectionRoot)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	intervals := generateIntervals(scales)
+	musicXMLOutput, err := utils.ParseMusicXMLOutput(*musicXMLFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *apkgPath != "" && musicXMLOutput == utils.MusicXMLOutputOnly {
+		log.Fatal("the .apkg package needs rendered images, -apkgPath can't be used with -musicxml only")
+	}
 
-	deckFileContent := prepareDeck(intervals)
+	ear, err := earFlags.Options(soundFlags)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sound, err := soundFlags.Outputs()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	intervals := filter.Apply(generateIntervals(scales))
+	if ear != nil {
+		var unique []notes.Interval
+		for _, idx := range ear.Deduplicate(len(intervals), func(idx int) string { return intervals[idx].Name() }) {
+			unique = append(unique, intervals[idx])
+		}
+		intervals = unique
+	}
+	log.Printf("Generating %d intervals", len(intervals))
 
-	err = ioutil.WriteFile(*deckFilePath, []byte(deckFileContent), 0660)
+	err = deckFile.WriteFile(*deckFilePath, prepareDeck(intervals, *imageDir, sound, ear != nil, directions))
 	if err != nil {
 		log.Fatalf("errors while rendering file:\n%v", err)
 	}
@@ -60,20 +133,45 @@ func main() {
 		}
 	}
 
-	err = utils.RunInParallel(ctx, len(intervals), *parallel, func(idx int) error {
-		intervalFileName := fmt.Sprintf("%s/%s", *imageDir, fmt.Sprintf("%s.png", intervalFileName(intervals[idx])))
-		if _, err := os.Stat(intervalFileName); err == nil {
-			fmt.Printf("Skipping rendering: %s\n", intervalFileName)
-			return nil
+	err = utils.RunInBatches(ctx, len(intervals), rendererFlags.BatchSize(), *parallel, func(indices []int) []error {
+		errs := make([]error, len(indices))
+		var toRender []int
+		var paths []string
+		for i, idx := range indices {
+			intervalFileName := intervalFilePath(*imageDir, intervals[idx])
+			render, err := writeIntervalFiles(intervals[idx], intervalFileName, sound, ear, musicXMLOutput, *abcFlag)
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			if render {
+				toRender = append(toRender, i)
+				paths = append(paths, intervalFileName)
+			}
 		}
 
-		return renderIntervalAndWriteFile(ctx, renderer, intervals[idx], intervalFileName)
+		var toRenderIntervals []notes.Interval
+		for _, i := range toRender {
+			toRenderIntervals = append(toRenderIntervals, intervals[indices[i]])
+		}
+		for j, err := range renderIntervalsAndWriteFiles(ctx, renderer, renderOpts, templates, debugDir, toRenderIntervals, paths) {
+			errs[toRender[j]] = err
+		}
+
+		return errs
 	})
 
 	if err != nil {
 		log.Fatalf("error writing file: %v", err)
 	}
 
+	if *apkgPath != "" {
+		if err := writePackage(*apkgPath, *deckName, *imageDir, intervals, directions); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Written package: %s\n", *apkgPath)
+	}
+
 	fmt.Println("Done...")
 }
 
@@ -94,15 +192,47 @@ func generateIntervals(scales []notes.Scale) []notes.Interval {
 	return intervals
 }
 
-func prepareDeck(intervals []notes.Interval) string {
-	deckLines := make([]string, 0)
-
+// prepareDeck returns the cards of the intervals in all directions ordered by their sort keys.
+func prepareDeck(intervals []notes.Interval, imageDir string, sound utils.SoundOutputs, earTraining bool, directions []anki.Direction) []anki.Card {
+	var cards []anki.Card
 	for i := 0; i < len(intervals); i++ {
-		deckLines = append(deckLines, deckLine(intervals[i]))
+		cards = append(cards, intervalCards(intervals[i], imageDir, sound, earTraining, directions)...)
 	}
 
-	sort.Strings(deckLines)
-	return strings.Join(deckLines, "\n")
+	sort.SliceStable(cards, func(i int, j int) bool { return cards[i].SortKey < cards[j].SortKey })
+	return cards
+}
+
+// intervalCards returns the cards of the interval, with ear training the notation card plays the interval instead.
+func intervalCards(interval notes.Interval, imageDir string, sound utils.SoundOutputs, earTraining bool, directions []anki.Direction) []anki.Card {
+	imagePath := intervalFilePath(imageDir, interval)
+	audioPath := ""
+	if sound.AudioOptions != nil {
+		audioPath = utils.ReplaceExtension(imagePath, ".wav")
+	}
+
+	cards := anki.DirectionCards(anki.Card{
+		Note:    anki.IntervalNote(interval, imagePath, audioPath),
+		Front:   frontText(interval),
+		Back:    backText(interval),
+		SortKey: intervalSortKey(interval),
+	}, directions)
+	for i := range cards {
+		if earTraining && cards[i].Direction == anki.DirectionNotation {
+			cards[i].GUID = utils.EarTrainingGUID(cards[i].GUID)
+			cards[i].Front = soundText(interval)
+			cards[i].Back = fmt.Sprintf("%s<br>%s", frontText(interval), backText(interval))
+		}
+	}
+
+	return cards
+}
+
+// intervalSortKey orders the intervals by the number of accidentals of the scale, the scale, the distance and the notes.
+func intervalSortKey(interval notes.Interval) string {
+	// tone indices are offset to be positive and compare as text
+	return fmt.Sprintf("%d %s %02d %03d %03d", len(interval.Scale.NotesModified), interval.Scale.Name, interval.Distance(),
+		interval.FirstNote.ToneIndex()+100, interval.SecondNote.ToneIndex()+100)
 }
 
 func prepareHtml(intervals []notes.Interval) string {
@@ -143,33 +273,96 @@ func prepareHtml(intervals []notes.Interval) string {
 `, strings.Join(lines, "\n"))
 }
 
-func deckLine(interval notes.Interval) string {
-	return fmt.Sprintf(`"%s";"%s"`, frontText(interval), backText(interval))
+func soundText(interval notes.Interval) string {
+	return fmt.Sprintf("[sound:%s.wav]", intervalFileName(interval))
 }
 
 func backText(interval notes.Interval) string {
-	return fmt.Sprintf("%s (%d), %s -> %s", interval.Name(), interval.Distance(), interval.FirstNote.NameWithModifier(), interval.SecondNote.NameWithModifier())
+	return fmt.Sprintf("%s (%d), %s -> %s", interval.Name(), interval.Distance(), interval.FirstNote.ScientificName(), interval.SecondNote.ScientificName())
 }
 
 func frontText(interval notes.Interval) string {
-	return fmt.Sprintf("<img src=\"\"%s.png\"\">", intervalFileName(interval))
+	return fmt.Sprintf(`<img src="%s.png">`, intervalFileName(interval))
 }
 
-func renderIntervalAndWriteFile(ctx context.Context, renderer lilypond.Renderer, interval notes.Interval, intervalFilePath string) error {
-	png, err := lilypond.RenderIntervalImage(ctx, &renderer, interval)
+// writeIntervalFiles writes the sound, musicxml and abc files of the interval and returns whether its image needs rendering.
+func writeIntervalFiles(interval notes.Interval, intervalFileName string, sound utils.SoundOutputs, ear *utils.EarTraining, musicXMLOutput utils.MusicXMLOutput, abcOutput bool) (bool, error) {
+	sonority := midi.IntervalSonority(interval)
+	if ear != nil {
+		sonority = ear.Transpose(intervalFileName, sonority)
+	}
 
-	if err != nil {
-		return fmt.Errorf("failed to render lilypond image: %v", err)
+	if err := sound.Write(intervalFileName, []midi.Sonority{sonority}, interval.Scale); err != nil {
+		return false, err
 	}
 
-	err = ioutil.WriteFile(intervalFilePath, png, os.FileMode(0660))
-	if err != nil {
-		return fmt.Errorf("failed to write png file: %v", err)
+	if musicXMLOutput != utils.MusicXMLOutputNone {
+		opts := musicxml.DefaultOptions
+		opts.Title = interval.Name()
+		score := musicxml.IntervalScore(interval, opts)
+		if err := musicxml.WriteFile(utils.ReplaceExtension(intervalFileName, ".musicxml"), score); err != nil {
+			return false, err
+		}
+	}
+	if abcOutput {
+		opts := abc.DefaultOptions
+		opts.Title = interval.Name()
+		if err := abc.WriteFile(utils.ReplaceExtension(intervalFileName, ".abc"), abc.IntervalTune(interval, opts)); err != nil {
+			return false, err
+		}
+	}
+	return musicXMLOutput != utils.MusicXMLOutputOnly, nil
+}
+
+// renderIntervalsAndWriteFiles renders all intervals in one batch and returns an error for every interval that failed.
+// The artifacts are written next to each other, named like the .png path.
+func renderIntervalsAndWriteFiles(ctx context.Context, renderer lilypond.Renderer, renderOpts lilypond.RenderOptions, templates *lilypond.Templates, debugDir *lilypond.DebugDir, intervals []notes.Interval, intervalFilePaths []string) []error {
+	errs := make([]error, len(intervals))
+	var sources []string
+	var sourceIndices []int
+	for i, interval := range intervals {
+		source, err := templates.IntervalSource(interval)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		sources = append(sources, source)
+		sourceIndices = append(sourceIndices, i)
+	}
+
+	for j, result := range lilypond.RenderBatch(ctx, renderer, sources, renderOpts) {
+		i := sourceIndices[j]
+		if result.Err != nil {
+			if err := debugDir.Record(intervalFilePaths[i], sources[j], result.Err); err != nil {
+				log.Printf("failed to keep the source of %s: %v", intervalFilePaths[i], err)
+			}
+			errs[i] = fmt.Errorf("failed to render lilypond image of %s: %v", intervals[i].Name(), result.Err)
+			continue
+		}
+
+		if err := result.WriteFiles(utils.ReplaceExtension(intervalFilePaths[i], "")); err != nil {
+			errs[i] = err
+			continue
+		}
+		log.Printf("Rendered file: %s\n", intervalFilePaths[i])
 	}
 
-	log.Printf("Rendered file: %s\n", intervalFilePath)
+	return errs
+}
+
+// writePackage writes the rendered images and sounds of the intervals as an .apkg package.
+func writePackage(path string, deckName string, imageDir string, intervals []notes.Interval, directions []anki.Direction) error {
+	deck := anki.Deck{Name: deckName, Directions: directions}
+	for _, interval := range intervals {
+		imagePath := intervalFilePath(imageDir, interval)
+		deck.Notes = append(deck.Notes, anki.IntervalNote(interval, imagePath, utils.ExistingPath(utils.ReplaceExtension(imagePath, ".wav"))))
+	}
+
+	return deck.WritePackage(path)
+}
 
-	return nil
+func intervalFilePath(imageDir string, interval notes.Interval) string {
+	return fmt.Sprintf("%s/%s.png", imageDir, intervalFileName(interval))
 }
 
 func intervalFileName(interval notes.Interval) string {
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"flag"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/abc"
+	"github.com/lsierant/notes-gen/pkg/anki"
+	"github.com/lsierant/notes-gen/pkg/lilypond"
+	"github.com/lsierant/notes-gen/pkg/midi"
+	"github.com/lsierant/notes-gen/pkg/musicxml"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"github.com/lsierant/notes-gen/pkg/utils"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// maxPositions limits how many occurrences are listed on the back of a card
+const maxPositions = 5
+
+// analyzedChord is a chord found in the score with its position
+type analyzedChord struct {
+	position string
+	chord    notes.Chord
+}
+
+// analyzedInterval is a melodic interval found in the score with its position
+type analyzedInterval struct {
+	position  string
+	interval  notes.Interval
+	ascending bool
+}
+
+// card is a chord or an interval found in the score together with all places where it occurs
+type card struct {
+	fileName  string
+	guid      string
+	name      string
+	positions []string
+	chord     *notes.Chord
+	interval  *notes.Interval
+}
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	utils.HandleSignals(func(code os.Signal) {
+		log.Printf("Received signal %d", code)
+		cancel()
+	})
+
+	input := flag.String("input", "", "path to the .musicxml, .mid or .abc score to import")
+	tmpDir := flag.String("tmpDir", "tmp", "temp directory for generating lilypond images")
+	imageDir := flag.String("imageDir", "images", "destination directory for storing generated images")
+	deckFilePath := flag.String("deckFilePath", "deck.csv", "path to generated deck file")
+	parallel := flag.Int("parallel", runtime.NumCPU(), "level of parallelism, defaults to number of CPUs")
+	withChords := flag.Bool("chords", true, "generate cards for chords found in the score")
+	withIntervals := flag.Bool("intervals", true, "generate cards for melodic intervals found in the score, musicxml and abc only")
+	midiTolerance := flag.Float64("midiTolerance", 0.125, "notes starting within this many quarter notes are simultaneous, midi only")
+	deckFlags := utils.RegisterDeckFlags(flag.CommandLine)
+	rendererFlags := utils.RegisterRendererFlags(flag.CommandLine)
+	templateFlags := utils.RegisterTemplateFlags(flag.CommandLine)
+
+	flag.Parse()
+
+	if *input == "" {
+		log.Fatal("-input is required")
+	}
+
+	renderer, err := rendererFlags.Renderer(*tmpDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	debugDir := rendererFlags.DebugDir()
+
+	renderOpts, err := rendererFlags.RenderOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	templates, err := templateFlags.Templates()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	deckFile, err := deckFlags.DeckFile()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var cards []*card
+	switch strings.ToLower(filepath.Ext(*input)) {
+	case ".mid", ".midi":
+		chords, err := midiChords(*input, *midiTolerance)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *withChords {
+			cards = append(cards, chordCards(chords)...)
+		}
+	case ".abc":
+		tunes, err := abc.ReadFile(*input)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var chords []analyzedChord
+		var intervals []analyzedInterval
+		for _, tune := range tunes {
+			tuneChords, tuneIntervals := abcChordsAndIntervals(tune, len(tunes) > 1)
+			chords = append(chords, tuneChords...)
+			intervals = append(intervals, tuneIntervals...)
+		}
+
+		if *withChords {
+			cards = append(cards, chordCards(chords)...)
+		}
+		if *withIntervals {
+			cards = append(cards, intervalCards(intervals)...)
+		}
+	default:
+		score, err := musicxml.ReadFile(*input)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		events, err := musicxml.Events(score)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if *withChords {
+			cards = append(cards, chordCards(musicXMLChords(musicxml.Sonorities(events)))...)
+		}
+		if *withIntervals {
+			cards = append(cards, intervalCards(musicXMLIntervals(musicxml.MelodicIntervals(events)))...)
+		}
+	}
+	log.Printf("Generating %d cards from %s", len(cards), *input)
+
+	err = deckFile.WriteFile(*deckFilePath, prepareDeck(cards, *imageDir))
+	if err != nil {
+		log.Fatalf("errors while rendering file:\n%v", err)
+	}
+
+	err = utils.RunInBatches(ctx, len(cards), rendererFlags.BatchSize(), *parallel, func(indices []int) []error {
+		var batchCards []*card
+		var paths []string
+		for _, idx := range indices {
+			batchCards = append(batchCards, cards[idx])
+			paths = append(paths, cardFilePath(*imageDir, cards[idx]))
+		}
+
+		return renderCardsAndWriteFiles(ctx, renderer, renderOpts, templates, debugDir, batchCards, paths)
+	})
+
+	if err != nil {
+		log.Fatalf("error writing file: %v", err)
+	}
+
+	fmt.Println("Done...")
+}
+
+func musicXMLChords(sonorities []musicxml.Sonority) []analyzedChord {
+	var chords []analyzedChord
+	for _, sonority := range sonorities {
+		if chord, ok := sonority.Chord(); ok {
+			chords = append(chords, analyzedChord{position: sonority.Position.String(), chord: chord})
+		}
+	}
+
+	return chords
+}
+
+func midiChords(path string, tolerance float64) ([]analyzedChord, error) {
+	file, err := midi.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sonorities, err := file.Sonorities(int(tolerance * float64(file.Division)))
+	if err != nil {
+		return nil, err
+	}
+
+	var chords []analyzedChord
+	for _, sonority := range sonorities {
+		if chord, ok := sonority.Chord(); ok {
+			chords = append(chords, analyzedChord{position: sonority.Position.String(), chord: chord})
+		}
+	}
+
+	return chords, nil
+}
+
+func chordCards(chords []analyzedChord) []*card {
+	var cards []*card
+	byFileName := map[string]*card{}
+	for _, analyzed := range chords {
+		chord := analyzed.chord
+		for i := range chord.Notes {
+			chord.Notes[i] = chord.Notes[i].OnNearestClef()
+		}
+		chord.RootNote = chord.RootNote.OnNearestClef()
+
+		var noteNames []string
+		for _, n := range chord.Notes {
+			noteNames = append(noteNames, n.String())
+		}
+		fileName := cardFileName(chord.Scale, "chord_"+strings.Join(noteNames, "_"))
+
+		if c, ok := byFileName[fileName]; ok {
+			c.positions = append(c.positions, analyzed.position)
+			continue
+		}
+
+		c := &card{
+			fileName:  fileName,
+			guid:      chord.ID(),
+			name:      fmt.Sprintf("%s, %s (%s)", chord.Name(), chord.RomanNumeral(), chord.Scale.Name),
+			positions: []string{analyzed.position},
+			chord:     &chord,
+		}
+		byFileName[fileName] = c
+		cards = append(cards, c)
+	}
+
+	return cards
+}
+
+func musicXMLIntervals(melodicIntervals []musicxml.MelodicInterval) []analyzedInterval {
+	var intervals []analyzedInterval
+	for _, melodicInterval := range melodicIntervals {
+		intervals = append(intervals, analyzedInterval{
+			position:  fmt.Sprintf("%s, part %s, voice %s", melodicInterval.Position, melodicInterval.Part, melodicInterval.Voice),
+			interval:  melodicInterval.Interval,
+			ascending: melodicInterval.Ascending,
+		})
+	}
+
+	return intervals
+}
+
+// abcChordsAndIntervals analyzes a tune, positions are prefixed with the tune title when the file has more tunes
+func abcChordsAndIntervals(tune abc.Tune, withTitle bool) ([]analyzedChord, []analyzedInterval) {
+	prefix := ""
+	if withTitle {
+		prefix = fmt.Sprintf("%s: ", tune.Title)
+	}
+
+	var chords []analyzedChord
+	for _, sonority := range abc.Sonorities(tune) {
+		if chord, ok := sonority.Chord(); ok {
+			chords = append(chords, analyzedChord{position: prefix + sonority.Position.String(), chord: chord})
+		}
+	}
+
+	var intervals []analyzedInterval
+	for _, melodicInterval := range abc.MelodicIntervals(tune) {
+		intervals = append(intervals, analyzedInterval{
+			position:  fmt.Sprintf("%s%s, voice %s", prefix, melodicInterval.Position, melodicInterval.Voice),
+			interval:  melodicInterval.Interval,
+			ascending: melodicInterval.Ascending,
+		})
+	}
+
+	return chords, intervals
+}
+
+func intervalCards(intervals []analyzedInterval) []*card {
+	var cards []*card
+	byFileName := map[string]*card{}
+	for _, analyzed := range intervals {
+		interval := analyzed.interval
+		if !interval.Supported() {
+			continue
+		}
+
+		interval.FirstNote = interval.FirstNote.OnNearestClef()
+		interval.SecondNote = interval.SecondNote.OnNearestClef()
+		first, second := interval.FirstNote, interval.SecondNote
+		direction := "ascending"
+		guid := interval.ID()
+		if !analyzed.ascending {
+			first, second = second, first
+			direction = "descending"
+			// the same notes sung downwards are a separate card
+			guid += "-" + direction
+		}
+
+		fileName := cardFileName(interval.Scale, fmt.Sprintf("interval_%s_%s", first, second))
+		if c, ok := byFileName[fileName]; ok {
+			c.positions = append(c.positions, analyzed.position)
+			continue
+		}
+
+		c := &card{
+			fileName:  fileName,
+			guid:      guid,
+			name:      fmt.Sprintf("%s %s (%d), %s -> %s", interval.Name(), direction, interval.Distance(), first.ScientificName(), second.ScientificName()),
+			positions: []string{analyzed.position},
+			interval:  &interval,
+		}
+		byFileName[fileName] = c
+		cards = append(cards, c)
+	}
+
+	return cards
+}
+
+func cardFileName(scale notes.Scale, name string) string {
+	fileName := fmt.Sprintf("%s_%s", strings.ReplaceAll(scale.Name, " ", "_"), name)
+	md5Hash := fmt.Sprintf("%x", md5.Sum([]byte(fileName)))
+	return fmt.Sprintf("ng-%s-%s", md5Hash, fileName)
+}
+
+func cardFilePath(imageDir string, c *card) string {
+	return fmt.Sprintf("%s/%s.png", imageDir, c.fileName)
+}
+
+// prepareDeck returns the deck cards ordered by their image file names.
+func prepareDeck(cards []*card, imageDir string) []anki.Card {
+	var deckCards []anki.Card
+	for _, c := range cards {
+		deckCards = append(deckCards, deckCard(c, imageDir))
+	}
+
+	sort.SliceStable(deckCards, func(i int, j int) bool { return deckCards[i].SortKey < deckCards[j].SortKey })
+	return deckCards
+}
+
+func deckCard(c *card, imageDir string) anki.Card {
+	deckCard := anki.Card{
+		Note:    anki.Note{GUID: c.guid, Image: cardFilePath(imageDir, c), Name: c.name},
+		Front:   frontText(c),
+		Back:    backText(c),
+		SortKey: c.fileName,
+	}
+	if c.chord != nil {
+		deckCard.Scale = c.chord.Scale.Name
+		deckCard.RomanNumeral = c.chord.RomanNumeral()
+		deckCard.ChordType = c.chord.Type.Description()
+	} else {
+		deckCard.Scale = c.interval.Scale.Name
+		deckCard.IntervalSize = c.interval.Size()
+	}
+
+	return deckCard
+}
+
+func frontText(c *card) string {
+	return fmt.Sprintf(`<img src="%s.png">`, c.fileName)
+}
+
+func backText(c *card) string {
+	positions := c.positions
+	if len(positions) > maxPositions {
+		positions = append(positions[:maxPositions:maxPositions], fmt.Sprintf("%d more", len(c.positions)-maxPositions))
+	}
+
+	return fmt.Sprintf("%s<br>%s", c.name, strings.Join(positions, "; "))
+}
+
+// renderCardsAndWriteFiles renders the card images in one batch and returns an error for every card that failed.
+// The artifacts are written next to each other, named like the .png path.
+func renderCardsAndWriteFiles(ctx context.Context, renderer lilypond.Renderer, renderOpts lilypond.RenderOptions, templates *lilypond.Templates, debugDir *lilypond.DebugDir, cards []*card, filePaths []string) []error {
+	errs := make([]error, len(cards))
+	var sources []string
+	var sourceIndices []int
+	for i, c := range cards {
+		var source string
+		var err error
+		if c.chord != nil {
+			source, err = templates.ChordSource(lilypond.MultipleChords{
+				Key:    lilypond.ScaleKey(c.chord.Scale),
+				Chords: []lilypond.SingleChord{lilypond.NewSingleChord(*c.chord)},
+			})
+		} else {
+			source, err = templates.IntervalSource(*c.interval)
+		}
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		sources = append(sources, source)
+		sourceIndices = append(sourceIndices, i)
+	}
+
+	for j, result := range lilypond.RenderBatch(ctx, renderer, sources, renderOpts) {
+		i := sourceIndices[j]
+		if result.Err != nil {
+			if err := debugDir.Record(filePaths[i], sources[j], result.Err); err != nil {
+				log.Printf("failed to keep the source of %s: %v", filePaths[i], err)
+			}
+			errs[i] = fmt.Errorf("failed to render lilypond image of %s: %v", cards[i].name, result.Err)
+			continue
+		}
+
+		if err := result.WriteFiles(utils.ReplaceExtension(filePaths[i], "")); err != nil {
+			errs[i] = err
+			continue
+		}
+
+		log.Printf("Rendered file: %s\n", filePaths[i])
+	}
+
+	return errs
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/lilypond"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"github.com/lsierant/notes-gen/pkg/utils"
+	"log"
+	"math/rand"
+	"os"
+)
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	utils.HandleSignals(func(code os.Signal) {
+		log.Printf("Received signal %d", code)
+		cancel()
+	})
+
+	tmpDir := flag.String("tmpDir", "tmp", "temp directory for generating lilypond files")
+	outputPath := flag.String("output", "worksheet.pdf", "path to the generated worksheet pdf")
+	answerKeyPath := flag.String("answerKey", "", "path to the generated answer key pdf, defaults to the worksheet path with an -answers suffix")
+	exercisesFlag := flag.String("exercises", "intervals", `kind of exercises: "intervals", "triads" or "sevenths"`)
+	count := flag.Int("count", lilypond.DefaultWorksheet.ItemsPerPage, "number of exercises, picked randomly from all matching ones")
+	title := flag.String("title", lilypond.DefaultWorksheet.Title, "title printed on top of the worksheet")
+	paperSize := flag.String("paperSize", lilypond.DefaultWorksheet.PaperSize, `lilypond paper size, e.g. "a4", "letter"`)
+	itemsPerRow := flag.Int("itemsPerRow", lilypond.DefaultWorksheet.ItemsPerRow, "number of exercises in a row")
+	itemsPerPage := flag.Int("itemsPerPage", lilypond.DefaultWorksheet.ItemsPerPage, "number of exercises on a page")
+	seed := flag.Int64("seed", 1, "seed for picking the exercises, the same seed gives the same worksheet")
+	scaleFlag := flag.String("scale", "c major", `scale to use, e.g. "c flat major", "d minor", "c sharp minor", "major" for all major scales, default: "c major"`)
+	accidentals := flag.Int("accidentals", 7, "filter scales up to given number of accidentals")
+	sizes := flag.String("sizes", "", `comma separated interval sizes to generate, e.g. "third,sixth", default: all`)
+	qualities := flag.String("qualities", "", `comma separated interval qualities to generate, e.g. "major,minor", default: all`)
+	clefs := flag.String("clefs", "", `comma separated clef layouts to generate: "treble", "bass", "cross", default: all`)
+	minDistance := flag.Int("minDistance", 0, "minimum interval distance in semitones")
+	maxDistance := flag.Int("maxDistance", 12, "maximum interval distance in semitones, 0 means no limit")
+	rendererFlags := utils.RegisterRendererFlags(flag.CommandLine)
+	templateFlags := utils.RegisterTemplateFlags(flag.CommandLine)
+
+	flag.Parse()
+
+	scales, err := utils.FilterScales(*scaleFlag, *accidentals)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	filter, err := utils.IntervalFilterFromFlags(*sizes, *qualities, *clefs, *minDistance, *maxDistance, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	renderer, err := rendererFlags.Renderer(*tmpDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	debugDir := rendererFlags.DebugDir()
+
+	renderOpts, err := rendererFlags.RenderOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// worksheets are multi-page documents, only the pdf has all pages in one file
+	renderOpts.Formats = []lilypond.Format{lilypond.FormatPDF}
+
+	templates, err := templateFlags.Templates()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *count <= 0 {
+		log.Fatalf("invalid number of exercises: %d", *count)
+	}
+
+	var exercises []lilypond.Exercise
+	switch *exercisesFlag {
+	case "intervals":
+		exercises, err = intervalExercises(filter.Apply(generateIntervals(scales)))
+	case "triads":
+		exercises = chordExercises(scales, notes.GenerateAllDiatonicTriadsInScale)
+	case "sevenths":
+		exercises = chordExercises(scales, notes.GenerateAllDiatonicSeventhsInScaleWithoutFifths)
+	default:
+		err = fmt.Errorf("invalid exercises: %s, expected one of: intervals, triads, sevenths", *exercisesFlag)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(exercises) == 0 {
+		log.Fatal("no exercises match the flags")
+	}
+
+	worksheet := lilypond.Worksheet{
+		Title:        *title,
+		PaperSize:    *paperSize,
+		ItemsPerRow:  *itemsPerRow,
+		ItemsPerPage: *itemsPerPage,
+		Exercises:    pickExercises(exercises, *count, *seed),
+	}
+
+	if *answerKeyPath == "" {
+		*answerKeyPath = utils.ReplaceExtension(*outputPath, "-answers.pdf")
+	}
+
+	if err := renderWorksheet(ctx, renderer, renderOpts, templates, debugDir, worksheet, *outputPath, *answerKeyPath); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("Done...")
+}
+
+func generateIntervals(scales []notes.Scale) []notes.Interval {
+	var intervals []notes.Interval
+	for _, scale := range scales {
+		notesInScale := notes.ApplyScale(notes.AllNotes, scale)
+		intervalsInScale := notes.GenerateIntervals(notesInScale, 0, len(notesInScale), 12)
+		for i := 0; i < len(intervalsInScale); i++ {
+			intervalsInScale[i].Scale = scale
+		}
+		intervals = append(intervals, intervalsInScale...)
+	}
+
+	return intervals
+}
+
+func intervalExercises(intervals []notes.Interval) ([]lilypond.Exercise, error) {
+	var exercises []lilypond.Exercise
+	for _, interval := range intervals {
+		chord, err := lilypond.NewIntervalChord(interval)
+		if err != nil {
+			return nil, err
+		}
+
+		exercises = append(exercises, lilypond.Exercise{
+			Key:    lilypond.ScaleKey(interval.Scale),
+			Chord:  chord,
+			Answer: interval.Name(),
+		})
+	}
+
+	return exercises, nil
+}
+
+func chordExercises(scales []notes.Scale, generate func(scale notes.Scale) []notes.Chord) []lilypond.Exercise {
+	var exercises []lilypond.Exercise
+	for _, scale := range scales {
+		for _, chord := range generate(scale) {
+			exercises = append(exercises, lilypond.Exercise{
+				Key:    lilypond.ScaleKey(scale),
+				Chord:  lilypond.NewSingleChord(chord),
+				Answer: fmt.Sprintf("%s (%s)", chord.Name(), chord.RomanNumeral()),
+			})
+		}
+	}
+
+	return exercises
+}
+
+// pickExercises returns count exercises in random order, repeating them only when there are fewer than count.
+func pickExercises(exercises []lilypond.Exercise, count int, seed int64) []lilypond.Exercise {
+	r := rand.New(rand.NewSource(seed))
+	var picked []lilypond.Exercise
+	for len(picked) < count {
+		for _, idx := range r.Perm(len(exercises)) {
+			if len(picked) == count {
+				break
+			}
+			picked = append(picked, exercises[idx])
+		}
+	}
+
+	return picked
+}
+
+// renderWorksheet renders the worksheet and its answer key in one batch.
+func renderWorksheet(ctx context.Context, renderer lilypond.Renderer, renderOpts lilypond.RenderOptions, templates *lilypond.Templates, debugDir *lilypond.DebugDir, worksheet lilypond.Worksheet, worksheetPath string, answerKeyPath string) error {
+	paths := []string{worksheetPath, answerKeyPath}
+	var sources []string
+	for i, answerKey := range []bool{false, true} {
+		source, err := templates.WorksheetSource(worksheet, answerKey)
+		if err != nil {
+			return fmt.Errorf("failed to render lilypond source of %s: %v", paths[i], err)
+		}
+		sources = append(sources, source)
+	}
+
+	for i, result := range lilypond.RenderBatch(ctx, renderer, sources, renderOpts) {
+		if result.Err != nil {
+			if err := debugDir.Record(paths[i], sources[i], result.Err); err != nil {
+				log.Printf("failed to keep the source of %s: %v", paths[i], err)
+			}
+			return fmt.Errorf("failed to render %s: %v", paths[i], result.Err)
+		}
+
+		if err := result.WriteFiles(utils.ReplaceExtension(paths[i], "")); err != nil {
+			return err
+		}
+
+		log.Printf("Rendered file: %s\n", paths[i])
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package abc
+
+import (
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"strconv"
+	"strings"
+)
+
+// ticksPerWhole is the time base of onsets, it's divisible by the common tuplets
+const ticksPerWhole = 3840
+
+// Length is a note length in whole notes.
+type Length struct {
+	Num int
+	Den int
+}
+
+var (
+	Whole   = Length{Num: 1, Den: 1}
+	Quarter = Length{Num: 1, Den: 4}
+	Eighth  = Length{Num: 1, Den: 8}
+)
+
+func (l Length) Mul(num int, den int) Length {
+	return Length{Num: l.Num * num, Den: l.Den * den}.reduce()
+}
+
+func (l Length) Div(other Length) Length {
+	return l.Mul(other.Den, other.Num)
+}
+
+func (l Length) ticks() int {
+	return ticksPerWhole * l.Num / l.Den
+}
+
+func (l Length) reduce() Length {
+	a, b := l.Num, l.Den
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a == 0 {
+		return l
+	}
+
+	return Length{Num: l.Num / a, Den: l.Den / a}
+}
+
+func (l Length) String() string {
+	return fmt.Sprintf("%d/%d", l.Num, l.Den)
+}
+
+func parseLength(s string) (Length, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 2 {
+		return Length{}, fmt.Errorf("invalid length: %s", s)
+	}
+
+	num, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Length{}, fmt.Errorf("invalid length: %s", s)
+	}
+	den, err := strconv.Atoi(parts[1])
+	if err != nil || num <= 0 || den <= 0 {
+		return Length{}, fmt.Errorf("invalid length: %s", s)
+	}
+
+	return Length{Num: num, Den: den}.reduce(), nil
+}
+
+// Position locates an element in the tune. Measure 0 is the pickup measure, Beat is 1-based
+// and counted in beats of the meter.
+type Position struct {
+	Measure int
+	Beat    float64
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("m. %d, beat %s", p.Measure, strconv.FormatFloat(p.Beat, 'f', -1, 64))
+}
+
+// Element is a note, a chord, a rest (no notes) or a bar line. Length is in whole notes.
+// Position, Onset and Scale are filled by the reader, the writer only uses Scale to write key changes.
+type Element struct {
+	Position
+	Notes  []notes.Note
+	Length Length
+	Tie    bool
+	Bar    bool
+	Onset  int
+	Scale  notes.Scale
+}
+
+type Voice struct {
+	ID       string
+	Clef     string
+	Elements []Element
+}
+
+type Tune struct {
+	Index      int
+	Title      string
+	Meter      string
+	UnitLength Length
+	Scale      notes.Scale
+	Voices     []Voice
+}
+
+// letterFifths is the position of the major key of every letter in the circle of fifths
+var letterFifths = map[string]int{"f": -1, "c": 0, "g": 1, "d": 2, "a": 3, "e": 4, "b": 5}
+
+// modeFifths moves the key signature of the major key of a tonic to the signature of the mode
+var modeFifths = map[string]int{
+	"":      0,
+	"maj":   0,
+	"ion":   0,
+	"m":     -3,
+	"min":   -3,
+	"aeo":   -3,
+	"mix":   -1,
+	"dor":   -2,
+	"phr":   -4,
+	"lyd":   1,
+	"loc":   -5,
+	"major": 0,
+	"minor": -3,
+}
+
+// parseKey parses the key of a K: field, e.g. "G", "F#m", "Bb", "D dor" or "none". Modes other
+// than major and minor are returned as the major scale with the same key signature.
+func parseKey(key string, mode string) (notes.Scale, error) {
+	if key == "" || strings.EqualFold(key, "none") || key == "HP" || key == "Hp" {
+		return notes.CMajorScale, nil
+	}
+
+	letter := strings.ToLower(key[:1])
+	fifths, ok := letterFifths[letter]
+	if !ok {
+		return notes.Scale{}, fmt.Errorf("invalid key: %s", key)
+	}
+
+	rest := key[1:]
+	if strings.HasPrefix(rest, "#") {
+		fifths += 7
+		rest = rest[1:]
+	} else if strings.HasPrefix(rest, "b") {
+		fifths -= 7
+		rest = rest[1:]
+	}
+
+	if rest == "" {
+		rest = mode
+	}
+	rest = strings.ToLower(rest)
+	if len(rest) > 3 && rest != "major" && rest != "minor" {
+		rest = rest[:3]
+	}
+
+	offset, ok := modeFifths[rest]
+	if !ok {
+		return notes.Scale{}, fmt.Errorf("invalid mode in key: %s", key)
+	}
+
+	minor := offset == -3
+	scale, err := notes.ScaleForKeySignature(fifths+offset, minor)
+	if err != nil {
+		return notes.Scale{}, fmt.Errorf("invalid key %s: %v", key, err)
+	}
+
+	return scale, nil
+}
+
+// keyName returns the scale as the key of a K: field, e.g. "F#m".
+func keyName(scale notes.Scale) string {
+	words := strings.Fields(scale.Name)
+	if len(words) == 0 {
+		return "C"
+	}
+
+	name := strings.ToUpper(words[0])
+	for _, word := range words[1:] {
+		switch word {
+		case "sharp":
+			name += "#"
+		case "flat":
+			name += "b"
+		}
+	}
+
+	if scale.Mode == notes.ScaleModeMinorHarmonic {
+		name += "m"
+	}
+
+	return name
+}
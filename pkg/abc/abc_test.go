@@ -0,0 +1,150 @@
+package abc
+
+import (
+	"bytes"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const testTune = `X:3
+T:The Test Reel
+T:alternative title
+M:3/4
+L:1/8
+K:D clef=treble % comment
+G | "D"A2 ^c>d ef- | f2 (3gfe [DFA]2 |
+!trill!B,2 z2 c2 |]
+`
+
+func TestReadTune(t *testing.T) {
+	tunes, err := Read(strings.NewReader(testTune))
+	assert.NoError(t, err)
+	assert.Len(t, tunes, 1)
+
+	tune := tunes[0]
+	assert.Equal(t, 3, tune.Index)
+	assert.Equal(t, "The Test Reel", tune.Title)
+	assert.Equal(t, "d major", tune.Scale.Name)
+	assert.Equal(t, Eighth, tune.UnitLength)
+	assert.Len(t, tune.Voices, 1)
+
+	var names []string
+	var elements []Element
+	for _, element := range tune.Voices[0].Elements {
+		if element.Bar {
+			continue
+		}
+		elements = append(elements, element)
+		var noteNames []string
+		for _, n := range element.Notes {
+			noteNames = append(noteNames, n.ScientificName())
+		}
+		names = append(names, strings.Join(noteNames, ","))
+	}
+	assert.Equal(t, []string{"G4", "A4", "C♯5", "D5", "E5", "F♯5", "F♯5", "G5", "F♯5", "E5", "D4,F♯4,A4", "B3", "", "C♯5"}, names)
+
+	assert.Equal(t, 0, elements[0].Measure, "pickup measure")
+	assert.Equal(t, 1, elements[1].Measure)
+	assert.Equal(t, Length{Num: 3, Den: 16}, elements[2].Length)
+	assert.Equal(t, Length{Num: 1, Den: 16}, elements[3].Length)
+	assert.Equal(t, 2.75, elements[3].Beat)
+	assert.True(t, elements[5].Tie)
+	assert.Equal(t, Length{Num: 1, Den: 12}, elements[7].Length)
+	assert.Equal(t, Quarter, elements[10].Length)
+	assert.Equal(t, "m. 2, beat 3", elements[10].Position.String())
+	assert.Equal(t, 3, elements[11].Measure)
+
+	sonorities := Sonorities(tune)
+	assert.Len(t, sonorities, 12, "rests and tied continuations aren't attacks")
+	chord, ok := sonorities[9].Chord()
+	assert.True(t, ok)
+	assert.Equal(t, "D maj", chord.Name())
+
+	intervals := MelodicIntervals(tune)
+	assert.Len(t, intervals, 11)
+	assert.Equal(t, "Major second", intervals[0].Interval.Name())
+	assert.True(t, intervals[0].Ascending)
+	assert.Equal(t, "Minor second", intervals[2].Interval.Name())
+	assert.Equal(t, "Minor second", intervals[5].Interval.Name(), "F♯ tied over isn't a new attack")
+	assert.Equal(t, "m. 1, beat 3.5", intervals[5].Position.String())
+}
+
+func TestParseKey(t *testing.T) {
+	for key, expected := range map[string]string{
+		"C":     "c major",
+		"Am":    "a minor",
+		"Bb":    "b flat major",
+		"F#m":   "f sharp minor",
+		"Emin":  "e minor",
+		"D dor": "c major",
+		"A mix": "d major",
+		"none":  "c major",
+	} {
+		fields := strings.Fields(key)
+		mode := ""
+		if len(fields) > 1 {
+			mode = fields[1]
+		}
+		scale, err := parseKey(fields[0], mode)
+		assert.NoError(t, err, key)
+		assert.Equal(t, expected, scale.Name, key)
+	}
+
+	_, err := parseKey("H", "")
+	assert.Error(t, err)
+}
+
+func TestReadErrors(t *testing.T) {
+	for input, expected := range map[string]string{
+		"X:1\nK:C\n[K:\n":                   "line 3: unterminated inline field",
+		"X:1\nK:C\nC [K:\n":                 "line 3: unterminated inline field",
+		"X:1\nK:C\n[CE\n":                   "line 3: unterminated chord",
+		"X:1\nK:C\nC/0|\n":                  "line 3: invalid length /0",
+		"X:1\nK:C\nC0|\n":                   "line 3: invalid length 0",
+		"X:1\nK:C\n[CE]/0\n":                "line 3: invalid length /0",
+		"X:1\nK:C\nz/256/256/256\n":         "line 3: invalid length",
+		"X:1\nK:C\nC99999999999999999999\n": "line 3: invalid number",
+		"X:1\nK:C\n(3:0CDE\n":               "line 3: invalid tuplet",
+	} {
+		_, err := Read(strings.NewReader(input))
+		if assert.Error(t, err, input) {
+			assert.Contains(t, err.Error(), expected, input)
+		}
+	}
+
+	tunes, err := Read(strings.NewReader("X:1\nK:C\nC [K:G] F\n"))
+	assert.NoError(t, err)
+	elements := tunes[0].Voices[0].Elements
+	assert.Equal(t, "c major", elements[0].Scale.Name)
+	assert.Equal(t, "g major", elements[len(elements)-1].Scale.Name)
+}
+
+func TestWriteAndRead(t *testing.T) {
+	c, _ := notes.ParseNote("C4")
+	fis, _ := notes.ParseNote("F#4")
+	a, _ := notes.ParseNote("A3")
+	bes, _ := notes.ParseNote("Bb5")
+
+	buf := bytes.Buffer{}
+	opts := DefaultOptions
+	opts.Title = "Exercise"
+	assert.NoError(t, Write(&buf, NewTune(notes.GMajorScale, [][]notes.Note{{c, fis, a}, {bes, a}}, opts)))
+
+	out := buf.String()
+	assert.Contains(t, out, "T:Exercise\n")
+	assert.Contains(t, out, "K:G\n")
+	assert.Contains(t, out, "V:2 clef=bass\n")
+	assert.Contains(t, out, "[CF]4 | _b4 |")
+	assert.Contains(t, out, "A,4 | A,4 |")
+
+	tunes, err := Read(&buf)
+	assert.NoError(t, err)
+	sonorities := Sonorities(tunes[0])
+	assert.Len(t, sonorities, 2)
+	assert.Len(t, sonorities[0].Notes, 3)
+	assert.Equal(t, "F♯4", sonorities[0].Notes[1].ScientificName())
+	assert.Equal(t, "B♭5", sonorities[1].Notes[0].ScientificName())
+	assert.Equal(t, 2, sonorities[1].Measure)
+}
@@ -0,0 +1,814 @@
+package abc
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// decorations are single characters placed before notes that don't change pitch nor length
+const decorations = ".~HLMOPSTuvy`"
+
+// maxLengthFactor bounds numerators and denominators of note lengths, so lengths can't overflow
+const maxLengthFactor = 1 << 16
+
+// Read parses all tunes of an ABC file. Only the notes are read: chord symbols, annotations,
+// decorations, grace notes and lyrics are skipped.
+func Read(r io.Reader) ([]Tune, error) {
+	var tunes []Tune
+	var lines []string
+	flush := func() error {
+		if len(lines) == 0 {
+			return nil
+		}
+		tune, err := parseTune(lines)
+		if err != nil {
+			return err
+		}
+		tunes = append(tunes, tune)
+		lines = nil
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "X:") {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read abc: %v", err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	if len(tunes) == 0 {
+		return nil, fmt.Errorf("no tunes found")
+	}
+
+	return tunes, nil
+}
+
+func ReadFile(path string) ([]Tune, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open abc file %s: %v", path, err)
+	}
+	defer f.Close()
+
+	tunes, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read abc file %s: %v", path, err)
+	}
+
+	return tunes, nil
+}
+
+// voiceState is the parsing state of a single voice
+type voiceState struct {
+	voice          *Voice
+	scale          notes.Scale
+	onset          int
+	barStart       int
+	measure        int
+	barAccidentals map[string]notes.NoteModifier
+	brokenFactor   Length
+	tupletFactor   Length
+	tupletNotes    int
+}
+
+type tuneParser struct {
+	tune         Tune
+	inBody       bool
+	measureTicks int
+	beatTicks    int
+	voices       map[string]*voiceState
+	current      *voiceState
+}
+
+func parseTune(lines []string) (Tune, error) {
+	p := tuneParser{
+		tune:      Tune{Index: 1, UnitLength: Eighth, Scale: notes.CMajorScale},
+		voices:    map[string]*voiceState{},
+		beatTicks: Quarter.ticks(),
+	}
+	unitLengthSet := false
+
+	for lineIdx, line := range lines {
+		if idx := strings.Index(line, "%"); idx != -1 && (idx == 0 || line[idx-1] != '\\') {
+			line = line[:idx]
+		}
+		line = strings.TrimRight(line, " \t\\")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if len(line) >= 2 && line[1] == ':' && isFieldLetter(line[0]) {
+			value := strings.TrimSpace(line[2:])
+			switch line[0] {
+			case 'X':
+				index, err := strconv.Atoi(value)
+				if err == nil {
+					p.tune.Index = index
+				}
+			case 'T':
+				if p.tune.Title == "" {
+					p.tune.Title = value
+				}
+			case 'M':
+				if err := p.setMeter(value, !unitLengthSet); err != nil {
+					return Tune{}, fmt.Errorf("line %d: %v", lineIdx+1, err)
+				}
+			case 'L':
+				unitLength, err := parseLength(value)
+				if err != nil {
+					return Tune{}, fmt.Errorf("line %d: %v", lineIdx+1, err)
+				}
+				p.tune.UnitLength = unitLength
+				unitLengthSet = true
+			case 'K':
+				if err := p.setKey(value); err != nil {
+					return Tune{}, fmt.Errorf("line %d: %v", lineIdx+1, err)
+				}
+				p.inBody = true
+			case 'V':
+				p.selectVoice(value)
+			}
+			continue
+		}
+
+		if !p.inBody {
+			continue
+		}
+
+		if err := p.parseBody(line); err != nil {
+			return Tune{}, fmt.Errorf("line %d: %v", lineIdx+1, err)
+		}
+	}
+
+	for _, voice := range p.tune.Voices {
+		markPickup(voice.Elements, p.measureTicks)
+	}
+
+	return p.tune, nil
+}
+
+func isFieldLetter(c byte) bool {
+	return (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
+
+// setMeter sets the meter, the default unit length depends on it until L: is given
+func (p *tuneParser) setMeter(meter string, setUnitLength bool) error {
+	p.tune.Meter = meter
+	num, den := 0, 4
+	switch meter {
+	case "C":
+		num, den = 4, 4
+	case "C|":
+		num, den = 2, 2
+	case "", "none":
+		p.measureTicks = 0
+		p.beatTicks = Quarter.ticks()
+		return nil
+	default:
+		parts := strings.Split(meter, "/")
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid meter: %s", meter)
+		}
+		for _, n := range strings.Split(strings.Trim(parts[0], "()"), "+") {
+			v, err := strconv.Atoi(strings.TrimSpace(n))
+			if err != nil {
+				return fmt.Errorf("invalid meter: %s", meter)
+			}
+			num += v
+		}
+		d, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || d <= 0 || num <= 0 {
+			return fmt.Errorf("invalid meter: %s", meter)
+		}
+		den = d
+	}
+
+	p.beatTicks = Length{Num: 1, Den: den}.ticks()
+	p.measureTicks = num * p.beatTicks
+	if setUnitLength {
+		// meters below 3/4 default to sixteenths, see the ABC standard
+		if float64(num)/float64(den) < 0.75 {
+			p.tune.UnitLength = Length{Num: 1, Den: 16}
+		} else {
+			p.tune.UnitLength = Eighth
+		}
+	}
+
+	return nil
+}
+
+// setKey handles a K: field, e.g. "G", "Am clef=bass" or "D dor". Before the body it sets the key of the tune.
+func (p *tuneParser) setKey(value string) error {
+	fields := strings.Fields(value)
+	key, mode := "", ""
+	clef := ""
+	for i, field := range fields {
+		switch {
+		case strings.HasPrefix(field, "clef="):
+			clef = strings.TrimPrefix(field, "clef=")
+		case field == "bass" || field == "treble":
+			clef = field
+		case i == 0 && !strings.Contains(field, "="):
+			key = field
+		case i == 1 && !strings.Contains(field, "=") && mode == "":
+			mode = field
+		}
+	}
+
+	scale, err := parseKey(key, mode)
+	if err != nil {
+		return err
+	}
+
+	if !p.inBody {
+		p.tune.Scale = scale
+		for _, state := range p.voices {
+			state.scale = scale
+		}
+	}
+	if p.current == nil {
+		p.selectVoice("1")
+	}
+	p.current.scale = scale
+	if clef != "" {
+		p.current.voice.Clef = clef
+	}
+
+	return nil
+}
+
+// selectVoice switches to the voice from a V: field, e.g. "2 clef=bass name=Left"
+func (p *tuneParser) selectVoice(value string) {
+	fields := strings.Fields(value)
+	id := "1"
+	if len(fields) > 0 {
+		id = fields[0]
+	}
+
+	state, ok := p.voices[id]
+	if !ok {
+		p.tune.Voices = append(p.tune.Voices, Voice{ID: id, Clef: "treble"})
+		state = &voiceState{scale: p.tune.Scale, measure: 1, barAccidentals: map[string]notes.NoteModifier{}}
+		p.voices[id] = state
+	}
+	// voices are kept in a slice, so the pointer is refreshed after every append
+	for i := range p.tune.Voices {
+		p.voices[p.tune.Voices[i].ID].voice = &p.tune.Voices[i]
+	}
+
+	for i := 1; i < len(fields); i++ {
+		switch {
+		case strings.HasPrefix(fields[i], "clef="):
+			state.voice.Clef = strings.TrimPrefix(fields[i], "clef=")
+		case fields[i] == "bass" || fields[i] == "treble":
+			state.voice.Clef = fields[i]
+		}
+	}
+
+	p.current = state
+}
+
+func (p *tuneParser) parseBody(line string) error {
+	if p.current == nil {
+		p.selectVoice("1")
+	}
+
+	for pos := 0; pos < len(line); {
+		c := line[pos]
+		switch {
+		case c == ' ' || c == '\t' || c == ')' || strings.IndexByte(decorations, c) != -1:
+			pos++
+		case c == '"':
+			pos = skipUntil(line, pos+1, '"')
+		case c == '!':
+			pos = skipUntil(line, pos+1, '!')
+		case c == '+':
+			pos = skipUntil(line, pos+1, '+')
+		case c == '{':
+			pos = skipUntil(line, pos+1, '}')
+		case c == '-':
+			p.markTie()
+			pos++
+		case c == '>' || c == '<':
+			pos = p.brokenRhythm(line, pos)
+		case c == '(':
+			next, err := p.tuplet(line, pos)
+			if err != nil {
+				return err
+			}
+			pos = next
+		case c == '|' || c == ':' && pos+1 < len(line) && (line[pos+1] == '|' || line[pos+1] == ':'):
+			pos = p.bar(line, pos)
+		case c == '[' && pos+1 < len(line) && line[pos+1] == '|':
+			pos = p.bar(line, pos)
+		case c == '[' && pos+1 < len(line) && line[pos+1] >= '0' && line[pos+1] <= '9':
+			// first and second endings
+			pos++
+			for pos < len(line) && strings.IndexByte("0123456789,-", line[pos]) != -1 {
+				pos++
+			}
+		case c == '[' && pos+2 < len(line) && line[pos+2] == ':':
+			end := strings.IndexByte(line[pos+1:], ']')
+			if end == -1 {
+				return fmt.Errorf("unterminated inline field at column %d", pos+1)
+			}
+			end += pos + 1
+			if err := p.inlineField(line[pos+1 : end]); err != nil {
+				return err
+			}
+			pos = end + 1
+		case c == '[':
+			next, err := p.chord(line, pos+1)
+			if err != nil {
+				return err
+			}
+			pos = next
+		case c == 'z' || c == 'x':
+			length, next, err := p.readLength(line, pos+1)
+			if err != nil {
+				return err
+			}
+			p.addElement(nil, length)
+			pos = next
+		case c == 'Z' || c == 'X':
+			count, next, err := readNumber(line, pos+1, 1)
+			if err != nil {
+				return err
+			}
+			if p.measureTicks > 0 {
+				p.addElement(nil, Length{Num: count * p.measureTicks, Den: ticksPerWhole}.reduce())
+				p.current.measure += count - 1
+			}
+			pos = next
+		case strings.IndexByte("^_=ABCDEFGabcdefg", c) != -1:
+			n, next, err := p.note(line, pos)
+			if err != nil {
+				return err
+			}
+			length, next, err := p.readLength(line, next)
+			if err != nil {
+				return err
+			}
+			p.addElement([]notes.Note{n}, length)
+			pos = next
+		default:
+			return fmt.Errorf("unexpected character %q at column %d", c, pos+1)
+		}
+	}
+
+	return nil
+}
+
+func skipUntil(line string, pos int, end byte) int {
+	idx := strings.IndexByte(line[pos:], end)
+	if idx == -1 {
+		return len(line)
+	}
+
+	return pos + idx + 1
+}
+
+func readNumber(line string, pos int, defaultValue int) (int, int, error) {
+	start := pos
+	for pos < len(line) && line[pos] >= '0' && line[pos] <= '9' {
+		pos++
+	}
+	if start == pos {
+		return defaultValue, pos, nil
+	}
+
+	value, err := strconv.Atoi(line[start:pos])
+	if err != nil {
+		return 0, pos, fmt.Errorf("invalid number %s at column %d", line[start:pos], start+1)
+	}
+	return value, pos, nil
+}
+
+// readLength reads a length multiplier like "2", "/", "//", "3/2" and returns the length in whole notes
+func (p *tuneParser) readLength(line string, pos int) (Length, int, error) {
+	start := pos
+	num, pos, err := readNumber(line, pos, 1)
+	if err != nil {
+		return Length{}, pos, err
+	}
+	den := 1
+	for pos < len(line) && line[pos] == '/' {
+		pos++
+		var d int
+		d, pos, err = readNumber(line, pos, 2)
+		if err != nil {
+			return Length{}, pos, err
+		}
+		if d <= 0 || d > maxLengthFactor/den {
+			return Length{}, pos, fmt.Errorf("invalid length %s at column %d", line[start:pos], start+1)
+		}
+		den *= d
+	}
+	if num <= 0 || num > maxLengthFactor {
+		return Length{}, pos, fmt.Errorf("invalid length %s at column %d", line[start:pos], start+1)
+	}
+
+	return p.tune.UnitLength.Mul(num, den), pos, nil
+}
+
+// note reads an accidental, letter and octave marks starting at pos
+func (p *tuneParser) note(line string, pos int) (notes.Note, int, error) {
+	modifier, explicit := notes.NoteModifierNone, false
+	for pos < len(line) && strings.IndexByte("^_=", line[pos]) != -1 {
+		if explicit && line[pos] != '=' {
+			return notes.Note{}, pos, fmt.Errorf("double accidentals aren't supported at column %d", pos+1)
+		}
+		switch line[pos] {
+		case '^':
+			modifier = notes.NoteModifierSharp
+		case '_':
+			modifier = notes.NoteModifierFlat
+		}
+		explicit = true
+		pos++
+	}
+
+	if pos >= len(line) || strings.IndexByte("ABCDEFGabcdefg", line[pos]) == -1 {
+		return notes.Note{}, pos, fmt.Errorf("expected note at column %d", pos+1)
+	}
+
+	letter := line[pos : pos+1]
+	octave := 4
+	if letter == strings.ToLower(letter) {
+		octave = 5
+	}
+	pos++
+	for pos < len(line) && (line[pos] == '\'' || line[pos] == ',') {
+		if line[pos] == '\'' {
+			octave++
+		} else {
+			octave--
+		}
+		pos++
+	}
+
+	baseName := strings.ToLower(letter)
+	// accidentals apply to the same note until the end of the measure
+	accidentalKey := fmt.Sprintf("%s%d", baseName, octave)
+	if explicit {
+		p.current.barAccidentals[accidentalKey] = modifier
+	} else if barModifier, ok := p.current.barAccidentals[accidentalKey]; ok {
+		modifier = barModifier
+	} else {
+		modifier = notes.KeySignatureModifier(p.current.scale.KeySignature(), baseName)
+	}
+
+	return notes.NewNote(baseName, modifier, octave), pos, nil
+}
+
+// chord reads notes up to the closing bracket, the chord gets the length of its first note
+func (p *tuneParser) chord(line string, pos int) (int, error) {
+	var chordNotes []notes.Note
+	var chordLength Length
+	for pos < len(line) && line[pos] != ']' {
+		if line[pos] == ' ' || line[pos] == '-' {
+			pos++
+			continue
+		}
+		n, next, err := p.note(line, pos)
+		if err != nil {
+			return pos, err
+		}
+		length, next, err := p.readLength(line, next)
+		if err != nil {
+			return pos, err
+		}
+		if len(chordNotes) == 0 {
+			chordLength = length
+		}
+		chordNotes = append(chordNotes, n)
+		pos = next
+	}
+	if pos >= len(line) {
+		return pos, fmt.Errorf("unterminated chord")
+	}
+
+	multiplier, next, err := p.readLength(line, pos+1)
+	if err != nil {
+		return pos, err
+	}
+	p.addElement(chordNotes, chordLength.Mul(multiplier.Num*p.tune.UnitLength.Den, multiplier.Den*p.tune.UnitLength.Num))
+	return next, nil
+}
+
+func (p *tuneParser) inlineField(field string) error {
+	value := strings.TrimSpace(field[2:])
+	switch field[0] {
+	case 'K':
+		return p.setKey(value)
+	case 'M':
+		return p.setMeter(value, false)
+	case 'L':
+		unitLength, err := parseLength(value)
+		if err != nil {
+			return err
+		}
+		p.tune.UnitLength = unitLength
+	case 'V':
+		p.selectVoice(value)
+	}
+
+	return nil
+}
+
+func (p *tuneParser) markTie() {
+	elements := p.current.voice.Elements
+	if len(elements) > 0 && !elements[len(elements)-1].Bar {
+		elements[len(elements)-1].Tie = true
+	}
+}
+
+// brokenRhythm handles "A>B" (dotted first note) and "A<B", doubled signs make it double dotted
+func (p *tuneParser) brokenRhythm(line string, pos int) int {
+	sign := line[pos]
+	count := 0
+	for pos < len(line) && line[pos] == sign {
+		count++
+		pos++
+	}
+
+	elements := p.current.voice.Elements
+	if len(elements) == 0 || elements[len(elements)-1].Bar {
+		return pos
+	}
+
+	short := Length{Num: 1, Den: 1 << uint(count)}
+	long := Length{Num: 2<<uint(count) - 1, Den: 1 << uint(count)}
+	if sign == '<' {
+		short, long = long, short
+	}
+
+	last := &elements[len(elements)-1]
+	p.current.onset -= last.Length.ticks()
+	last.Length = last.Length.Mul(long.Num, long.Den)
+	p.current.onset += last.Length.ticks()
+	p.current.brokenFactor = short
+
+	return pos
+}
+
+// tuplet handles "(p", "(p:q" and "(p:q:r"
+func (p *tuneParser) tuplet(line string, pos int) (int, error) {
+	if pos+1 >= len(line) || line[pos+1] < '2' || line[pos+1] > '9' {
+		// a slur
+		return pos + 1, nil
+	}
+
+	start := pos
+	num, pos, err := readNumber(line, pos+1, 3)
+	if err != nil {
+		return pos, err
+	}
+	defaultInTimeOf := map[int]int{2: 3, 3: 2, 4: 3, 6: 2, 8: 3}
+	inTimeOf, ok := defaultInTimeOf[num]
+	if !ok {
+		inTimeOf = 2
+	}
+	count := num
+
+	if pos < len(line) && line[pos] == ':' {
+		if inTimeOf, pos, err = readNumber(line, pos+1, inTimeOf); err != nil {
+			return pos, err
+		}
+		if pos < len(line) && line[pos] == ':' {
+			if count, pos, err = readNumber(line, pos+1, num); err != nil {
+				return pos, err
+			}
+		}
+	}
+	if num > maxLengthFactor || inTimeOf <= 0 || inTimeOf > maxLengthFactor {
+		return pos, fmt.Errorf("invalid tuplet %s at column %d", line[start:pos], start+1)
+	}
+
+	p.current.tupletFactor = Length{Num: inTimeOf, Den: num}
+	p.current.tupletNotes = count
+	return pos, nil
+}
+
+func (p *tuneParser) bar(line string, pos int) int {
+	for pos < len(line) && strings.IndexByte("|:[]", line[pos]) != -1 {
+		pos++
+	}
+	for pos < len(line) && strings.IndexByte("0123456789,-", line[pos]) != -1 {
+		pos++
+	}
+
+	state := p.current
+	state.voice.Elements = append(state.voice.Elements, Element{
+		Bar:   true,
+		Onset: state.onset,
+		Scale: state.scale,
+		Position: Position{
+			Measure: state.measure,
+			Beat:    1 + float64(state.onset-state.barStart)/float64(p.beatTicks),
+		},
+	})
+	// bar lines at the very beginning of the voice don't start a new measure
+	if state.onset > 0 {
+		state.measure++
+	}
+	state.barStart = state.onset
+	state.barAccidentals = map[string]notes.NoteModifier{}
+
+	return pos
+}
+
+func (p *tuneParser) addElement(elementNotes []notes.Note, length Length) {
+	state := p.current
+	if state.brokenFactor.Den != 0 {
+		length = length.Mul(state.brokenFactor.Num, state.brokenFactor.Den)
+		state.brokenFactor = Length{}
+	}
+	if state.tupletNotes > 0 {
+		length = length.Mul(state.tupletFactor.Num, state.tupletFactor.Den)
+		state.tupletNotes--
+	}
+
+	state.voice.Elements = append(state.voice.Elements, Element{
+		Notes:  elementNotes,
+		Length: length,
+		Onset:  state.onset,
+		Scale:  state.scale,
+		Position: Position{
+			Measure: state.measure,
+			Beat:    1 + float64(state.onset-state.barStart)/float64(p.beatTicks),
+		},
+	})
+	state.onset += length.ticks()
+}
+
+// markPickup renumbers measures from 0 when the first measure is shorter than the meter
+func markPickup(elements []Element, measureTicks int) {
+	if measureTicks == 0 {
+		return
+	}
+
+	for _, element := range elements {
+		if element.Bar && element.Onset > 0 {
+			if element.Onset >= measureTicks {
+				return
+			}
+			break
+		}
+	}
+
+	for i := range elements {
+		if elements[i].Bar && elements[i].Onset == 0 {
+			continue
+		}
+		elements[i].Measure--
+	}
+}
+
+// Sonority is a group of notes attacked at the same time in any voice.
+type Sonority struct {
+	Position
+	Notes []notes.Note
+	Scale notes.Scale
+}
+
+func (s Sonority) Chord() (notes.Chord, bool) {
+	return notes.AnalyzeChord(s.Notes, s.Scale)
+}
+
+// MelodicInterval is an interval between consecutive notes of a voice. Interval.FirstNote is always the lower note.
+type MelodicInterval struct {
+	Position
+	Voice     string
+	Interval  notes.Interval
+	Ascending bool
+}
+
+// Sonorities groups notes attacked at the same onset in all voices, tied continuations aren't new attacks.
+func Sonorities(tune Tune) []Sonority {
+	type attack struct {
+		element Element
+		notes   []notes.Note
+	}
+
+	var attacks []attack
+	for _, voice := range tune.Voices {
+		var previous *Element
+		for i, element := range voice.Elements {
+			if element.Bar {
+				continue
+			}
+			attacked := attackedNotes(element, previous)
+			previous = &voice.Elements[i]
+			if len(attacked) > 0 {
+				attacks = append(attacks, attack{element: element, notes: attacked})
+			}
+		}
+	}
+
+	sort.SliceStable(attacks, func(i int, j int) bool {
+		return attacks[i].element.Onset < attacks[j].element.Onset
+	})
+
+	var sonorities []Sonority
+	for i := 0; i < len(attacks); {
+		sonority := Sonority{Position: attacks[i].element.Position, Scale: attacks[i].element.Scale}
+		seen := map[string]bool{}
+		j := i
+		for ; j < len(attacks) && attacks[j].element.Onset == attacks[i].element.Onset; j++ {
+			for _, n := range attacks[j].notes {
+				if key := n.ScientificName(); !seen[key] {
+					seen[key] = true
+					sonority.Notes = append(sonority.Notes, n)
+				}
+			}
+		}
+		sonorities = append(sonorities, sonority)
+		i = j
+	}
+
+	return sonorities
+}
+
+// MelodicIntervals returns intervals between consecutive attacks of every voice.
+// Only the highest note of chords is taken into account.
+func MelodicIntervals(tune Tune) []MelodicInterval {
+	var intervals []MelodicInterval
+	for _, voice := range tune.Voices {
+		var previous *Element
+		var last *Element
+		var lastNote notes.Note
+		for i, element := range voice.Elements {
+			if element.Bar {
+				continue
+			}
+			attacked := attackedNotes(element, previous)
+			previous = &voice.Elements[i]
+			if len(attacked) == 0 {
+				continue
+			}
+
+			highest := attacked[0]
+			for _, n := range attacked[1:] {
+				if n.ToneIndex() > highest.ToneIndex() {
+					highest = n
+				}
+			}
+
+			if last != nil {
+				first, second := lastNote, highest
+				ascending := second.ToneIndex() >= first.ToneIndex()
+				if !ascending {
+					first, second = second, first
+				}
+				intervals = append(intervals, MelodicInterval{
+					Position:  last.Position,
+					Voice:     voice.ID,
+					Interval:  notes.Interval{FirstNote: first, SecondNote: second, Scale: last.Scale},
+					Ascending: ascending,
+				})
+			}
+			last, lastNote = &voice.Elements[i], highest
+		}
+	}
+
+	return intervals
+}
+
+// attackedNotes returns notes of the element that aren't tied from the same note of the previous element
+func attackedNotes(element Element, previous *Element) []notes.Note {
+	if previous == nil || !previous.Tie {
+		return element.Notes
+	}
+
+	var attacked []notes.Note
+	for _, n := range element.Notes {
+		tied := false
+		for _, p := range previous.Notes {
+			if p.ToneIndex() == n.ToneIndex() {
+				tied = true
+			}
+		}
+		if !tied {
+			attacked = append(attacked, n)
+		}
+	}
+
+	return attacked
+}
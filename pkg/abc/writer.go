@@ -0,0 +1,255 @@
+package abc
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// barsPerLine is the number of measures written in one line of the tune body
+const barsPerLine = 4
+
+type Options struct {
+	Title      string
+	UnitLength Length
+	GrandStaff bool
+}
+
+var DefaultOptions = Options{
+	UnitLength: Quarter,
+	GrandStaff: true,
+}
+
+// NewTune builds a tune with every sonority as a whole note in its own measure. On the grand staff
+// notes are split between a treble and a bass voice, otherwise a single voice gets the clef closer to the notes.
+func NewTune(scale notes.Scale, sonorities [][]notes.Note, opts Options) Tune {
+	tune := Tune{
+		Index:      1,
+		Title:      opts.Title,
+		Meter:      "4/4",
+		UnitLength: opts.UnitLength,
+		Scale:      scale,
+	}
+	if tune.UnitLength.Den == 0 {
+		tune.UnitLength = Quarter
+	}
+
+	if !opts.GrandStaff {
+		voice := Voice{ID: "1", Clef: "treble"}
+		sum, count := 0, 0
+		for _, sonority := range sonorities {
+			voice.Elements = append(voice.Elements, Element{Notes: sonority, Length: Whole}, Element{Bar: true})
+			for _, n := range sonority {
+				sum += n.BaseNoteIndex
+				count++
+			}
+		}
+		if count > 0 && sum/count < notes.MiddleCIndex {
+			voice.Clef = "bass"
+		}
+		tune.Voices = []Voice{voice}
+		return tune
+	}
+
+	treble := Voice{ID: "1", Clef: "treble"}
+	bass := Voice{ID: "2", Clef: "bass"}
+	for _, sonority := range sonorities {
+		var trebleNotes, bassNotes []notes.Note
+		for _, n := range sonority {
+			if n.TrebleClef && (!n.BassClef || n.BaseNoteIndex >= notes.MiddleCIndex) {
+				trebleNotes = append(trebleNotes, n)
+			} else {
+				bassNotes = append(bassNotes, n)
+			}
+		}
+		treble.Elements = append(treble.Elements, Element{Notes: trebleNotes, Length: Whole}, Element{Bar: true})
+		bass.Elements = append(bass.Elements, Element{Notes: bassNotes, Length: Whole}, Element{Bar: true})
+	}
+	tune.Voices = []Voice{treble, bass}
+
+	return tune
+}
+
+func IntervalTune(interval notes.Interval, opts Options) Tune {
+	return NewTune(interval.Scale, [][]notes.Note{{interval.FirstNote, interval.SecondNote}}, opts)
+}
+
+func ChordsTune(chords []notes.Chord, opts Options) Tune {
+	var sonorities [][]notes.Note
+	for i := 0; i < len(chords); i++ {
+		sonorities = append(sonorities, chords[i].Notes)
+	}
+
+	scale := notes.CMajorScale
+	if len(chords) > 0 {
+		scale = chords[0].Scale
+	}
+
+	return NewTune(scale, sonorities, opts)
+}
+
+func Write(w io.Writer, tune Tune) error {
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, "X:%d\n", tune.Index)
+	if tune.Title != "" {
+		fmt.Fprintf(&buf, "T:%s\n", tune.Title)
+	}
+	if tune.Meter != "" {
+		fmt.Fprintf(&buf, "M:%s\n", tune.Meter)
+	}
+	unitLength := tune.UnitLength
+	if unitLength.Den == 0 {
+		unitLength = Eighth
+	}
+	fmt.Fprintf(&buf, "L:%s\n", unitLength)
+
+	if len(tune.Voices) > 1 {
+		var ids []string
+		for _, voice := range tune.Voices {
+			ids = append(ids, voice.ID)
+		}
+		fmt.Fprintf(&buf, "%%%%score {%s}\n", strings.Join(ids, " "))
+		for _, voice := range tune.Voices {
+			fmt.Fprintf(&buf, "V:%s clef=%s\n", voice.ID, voice.Clef)
+		}
+		fmt.Fprintf(&buf, "K:%s\n", keyName(tune.Scale))
+	} else if len(tune.Voices) == 1 && tune.Voices[0].Clef != "" && tune.Voices[0].Clef != "treble" {
+		fmt.Fprintf(&buf, "K:%s clef=%s\n", keyName(tune.Scale), tune.Voices[0].Clef)
+	} else {
+		fmt.Fprintf(&buf, "K:%s\n", keyName(tune.Scale))
+	}
+
+	for _, voice := range tune.Voices {
+		if len(tune.Voices) > 1 {
+			fmt.Fprintf(&buf, "V:%s\n", voice.ID)
+		}
+		buf.WriteString(voiceBody(voice, tune.Scale, unitLength))
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write abc tune: %v", err)
+	}
+
+	return nil
+}
+
+func WriteFile(path string, tune Tune) error {
+	buf := bytes.Buffer{}
+	if err := Write(&buf, tune); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), os.FileMode(0660)); err != nil {
+		return fmt.Errorf("failed to write abc file %s: %v", path, err)
+	}
+
+	return nil
+}
+
+func voiceBody(voice Voice, scale notes.Scale, unitLength Length) string {
+	buf := bytes.Buffer{}
+	keySignature := scale.KeySignature()
+	// accidentals last until the end of the measure, keyed by the note without its modifier
+	barAccidentals := map[string]notes.NoteModifier{}
+	bars := 0
+
+	for _, element := range voice.Elements {
+		if element.Bar {
+			barAccidentals = map[string]notes.NoteModifier{}
+			bars++
+			if bars%barsPerLine == 0 {
+				buf.WriteString("|\n")
+			} else {
+				buf.WriteString("| ")
+			}
+			continue
+		}
+
+		if element.Scale.Name != "" && element.Scale.Name != scale.Name {
+			scale = element.Scale
+			keySignature = scale.KeySignature()
+			fmt.Fprintf(&buf, "[K:%s] ", keyName(scale))
+		}
+
+		length := lengthSuffix(element.Length.Div(unitLength))
+		switch len(element.Notes) {
+		case 0:
+			buf.WriteString("z" + length)
+		case 1:
+			buf.WriteString(pitch(element.Notes[0], keySignature, barAccidentals) + length)
+		default:
+			sorted := make([]notes.Note, len(element.Notes))
+			copy(sorted, element.Notes)
+			sort.SliceStable(sorted, func(i int, j int) bool {
+				return sorted[i].ToneIndex() < sorted[j].ToneIndex()
+			})
+			buf.WriteString("[")
+			for _, n := range sorted {
+				buf.WriteString(pitch(n, keySignature, barAccidentals))
+			}
+			buf.WriteString("]" + length)
+		}
+
+		if element.Tie {
+			buf.WriteString("-")
+		}
+		buf.WriteString(" ")
+	}
+
+	body := strings.TrimRight(buf.String(), " \n")
+	if body == "" {
+		return ""
+	}
+
+	return body + "\n"
+}
+
+func pitch(n notes.Note, keySignature int, barAccidentals map[string]notes.NoteModifier) string {
+	octave := n.Octave()
+	letter := strings.ToUpper(n.BaseName)
+	if octave >= 5 {
+		letter = n.BaseName + strings.Repeat("'", octave-5)
+	} else {
+		letter += strings.Repeat(",", 4-octave)
+	}
+
+	current, ok := barAccidentals[letter]
+	if !ok {
+		current = notes.KeySignatureModifier(keySignature, n.BaseName)
+	}
+	if current == n.Modifier {
+		return letter
+	}
+
+	barAccidentals[letter] = n.Modifier
+	switch n.Modifier {
+	case notes.NoteModifierSharp:
+		return "^" + letter
+	case notes.NoteModifierFlat:
+		return "_" + letter
+	default:
+		return "=" + letter
+	}
+}
+
+// lengthSuffix returns the length multiplier written after a note, e.g. "2", "/" or "3/2".
+func lengthSuffix(units Length) string {
+	switch {
+	case units.Num == units.Den:
+		return ""
+	case units.Den == 1:
+		return strconv.Itoa(units.Num)
+	case units.Num == 1 && units.Den == 2:
+		return "/"
+	case units.Num == 1:
+		return "/" + strconv.Itoa(units.Den)
+	default:
+		return fmt.Sprintf("%d/%d", units.Num, units.Den)
+	}
+}
@@ -0,0 +1,301 @@
+// Package anki writes decks as .apkg packages, ready to import into Anki with their images and sounds.
+package anki
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"hash/fnv"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fields of the note type, in order.
+var Fields = []string{"Image", "Audio", "Name", "RomanNumeral", "Scale", "IntervalSize", "Quality", "Root", "Description"}
+
+const (
+	modelName = "notes-gen"
+	// modelID stays the same, so notes of re-imported packages keep their note type, other directions than the
+	// default have their own note type
+	modelID int64 = 1583142400000
+	// sortField is the Name field shown in the browser
+	sortField = 2
+
+	// TagRoot is the top of the tag hierarchy of all generated notes
+	TagRoot = "notes-gen"
+)
+
+const frontTemplate = `<div class="notation">{{Image}}</div>`
+
+const backTemplate = `{{FrontSide}}
+
+<hr id=answer>
+
+<div class="name">{{Name}}{{#RomanNumeral}} ({{RomanNumeral}}){{/RomanNumeral}}</div>
+<div class="details">{{Scale}}{{#IntervalSize}}, {{IntervalSize}}{{/IntervalSize}}</div>
+{{Audio}}`
+
+const nameFrontTemplate = `<div class="prompt">Draw {{Description}}</div>
+<div class="details">{{Scale}}</div>`
+
+const nameBackTemplate = `{{FrontSide}}
+
+<hr id=answer>
+
+<div class="notation">{{Image}}</div>
+{{Audio}}`
+
+// attributeFrontTemplate and attributeBackTemplate are formatted with the question and the answer field
+const attributeFrontTemplate = `<div class="notation">{{Image}}</div>
+<div class="prompt">%s</div>`
+
+const attributeBackTemplate = `{{FrontSide}}
+
+<hr id=answer>
+
+<div class="name">{{%s}}</div>`
+
+const css = `.card {
+  font-family: arial;
+  font-size: 20px;
+  text-align: center;
+  color: black;
+  background-color: white;
+}
+
+.notation img {
+  max-width: 100%;
+}
+
+.details {
+  font-size: 16px;
+  color: #666;
+}
+
+.prompt {
+  margin-top: 10px;
+}
+`
+
+// Note is an item of a deck. Image and Audio are paths of the media files, stored in the package under their
+// base names, Audio is optional. Description is the item in words, asked for on the name cards. An empty GUID is
+// derived from the fields.
+type Note struct {
+	GUID         string
+	Image        string
+	Audio        string
+	Name         string
+	RomanNumeral string
+	Scale        string
+	IntervalSize string
+	Quality      string
+	Root         string
+	Description  string
+	Tags         []string
+}
+
+// Deck has a card in every direction for each note, when the note has the fields the direction asks for.
+// No directions mean the default ones.
+type Deck struct {
+	Name       string
+	Notes      []Note
+	Directions []Direction
+}
+
+// Tag joins the lower-cased parts into a hierarchical tag under TagRoot, e.g. notes-gen::scale::c_major.
+func Tag(parts ...string) string {
+	tag := TagRoot
+	for _, part := range parts {
+		tag += "::" + strings.Join(strings.Fields(strings.ToLower(part)), "_")
+	}
+	return tag
+}
+
+func clefTag(layout notes.IntervalClefLayout) string {
+	switch layout {
+	case notes.IntervalClefLayoutTreble:
+		return Tag("clef", "treble")
+	case notes.IntervalClefLayoutBass:
+		return Tag("clef", "bass")
+	default:
+		return Tag("clef", "cross")
+	}
+}
+
+// ChordNote returns the note of the chord, tagged with its scale, type and clef. Its GUID is the chord id, so
+// re-imported packages update the notes.
+func ChordNote(chord notes.Chord, image string, audio string) Note {
+	chordOnClefs := notes.ChordToChordOnClefs(chord)
+	layout := notes.IntervalClefLayoutCrossStaff
+	if len(chordOnClefs.BassClefNotes) == 0 {
+		layout = notes.IntervalClefLayoutTreble
+	} else if len(chordOnClefs.TrebleClefNotes) == 0 {
+		layout = notes.IntervalClefLayoutBass
+	}
+
+	return Note{
+		GUID:         chord.ID(),
+		Image:        image,
+		Audio:        audio,
+		Name:         chord.Name(),
+		RomanNumeral: chord.RomanNumeral(),
+		Scale:        chord.Scale.Name,
+		Quality:      chord.Type.Description(),
+		Root:         chord.RootNote.NameWithSharpFlatModifier(),
+		Description:  fmt.Sprintf("%s in %s", chord.Name(), chord.InversionName()),
+		Tags: []string{
+			Tag("scale", chord.Scale.Name),
+			Tag("chord", chord.Type.Description()),
+			clefTag(layout),
+		},
+	}
+}
+
+// IntervalNote returns the note of the interval, tagged with its scale, size and clef, with the interval id as GUID.
+func IntervalNote(interval notes.Interval, image string, audio string) Note {
+	return Note{
+		GUID:         interval.ID(),
+		Image:        image,
+		Audio:        audio,
+		Name:         interval.Name(),
+		Scale:        interval.Scale.Name,
+		IntervalSize: interval.Size(),
+		Quality:      interval.Quality(),
+		Description:  fmt.Sprintf("a %s above %s", strings.ToLower(interval.Name()), interval.FirstNote.ScientificName()),
+		Tags: []string{
+			Tag("scale", interval.Scale.Name),
+			Tag("interval", interval.Name()),
+			clefTag(interval.ClefLayout()),
+		},
+	}
+}
+
+func (n Note) guid(fields []string) string {
+	if n.GUID != "" {
+		return n.GUID
+	}
+	sum := sha1.Sum([]byte(strings.Join(fields, "\x1f")))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+func (d Deck) directions() []Direction {
+	if len(d.Directions) == 0 {
+		return DefaultDirections
+	}
+	return d.Directions
+}
+
+// id is derived from the name, so re-imported packages update the same deck
+func (d Deck) id() int64 {
+	h := fnv.New64a()
+	h.Write([]byte(d.Name))
+	return 1<<40 + int64(h.Sum64()%(1<<40))
+}
+
+// WritePackage writes the deck with all media files to an .apkg package.
+func (d Deck) WritePackage(path string) error {
+	data, err := d.pack(time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, data, 0660); err != nil {
+		return fmt.Errorf("failed to write package %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// pack returns the zipped package: the collection, the media manifest mapping the numbered media entries to
+// their file names and the media files.
+func (d Deck) pack(now time.Time) ([]byte, error) {
+	var media []string
+	names := map[string]string{}
+	addMedia := func(path string) (string, error) {
+		name := filepath.Base(path)
+		if previous, ok := names[name]; ok && previous != path {
+			return "", fmt.Errorf("media files %s and %s have the same name", previous, path)
+		} else if !ok {
+			names[name] = path
+			media = append(media, path)
+		}
+		return name, nil
+	}
+
+	var fields [][]string
+	for _, note := range d.Notes {
+		if note.Image == "" {
+			return nil, fmt.Errorf("note %s has no image", note.Name)
+		}
+		image, err := addMedia(note.Image)
+		if err != nil {
+			return nil, err
+		}
+
+		audio := ""
+		if note.Audio != "" {
+			name, err := addMedia(note.Audio)
+			if err != nil {
+				return nil, err
+			}
+			audio = fmt.Sprintf("[sound:%s]", name)
+		}
+
+		fields = append(fields, []string{fmt.Sprintf(`<img src="%s">`, image), audio, note.Name, note.RomanNumeral, note.Scale, note.IntervalSize, note.Quality, note.Root, note.Description})
+	}
+
+	db, err := collection(d, fields, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create collection: %v", err)
+	}
+
+	manifest := map[string]string{}
+	for i, path := range media {
+		manifest[strconv.Itoa(i)] = filepath.Base(path)
+	}
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode media manifest: %v", err)
+	}
+
+	buf := bytes.Buffer{}
+	archive := zip.NewWriter(&buf)
+	writeEntry := func(name string, data []byte) error {
+		w, err := archive.Create(name)
+		if err != nil {
+			return fmt.Errorf("failed to add %s to package: %v", name, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("failed to add %s to package: %v", name, err)
+		}
+		return nil
+	}
+
+	if err := writeEntry("collection.anki2", db); err != nil {
+		return nil, err
+	}
+	if err := writeEntry("media", manifestData); err != nil {
+		return nil, err
+	}
+	for i, path := range media {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read media file: %v", err)
+		}
+		if err := writeEntry(strconv.Itoa(i), data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write package: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,440 @@
+package anki
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readVarint(data []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		v = v<<7 | uint64(data[i]&0x7F)
+		if data[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v<<8 | uint64(data[8]), 9
+}
+
+func decodeRecord(t *testing.T, record []byte) []interface{} {
+	headerSize, n := readVarint(record)
+	var types []uint64
+	for n < int(headerSize) {
+		serialType, size := readVarint(record[n:])
+		types = append(types, serialType)
+		n += size
+	}
+
+	var values []interface{}
+	body := record[headerSize:]
+	for _, serialType := range types {
+		switch {
+		case serialType == 0:
+			values = append(values, nil)
+		case serialType == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(body)))
+			body = body[8:]
+		case serialType == 8 || serialType == 9:
+			values = append(values, int64(serialType-8))
+		case serialType < 7:
+			size := []int{0, 1, 2, 3, 4, 6, 8}[serialType]
+			v := int64(int8(body[0]))
+			for _, b := range body[1:size] {
+				v = v<<8 | int64(b)
+			}
+			values = append(values, v)
+			body = body[size:]
+		case serialType%2 == 1:
+			size := int(serialType-13) / 2
+			values = append(values, string(body[:size]))
+			body = body[size:]
+		default:
+			size := int(serialType-12) / 2
+			values = append(values, body[:size])
+			body = body[size:]
+		}
+	}
+	assert.Empty(t, body)
+
+	return values
+}
+
+// page returns the b-tree page and the offset of its header, after the file header on the first page
+func page(data []byte, number uint32) ([]byte, int) {
+	p := data[(number-1)*pageSize : number*pageSize]
+	if number == 1 {
+		return p, fileHeaderSize
+	}
+	return p, 0
+}
+
+func cellPointers(p []byte, offset int) []int {
+	header := headerSize(p[offset])
+	var pointers []int
+	for i := 0; i < int(binary.BigEndian.Uint16(p[offset+3:])); i++ {
+		pointers = append(pointers, int(binary.BigEndian.Uint16(p[offset+header+2*i:])))
+	}
+	return pointers
+}
+
+// readTable returns the rows of the table b-tree in order.
+func readTable(t *testing.T, data []byte, root uint32) []sqliteRow {
+	p, offset := page(data, root)
+	var rows []sqliteRow
+	for _, pointer := range cellPointers(p, offset) {
+		if p[offset] == pageTypeTableInterior {
+			rows = append(rows, readTable(t, data, binary.BigEndian.Uint32(p[pointer:]))...)
+			continue
+		}
+		assert.Equal(t, byte(pageTypeTableLeaf), p[offset])
+
+		size, n := readVarint(p[pointer:])
+		rowID, m := readVarint(p[pointer+n:])
+		cell := p[pointer+n+m:]
+		local := localPayloadSize(int(size))
+		payload := append([]byte(nil), cell[:local]...)
+		for next := uint32(0); local < int(size) && len(payload) < int(size); {
+			if next == 0 {
+				next = binary.BigEndian.Uint32(cell[local:])
+			}
+			overflow, _ := page(data, next)
+			remaining := int(size) - len(payload)
+			if remaining > pageSize-4 {
+				remaining = pageSize - 4
+			}
+			payload = append(payload, overflow[4:4+remaining]...)
+			next = binary.BigEndian.Uint32(overflow)
+		}
+		rows = append(rows, sqliteRow{RowID: int64(rowID), Values: decodeRecord(t, payload)})
+	}
+	if p[offset] == pageTypeTableInterior {
+		rows = append(rows, readTable(t, data, binary.BigEndian.Uint32(p[offset+8:]))...)
+	}
+
+	return rows
+}
+
+// readIndex returns the keys of the index b-tree in order.
+func readIndex(t *testing.T, data []byte, root uint32) [][]interface{} {
+	p, offset := page(data, root)
+	interior := p[offset] == pageTypeIndexInterior
+	var keys [][]interface{}
+	for _, pointer := range cellPointers(p, offset) {
+		if interior {
+			keys = append(keys, readIndex(t, data, binary.BigEndian.Uint32(p[pointer:]))...)
+			pointer += 4
+		}
+		size, n := readVarint(p[pointer:])
+		keys = append(keys, decodeRecord(t, p[pointer+n:pointer+n+int(size)]))
+	}
+	if interior {
+		keys = append(keys, readIndex(t, data, binary.BigEndian.Uint32(p[offset+8:]))...)
+	}
+
+	return keys
+}
+
+func readSchema(t *testing.T, data []byte) map[string]uint32 {
+	roots := map[string]uint32{}
+	for _, row := range readTable(t, data, 1) {
+		roots[row.Values[1].(string)] = uint32(row.Values[3].(int64))
+	}
+	return roots
+}
+
+func TestRecord(t *testing.T) {
+	assert.Equal(t, []byte{0x7F}, appendVarint(nil, 0x7F))
+	assert.Equal(t, []byte{0x81, 0x00}, appendVarint(nil, 0x80))
+	assert.Equal(t, 9, len(appendVarint(nil, math.MaxUint64)))
+	for _, v := range []uint64{0, 300, 1 << 40, 1<<56 - 1, 1 << 56, math.MaxUint64} {
+		decoded, n := readVarint(appendVarint(nil, v))
+		assert.Equal(t, v, decoded)
+		assert.Equal(t, varintLen(v), n)
+	}
+
+	record, err := encodeRecord([]interface{}{nil, int64(0), int64(1), 200, int64(-70000), int64(1) << 40, 2.5, "abc", []byte{1}})
+	assert.NoError(t, err)
+	// header size and serial types, then the values
+	assert.Equal(t, []byte{10, 0, 8, 9, 2, 3, 5, 7, 19, 14}, record[:10])
+	assert.Equal(t, []interface{}{nil, int64(0), int64(1), int64(200), int64(-70000), int64(1) << 40, 2.5, "abc", []byte{1}}, decodeRecord(t, record))
+
+	_, err = encodeRecord([]interface{}{true})
+	assert.Error(t, err)
+}
+
+func TestDatabase(t *testing.T) {
+	var rows []sqliteRow
+	for i := 3000; i > 0; i-- {
+		rows = append(rows, sqliteRow{RowID: int64(i * 10), Values: []interface{}{nil, fmt.Sprintf("row %d", i), int64(i % 7)}})
+	}
+	// larger than a page, continues on overflow pages
+	rows = append(rows, sqliteRow{RowID: 1, Values: []interface{}{nil, strings.Repeat("x", 3*pageSize), int64(3)}})
+
+	data, err := writeDatabase([]sqliteTable{
+		{Name: "t", SQL: "CREATE TABLE t (id integer primary key, s text, n integer)", Rows: rows},
+		{Name: "empty", SQL: "CREATE TABLE empty (x integer)"},
+	}, []sqliteIndex{{Name: "ix_t_n", Table: "t", SQL: "CREATE INDEX ix_t_n on t (n)", Columns: []int{2}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "SQLite format 3\x00", string(data[:16]))
+	assert.Equal(t, 0, len(data)%pageSize)
+	assert.Equal(t, uint32(len(data)/pageSize), binary.BigEndian.Uint32(data[28:]))
+
+	roots := readSchema(t, data)
+	assert.Len(t, roots, 3)
+
+	read := readTable(t, data, roots["t"])
+	if assert.Len(t, read, len(rows)) {
+		assert.Equal(t, rows[len(rows)-1].Values, read[0].Values)
+		assert.Equal(t, int64(10), read[1].RowID)
+		assert.Equal(t, []interface{}{nil, "row 1", int64(1)}, read[1].Values)
+		assert.Equal(t, int64(30000), read[len(read)-1].RowID)
+	}
+	assert.Empty(t, readTable(t, data, roots["empty"]))
+
+	keys := readIndex(t, data, roots["ix_t_n"])
+	if assert.Len(t, keys, len(rows)) {
+		assert.Equal(t, []interface{}{int64(0), int64(70)}, keys[0])
+		for i := 1; i < len(keys); i++ {
+			previous := keys[i-1][0].(int64)*1000000 + keys[i-1][1].(int64)
+			assert.True(t, previous < keys[i][0].(int64)*1000000+keys[i][1].(int64), "keys %v, %v", keys[i-1], keys[i])
+		}
+	}
+
+	_, err = writeDatabase([]sqliteTable{{Name: "t", Rows: []sqliteRow{{RowID: 1}, {RowID: 1}}}}, nil)
+	assert.Error(t, err)
+	_, err = writeDatabase(nil, []sqliteIndex{{Name: "ix", Table: "t"}})
+	assert.Error(t, err)
+}
+
+func readPackage(t *testing.T, data []byte) map[string][]byte {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	entries := map[string][]byte{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		assert.NoError(t, err)
+		entries[file.Name], err = ioutil.ReadAll(reader)
+		assert.NoError(t, err)
+	}
+	return entries
+}
+
+func TestPackage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anki-test-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a.png", "a.wav", "b.png"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0660))
+	}
+
+	deck := Deck{Name: "Chords::Triads", Notes: []Note{
+		{Image: filepath.Join(dir, "a.png"), Audio: filepath.Join(dir, "a.wav"), Name: "C", RomanNumeral: "I", Scale: "c major", Tags: []string{Tag("scale", "c major")}},
+		{Image: filepath.Join(dir, "b.png"), Name: "Dm", RomanNumeral: "ii", Scale: "c major", GUID: "dm"},
+	}}
+	now := time.Date(2020, 3, 2, 12, 0, 0, 0, time.UTC)
+	data, err := deck.pack(now)
+	assert.NoError(t, err)
+
+	entries := readPackage(t, data)
+	assert.Len(t, entries, 5)
+	assert.JSONEq(t, `{"0": "a.png", "1": "a.wav", "2": "b.png"}`, string(entries["media"]))
+	assert.Equal(t, "a.wav", string(entries["1"]))
+
+	db := entries["collection.anki2"]
+	roots := readSchema(t, db)
+	assert.Len(t, roots, 12)
+
+	noteRows := readTable(t, db, roots["notes"])
+	if assert.Len(t, noteRows, 2) {
+		first := noteRows[0].Values
+		assert.Equal(t, now.Unix()*1000, noteRows[0].RowID)
+		assert.Equal(t, " notes-gen::scale::c_major ", first[5])
+		assert.Equal(t, "<img src=\"a.png\">\x1f[sound:a.wav]\x1fC\x1fI\x1fc major\x1f\x1f\x1f\x1f", first[6])
+		assert.Equal(t, "C", first[7])
+		assert.Equal(t, checksum("a.png"), first[8])
+		assert.Equal(t, "dm", noteRows[1].Values[1])
+	}
+
+	cardRows := readTable(t, db, roots["cards"])
+	if assert.Len(t, cardRows, 2) {
+		assert.Equal(t, noteRows[1].RowID, cardRows[1].Values[1])
+		assert.Equal(t, deck.id(), cardRows[1].Values[2])
+		assert.Equal(t, int64(2), cardRows[1].Values[8])
+	}
+	assert.Len(t, readIndex(t, db, roots["ix_cards_sched"]), 2)
+
+	col := readTable(t, db, roots["col"])
+	if assert.Len(t, col, 1) {
+		var models map[string]model
+		assert.NoError(t, json.Unmarshal([]byte(col[0].Values[9].(string)), &models))
+		m := models[fmt.Sprint(modelID)]
+		assert.Len(t, m.Flds, len(Fields))
+		assert.Equal(t, "RomanNumeral", m.Flds[3].Name)
+		assert.Equal(t, deck.id(), m.DID)
+		assert.True(t, strings.Contains(col[0].Values[10].(string), `"name":"Chords::Triads"`))
+	}
+
+	deck.Notes = append(deck.Notes, Note{Image: filepath.Join(dir, "other", "a.png")})
+	_, err = deck.pack(now)
+	assert.Error(t, err)
+
+	deck.Notes = []Note{{Name: "no image"}}
+	_, err = deck.pack(now)
+	assert.Error(t, err)
+}
+
+func TestDirections(t *testing.T) {
+	directions, err := ParseDirections("notation, name,roman,size,name")
+	assert.NoError(t, err)
+	assert.Equal(t, []Direction{DirectionNotation, DirectionName, DirectionRoman, DirectionSize}, directions)
+	_, err = ParseDirections("notation,inversion")
+	assert.Error(t, err)
+
+	assert.Equal(t, "c1", DirectionNotation.GUID("c1"))
+	assert.Equal(t, "c1-roman", DirectionRoman.GUID("c1"))
+	assert.NotEqual(t, modelID, directionsModelID(directions))
+	assert.Equal(t, modelID, directionsModelID(DefaultDirections))
+
+	dir, err := ioutil.TempDir("", "anki-test-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	image := filepath.Join(dir, "a.png")
+	assert.NoError(t, ioutil.WriteFile(image, []byte("a.png"), 0660))
+
+	// the interval has no Roman numeral and the chord no size, their cards are not generated
+	deck := Deck{Name: "Directions", Directions: directions, Notes: []Note{
+		{Image: image, Name: "C maj", RomanNumeral: "I", Description: "C maj in root position"},
+		{Image: image, Name: "Major third", IntervalSize: "third", Description: "a major third above C4"},
+	}}
+	data, err := deck.pack(time.Date(2020, 3, 2, 12, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	db := readPackage(t, data)["collection.anki2"]
+	roots := readSchema(t, db)
+
+	noteRows := readTable(t, db, roots["notes"])
+	var ords []interface{}
+	for _, card := range readTable(t, db, roots["cards"]) {
+		ords = append(ords, fmt.Sprintf("%d:%d", card.Values[1].(int64)-noteRows[0].RowID, card.Values[3]))
+	}
+	assert.Equal(t, []interface{}{"0:0", "0:1", "0:2", "1:0", "1:1", "1:3"}, ords)
+
+	var models map[string]model
+	assert.NoError(t, json.Unmarshal([]byte(readTable(t, db, roots["col"])[0].Values[9].(string)), &models))
+	m := models[fmt.Sprint(directionsModelID(directions))]
+	if assert.Len(t, m.Tmpls, 4) {
+		assert.Equal(t, "Roman", m.Tmpls[2].Name)
+		assert.True(t, strings.Contains(m.Tmpls[2].QFmt, "Roman numeral?"), m.Tmpls[2].QFmt)
+		assert.True(t, strings.Contains(m.Tmpls[2].AFmt, "{{RomanNumeral}}"), m.Tmpls[2].AFmt)
+		assert.True(t, strings.Contains(m.Tmpls[1].QFmt, "Draw {{Description}}"), m.Tmpls[1].QFmt)
+	}
+	assert.Equal(t, "notes-gen (notation, name, roman, size)", m.Name)
+
+	notation := Card{
+		Note:  Note{GUID: "c1", Image: "images/c.png", Name: "C maj", RomanNumeral: "I", Scale: "c major", Description: "C maj in root position", Tags: []string{Tag("scale", "c major")}},
+		Front: `<img src="c.png">`,
+		Back:  "C maj (I)",
+	}
+	cards := DirectionCards(notation, directions)
+	if assert.Len(t, cards, 3) {
+		assert.Equal(t, notation.Front, cards[0].Front)
+		assert.Equal(t, "c1", cards[0].GUID)
+		assert.Equal(t, DirectionName, cards[1].Direction)
+		assert.Equal(t, "Draw C maj in root position<br>c major", cards[1].Front)
+		assert.Equal(t, `<img src="c.png">`, cards[1].Back)
+		assert.Equal(t, "c1-name", cards[1].GUID)
+		assert.Equal(t, `<img src="c.png"><br>Roman numeral?`, cards[2].Front)
+		assert.Equal(t, "I", cards[2].Back)
+		assert.Equal(t, []string{Tag("scale", "c major"), Tag("direction", "roman")}, cards[2].Tags)
+	}
+	assert.Equal(t, []string{Tag("scale", "c major")}, notation.Tags)
+}
+
+func TestNoteTags(t *testing.T) {
+	assert.Equal(t, "notes-gen::scale::c_sharp_minor", Tag("scale", "C sharp  minor"))
+
+	var chord notes.Chord
+	for _, c := range notes.GenerateAllDiatonicTriadsInScale(notes.CMajorScale) {
+		if c.Type == notes.ChordTypeMajorTriad && len(notes.ChordToChordOnClefs(c).BassClefNotes) == 0 {
+			chord = c
+			break
+		}
+	}
+	note := ChordNote(chord, "chord.png", "")
+	assert.Equal(t, chord.ID(), note.GUID)
+	assert.Equal(t, chord.Name(), note.Name)
+	assert.Equal(t, chord.RomanNumeral(), note.RomanNumeral)
+	assert.Equal(t, "major triad", note.Quality)
+	assert.Equal(t, chord.RootNote.NameWithSharpFlatModifier(), note.Root)
+	assert.Equal(t, chord.Name()+" in "+chord.InversionName(), note.Description)
+	assert.Equal(t, []string{"notes-gen::scale::c_major", "notes-gen::chord::major_triad", "notes-gen::clef::treble"}, note.Tags)
+
+	intervals := notes.GenerateIntervals(notes.ApplyScale(notes.AllNotes, notes.CMajorScale), 0, len(notes.AllNotes), 12)
+	var interval notes.Interval
+	for _, i := range intervals {
+		if i.Distance() == 4 && i.ClefLayout() == notes.IntervalClefLayoutBass {
+			interval = i
+			break
+		}
+	}
+	interval.Scale = notes.CMajorScale
+	note = IntervalNote(interval, "interval.png", "interval.wav")
+	assert.Equal(t, interval.ID(), note.GUID)
+	assert.Equal(t, "third", note.IntervalSize)
+	assert.Equal(t, "major", note.Quality)
+	assert.Equal(t, "a major third above "+interval.FirstNote.ScientificName(), note.Description)
+	assert.Equal(t, []string{"notes-gen::scale::c_major", "notes-gen::interval::major_third", "notes-gen::clef::bass"}, note.Tags)
+}
+
+func TestDeckFile(t *testing.T) {
+	cards := []Card{
+		{
+			Note:  Note{GUID: "c1", Name: "C maj", RomanNumeral: "I", Audio: "images/c.wav", Tags: []string{Tag("scale", "c major"), Tag("clef", "treble")}},
+			Front: `<img src="c.png">`,
+			Back:  `C maj; "tonic"`,
+		},
+		{Note: Note{GUID: "d1", Name: "D min"}, Front: `<img src="d.png">`, Back: "D min", ChordType: "minor triad", SortKey: "2"},
+	}
+
+	data, err := DefaultDeckFile.Encode(cards)
+	assert.NoError(t, err)
+	assert.Equal(t, `#separator:semicolon
+#html:true
+#guid column:3
+"<img src=""c.png"">";"C maj; ""tonic""";c1
+"<img src=""d.png"">";D min;d1
+`, string(data))
+
+	columns, err := ParseColumns("guid, tags,audio,chordType,roman")
+	assert.NoError(t, err)
+	tsv := DeckFile{Delimiter: '\t', Columns: columns}
+	data, err = tsv.Encode(cards)
+	assert.NoError(t, err)
+	assert.Equal(t, "#separator:tab\n#html:true\n#guid column:1\n#tags column:2\n"+
+		"c1\tnotes-gen::scale::c_major notes-gen::clef::treble\t[sound:c.wav]\t\tI\n"+
+		"d1\t\t\tminor triad\t\n", string(data))
+
+	tsv.Header = true
+	data, err = tsv.Encode(cards[1:])
+	assert.NoError(t, err)
+	assert.Equal(t, "guid\ttags\taudio\tchordType\troman\nd1\t\t\tminor triad\t\n", string(data))
+
+	_, err = ParseColumns("front,image")
+	assert.Error(t, err)
+	_, err = DeckFile{Delimiter: '"', Columns: columns}.Encode(cards)
+	assert.Error(t, err)
+	_, err = DeckFile{Delimiter: ';'}.Encode(cards)
+	assert.Error(t, err)
+}
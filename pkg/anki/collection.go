@@ -0,0 +1,294 @@
+package anki
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schemaVersion is the collection schema of Anki 2.1.0, which all later versions import and upgrade.
+const schemaVersion = 11
+
+// tables and indexes of the collection schema
+var (
+	colTable    = "CREATE TABLE col (id integer primary key, crt integer not null, mod integer not null, scm integer not null, ver integer not null, dty integer not null, usn integer not null, ls integer not null, conf text not null, models text not null, decks text not null, dconf text not null, tags text not null)"
+	notesTable  = "CREATE TABLE notes (id integer primary key, guid text not null, mid integer not null, mod integer not null, usn integer not null, tags text not null, flds text not null, sfld integer not null, csum integer not null, flags integer not null, data text not null)"
+	cardsTable  = "CREATE TABLE cards (id integer primary key, nid integer not null, did integer not null, ord integer not null, mod integer not null, usn integer not null, type integer not null, queue integer not null, due integer not null, ivl integer not null, factor integer not null, reps integer not null, lapses integer not null, left integer not null, odue integer not null, odid integer not null, flags integer not null, data text not null)"
+	revlogTable = "CREATE TABLE revlog (id integer primary key, cid integer not null, usn integer not null, ease integer not null, ivl integer not null, lastIvl integer not null, factor integer not null, time integer not null, type integer not null)"
+	gravesTable = "CREATE TABLE graves (usn integer not null, oid integer not null, type integer not null)"
+
+	// positions of the indexed columns in the rows below
+	collectionIndexes = []sqliteIndex{
+		{Name: "ix_notes_usn", Table: "notes", SQL: "CREATE INDEX ix_notes_usn on notes (usn)", Columns: []int{4}},
+		{Name: "ix_cards_usn", Table: "cards", SQL: "CREATE INDEX ix_cards_usn on cards (usn)", Columns: []int{5}},
+		{Name: "ix_revlog_usn", Table: "revlog", SQL: "CREATE INDEX ix_revlog_usn on revlog (usn)", Columns: []int{2}},
+		{Name: "ix_cards_nid", Table: "cards", SQL: "CREATE INDEX ix_cards_nid on cards (nid)", Columns: []int{1}},
+		{Name: "ix_cards_sched", Table: "cards", SQL: "CREATE INDEX ix_cards_sched on cards (did, queue, due)", Columns: []int{2, 7, 8}},
+		{Name: "ix_revlog_cid", Table: "revlog", SQL: "CREATE INDEX ix_revlog_cid on revlog (cid)", Columns: []int{1}},
+		{Name: "ix_notes_csum", Table: "notes", SQL: "CREATE INDEX ix_notes_csum on notes (csum)", Columns: []int{8}},
+	}
+)
+
+const (
+	defaultDeckID = 1
+	// defaultConfID is the id of the default deck options
+	defaultConfID = 1
+	// usn -1 marks objects changed since the last sync
+	unsynced = -1
+)
+
+type modelField struct {
+	Name   string        `json:"name"`
+	Ord    int           `json:"ord"`
+	Sticky bool          `json:"sticky"`
+	RTL    bool          `json:"rtl"`
+	Font   string        `json:"font"`
+	Size   int           `json:"size"`
+	Media  []interface{} `json:"media"`
+}
+
+type modelTemplate struct {
+	Name  string      `json:"name"`
+	Ord   int         `json:"ord"`
+	QFmt  string      `json:"qfmt"`
+	AFmt  string      `json:"afmt"`
+	DID   interface{} `json:"did"`
+	BQFmt string      `json:"bqfmt"`
+	BAFmt string      `json:"bafmt"`
+}
+
+type model struct {
+	ID        int64           `json:"id"`
+	Name      string          `json:"name"`
+	Type      int             `json:"type"`
+	Mod       int64           `json:"mod"`
+	USN       int             `json:"usn"`
+	SortF     int             `json:"sortf"`
+	DID       int64           `json:"did"`
+	Tmpls     []modelTemplate `json:"tmpls"`
+	Flds      []modelField    `json:"flds"`
+	CSS       string          `json:"css"`
+	LatexPre  string          `json:"latexPre"`
+	LatexPost string          `json:"latexPost"`
+	Tags      []string        `json:"tags"`
+	Vers      []interface{}   `json:"vers"`
+	Req       []interface{}   `json:"req"`
+}
+
+type deck struct {
+	ID               int64  `json:"id"`
+	Name             string `json:"name"`
+	Mod              int64  `json:"mod"`
+	USN              int    `json:"usn"`
+	LrnToday         []int  `json:"lrnToday"`
+	RevToday         []int  `json:"revToday"`
+	NewToday         []int  `json:"newToday"`
+	TimeToday        []int  `json:"timeToday"`
+	Collapsed        bool   `json:"collapsed"`
+	BrowserCollapsed bool   `json:"browserCollapsed"`
+	Desc             string `json:"desc"`
+	Dyn              int    `json:"dyn"`
+	Conf             int64  `json:"conf"`
+	ExtendNew        int    `json:"extendNew"`
+	ExtendRev        int    `json:"extendRev"`
+}
+
+func newDeck(id int64, name string, mod int64) deck {
+	return deck{
+		ID:        id,
+		Name:      name,
+		Mod:       mod,
+		USN:       unsynced,
+		LrnToday:  []int{0, 0},
+		RevToday:  []int{0, 0},
+		NewToday:  []int{0, 0},
+		TimeToday: []int{0, 0},
+		Conf:      defaultConfID,
+		ExtendNew: 10,
+		ExtendRev: 50,
+	}
+}
+
+var defaultConf = map[string]interface{}{
+	"id":       defaultConfID,
+	"name":     "Default",
+	"mod":      0,
+	"usn":      0,
+	"maxTaken": 60,
+	"autoplay": true,
+	"timer":    0,
+	"replayq":  true,
+	"new": map[string]interface{}{
+		"bury":          true,
+		"delays":        []int{1, 10},
+		"initialFactor": 2500,
+		"ints":          []int{1, 4, 7},
+		"order":         1,
+		"perDay":        20,
+		"separate":      true,
+	},
+	"lapse": map[string]interface{}{
+		"delays":      []int{10},
+		"leechAction": 0,
+		"leechFails":  8,
+		"minInt":      1,
+		"mult":        0,
+	},
+	"rev": map[string]interface{}{
+		"bury":     true,
+		"ease4":    1.3,
+		"fuzz":     0.05,
+		"ivlFct":   1,
+		"maxIvl":   36500,
+		"minSpace": 1,
+		"perDay":   100,
+	},
+}
+
+func noteModel(directions []Direction, deckID int64, mod int64) model {
+	m := model{
+		ID:        directionsModelID(directions),
+		Name:      modelName,
+		Mod:       mod,
+		USN:       unsynced,
+		SortF:     sortField,
+		DID:       deckID,
+		CSS:       css,
+		LatexPre:  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+		LatexPost: "\\end{document}",
+		Tags:      []string{},
+		Vers:      []interface{}{},
+	}
+	if len(directions) > 1 || directions[0] != DirectionNotation {
+		m.Name = fmt.Sprintf("%s (%s)", modelName, directionNames(directions))
+	}
+	for i, name := range Fields {
+		m.Flds = append(m.Flds, modelField{Name: name, Ord: i, Font: "Arial", Size: 20, Media: []interface{}{}})
+	}
+	// the cards of a template are generated when the required fields are not empty
+	for i, direction := range directions {
+		template, req := direction.template(i)
+		m.Tmpls = append(m.Tmpls, template)
+		m.Req = append(m.Req, req)
+	}
+
+	return m
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+var imageSource = regexp.MustCompile(`<img[^>]*src="([^"]*)"[^>]*>`)
+
+// checksum is the duplicate check of the first field: the first 8 hex digits of the SHA1 of its text, with
+// images replaced by their file names.
+func checksum(field string) int64 {
+	text := imageSource.ReplaceAllString(field, " $1 ")
+	text = strings.TrimSpace(htmlTag.ReplaceAllString(text, ""))
+	sum := sha1.Sum([]byte(text))
+	value, _ := strconv.ParseInt(fmt.Sprintf("%x", sum[:4]), 16, 64)
+	return value
+}
+
+func directionNames(directions []Direction) string {
+	var names []string
+	for _, direction := range directions {
+		names = append(names, string(direction))
+	}
+	return strings.Join(names, ", ")
+}
+
+// generatesCard tells whether a template requirement, "any" or "all" of the field indices not empty, is met.
+func generatesCard(req []interface{}, fields []string) bool {
+	all := req[1] == "all"
+	for _, field := range req[2].([]int) {
+		if (fields[field] != "") != all {
+			return !all
+		}
+	}
+	return all
+}
+
+// collection returns the SQLite database of a collection with the deck. Note and card ids are the creation time
+// in milliseconds, increasing for every note and card.
+func collection(d Deck, fields [][]string, now time.Time) ([]byte, error) {
+	mod := now.Unix()
+	millis := now.UnixNano() / int64(time.Millisecond)
+	deckID := d.id()
+	directions := d.directions()
+	noteType := noteModel(directions, deckID, mod)
+
+	models, err := json.Marshal(map[string]model{strconv.FormatInt(noteType.ID, 10): noteType})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode note type: %v", err)
+	}
+
+	decks, err := json.Marshal(map[string]deck{
+		strconv.FormatInt(defaultDeckID, 10): newDeck(defaultDeckID, "Default", mod),
+		strconv.FormatInt(deckID, 10):        newDeck(deckID, d.Name, mod),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode decks: %v", err)
+	}
+
+	dconf, err := json.Marshal(map[string]interface{}{strconv.Itoa(defaultConfID): defaultConf})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode deck options: %v", err)
+	}
+
+	conf, err := json.Marshal(map[string]interface{}{
+		"activeDecks":   []int64{deckID},
+		"curDeck":       deckID,
+		"curModel":      strconv.FormatInt(noteType.ID, 10),
+		"nextPos":       len(d.Notes) + 1,
+		"addToCur":      true,
+		"collapseTime":  1200,
+		"dueCounts":     true,
+		"estTimes":      true,
+		"newSpread":     0,
+		"sortBackwards": false,
+		"sortType":      "noteFld",
+		"timeLim":       0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode collection options: %v", err)
+	}
+
+	// the collection was created at the start of the day
+	year, month, day := now.Date()
+	created := time.Date(year, month, day, 0, 0, 0, 0, now.Location()).Unix()
+	col := sqliteRow{RowID: 1, Values: []interface{}{nil, created, millis, millis, schemaVersion, 0, 0, 0, string(conf), string(models), string(decks), string(dconf), "{}"}}
+
+	var notes []sqliteRow
+	var cards []sqliteRow
+	for i, note := range d.Notes {
+		id := millis + int64(i)
+		tags := ""
+		if len(note.Tags) > 0 {
+			tags = " " + strings.Join(note.Tags, " ") + " "
+		}
+		notes = append(notes, sqliteRow{RowID: id, Values: []interface{}{
+			nil, note.guid(fields[i]), noteType.ID, mod, unsynced, tags, strings.Join(fields[i], "\x1f"), fields[i][sortField], checksum(fields[i][0]), 0, "",
+		}})
+
+		for ord, req := range noteType.Req {
+			if !generatesCard(req.([]interface{}), fields[i]) {
+				continue
+			}
+			// new cards are due in the order of the notes
+			cards = append(cards, sqliteRow{RowID: millis + int64(len(cards)), Values: []interface{}{
+				nil, id, deckID, ord, mod, unsynced, 0, 0, i + 1, 0, 0, 0, 0, 0, 0, 0, 0, "",
+			}})
+		}
+	}
+
+	return writeDatabase([]sqliteTable{
+		{Name: "col", SQL: colTable, Rows: []sqliteRow{col}},
+		{Name: "notes", SQL: notesTable, Rows: notes},
+		{Name: "cards", SQL: cardsTable, Rows: cards},
+		{Name: "revlog", SQL: revlogTable},
+		{Name: "graves", SQL: gravesTable},
+	}, collectionIndexes)
+}
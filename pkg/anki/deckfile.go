@@ -0,0 +1,208 @@
+package anki
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Column of a deck file.
+type Column string
+
+const (
+	ColumnFront        Column = "front"
+	ColumnBack         Column = "back"
+	ColumnGUID         Column = "guid"
+	ColumnName         Column = "name"
+	ColumnTags         Column = "tags"
+	ColumnScale        Column = "scale"
+	ColumnRomanNumeral Column = "roman"
+	ColumnChordType    Column = "chordType"
+	ColumnIntervalSize Column = "intervalSize"
+	ColumnAudio        Column = "audio"
+	ColumnSortKey      Column = "sortKey"
+	ColumnDirection    Column = "direction"
+)
+
+var columns = []Column{ColumnFront, ColumnBack, ColumnGUID, ColumnName, ColumnTags, ColumnScale, ColumnRomanNumeral, ColumnChordType, ColumnIntervalSize, ColumnAudio, ColumnSortKey, ColumnDirection}
+
+// ParseColumns parses comma separated column names.
+func ParseColumns(names string) ([]Column, error) {
+	var parsed []Column
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, column := range columns {
+			if strings.EqualFold(name, string(column)) {
+				parsed = append(parsed, column)
+				found = true
+				break
+			}
+		}
+		if !found {
+			var expected []string
+			for _, column := range columns {
+				expected = append(expected, string(column))
+			}
+			return nil, fmt.Errorf("invalid deck column: %s, expected one of: %s", name, strings.Join(expected, ", "))
+		}
+	}
+
+	return parsed, nil
+}
+
+// Card is a line of a deck file, a note with the texts of its card in one direction.
+type Card struct {
+	Note
+	Direction Direction
+	Front     string
+	Back      string
+	ChordType string
+	SortKey   string
+}
+
+func (c Card) value(column Column) string {
+	switch column {
+	case ColumnFront:
+		return c.Front
+	case ColumnBack:
+		return c.Back
+	case ColumnGUID:
+		return c.GUID
+	case ColumnName:
+		return c.Name
+	case ColumnTags:
+		return strings.Join(c.Tags, " ")
+	case ColumnScale:
+		return c.Scale
+	case ColumnRomanNumeral:
+		return c.RomanNumeral
+	case ColumnChordType:
+		return c.ChordType
+	case ColumnIntervalSize:
+		return c.IntervalSize
+	case ColumnAudio:
+		if c.Audio == "" {
+			return ""
+		}
+		return fmt.Sprintf("[sound:%s]", filepath.Base(c.Audio))
+	case ColumnSortKey:
+		return c.SortKey
+	case ColumnDirection:
+		return string(c.Direction)
+	default:
+		return ""
+	}
+}
+
+// DeckFile writes cards as delimiter separated lines for the Anki text import. Unless there is a Header with
+// the column names, the lines start with the import options: the separator, html fields and the guid and
+// tags columns. A header row is meant for other tools, Anki imports it as a card.
+type DeckFile struct {
+	Delimiter rune
+	Header    bool
+	Columns   []Column
+}
+
+var DefaultDeckFile = DeckFile{
+	Delimiter: ';',
+	Columns:   []Column{ColumnFront, ColumnBack, ColumnGUID},
+}
+
+// separatorNames are the names of separators in the import options, other ones are given as they are
+var separatorNames = map[rune]string{
+	',':  "comma",
+	';':  "semicolon",
+	'\t': "tab",
+	' ':  "space",
+	'|':  "pipe",
+	':':  "colon",
+}
+
+func (f DeckFile) Validate() error {
+	if len(f.Columns) == 0 {
+		return fmt.Errorf("deck file has no columns")
+	}
+	if f.Delimiter == 0 || f.Delimiter == '"' || f.Delimiter == '\r' || f.Delimiter == '\n' || f.Delimiter == 0xFFFD {
+		return fmt.Errorf("invalid deck delimiter: %q", f.Delimiter)
+	}
+	return nil
+}
+
+func (f DeckFile) options() []string {
+	separator, ok := separatorNames[f.Delimiter]
+	if !ok {
+		separator = string(f.Delimiter)
+	}
+
+	options := []string{"#separator:" + separator, "#html:true"}
+	for i, column := range f.Columns {
+		switch column {
+		case ColumnGUID:
+			options = append(options, fmt.Sprintf("#guid column:%d", i+1))
+		case ColumnTags:
+			options = append(options, fmt.Sprintf("#tags column:%d", i+1))
+		}
+	}
+
+	return options
+}
+
+// Encode returns the deck file with the cards in the given order.
+func (f DeckFile) Encode(cards []Card) ([]byte, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	buf := bytes.Buffer{}
+	if !f.Header {
+		for _, option := range f.options() {
+			buf.WriteString(option + "\n")
+		}
+	}
+
+	w := csv.NewWriter(&buf)
+	w.Comma = f.Delimiter
+	if f.Header {
+		var names []string
+		for _, column := range f.Columns {
+			names = append(names, string(column))
+		}
+		if err := w.Write(names); err != nil {
+			return nil, fmt.Errorf("failed to write deck header: %v", err)
+		}
+	}
+
+	for _, card := range cards {
+		var record []string
+		for _, column := range f.Columns {
+			record = append(record, card.value(column))
+		}
+		if err := w.Write(record); err != nil {
+			return nil, fmt.Errorf("failed to write card %s: %v", card.Name, err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write deck file: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (f DeckFile) WriteFile(path string, cards []Card) error {
+	data, err := f.Encode(cards)
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, data, 0660); err != nil {
+		return fmt.Errorf("failed to write deck file %s: %v", path, err)
+	}
+
+	return nil
+}
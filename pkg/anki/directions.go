@@ -0,0 +1,177 @@
+package anki
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strings"
+)
+
+// Direction is what a card of an item asks for.
+type Direction string
+
+const (
+	// DirectionNotation shows the notation and asks for the name
+	DirectionNotation Direction = "notation"
+	// DirectionName shows the name and asks to draw the notation
+	DirectionName Direction = "name"
+	// the single attribute cards show the notation and ask only for the Roman numeral, quality, root or interval size
+	DirectionRoman   Direction = "roman"
+	DirectionQuality Direction = "quality"
+	DirectionRoot    Direction = "root"
+	DirectionSize    Direction = "size"
+)
+
+var DefaultDirections = []Direction{DirectionNotation}
+
+var directions = []Direction{DirectionNotation, DirectionName, DirectionRoman, DirectionQuality, DirectionRoot, DirectionSize}
+
+var questions = map[Direction]string{
+	DirectionRoman:   "Roman numeral?",
+	DirectionQuality: "Quality?",
+	DirectionRoot:    "Root?",
+	DirectionSize:    "Size?",
+}
+
+// ParseDirections parses comma separated directions, each one only once.
+func ParseDirections(names string) ([]Direction, error) {
+	var parsed []Direction
+	seen := map[Direction]bool{}
+	for _, name := range strings.Split(names, ",") {
+		direction := Direction(strings.ToLower(strings.TrimSpace(name)))
+		if !direction.valid() {
+			var expected []string
+			for _, d := range directions {
+				expected = append(expected, string(d))
+			}
+			return nil, fmt.Errorf("invalid card direction: %s, expected one of: %s", name, strings.Join(expected, ", "))
+		}
+		if !seen[direction] {
+			parsed = append(parsed, direction)
+			seen[direction] = true
+		}
+	}
+
+	return parsed, nil
+}
+
+func (d Direction) valid() bool {
+	for _, direction := range directions {
+		if d == direction {
+			return true
+		}
+	}
+	return false
+}
+
+// GUID returns the GUID of the note of the item card in a deck file, where every card is a separate note.
+// The notation cards keep the item id, as they had before there were other directions.
+func (d Direction) GUID(id string) string {
+	if d == DirectionNotation {
+		return id
+	}
+	return id + "-" + string(d)
+}
+
+// Question is asked under the notation on the single attribute cards.
+func (d Direction) Question() string {
+	return questions[d]
+}
+
+func fieldIndex(name string) int {
+	for i, field := range Fields {
+		if field == name {
+			return i
+		}
+	}
+	panic(fmt.Errorf("unknown field: %s", name))
+}
+
+// answerFields are the fields the single attribute cards ask for
+var answerFields = map[Direction]string{
+	DirectionRoman:   "RomanNumeral",
+	DirectionQuality: "Quality",
+	DirectionRoot:    "Root",
+	DirectionSize:    "IntervalSize",
+}
+
+// template returns the card template of the direction with the fields it needs to generate a card.
+func (d Direction) template(ord int) (modelTemplate, []interface{}) {
+	template := modelTemplate{Name: strings.ToUpper(string(d[:1])) + string(d[1:]), Ord: ord}
+	image := fieldIndex("Image")
+	switch d {
+	case DirectionNotation:
+		template.QFmt = frontTemplate
+		template.AFmt = backTemplate
+		return template, []interface{}{ord, "any", []int{image}}
+	case DirectionName:
+		template.QFmt = nameFrontTemplate
+		template.AFmt = nameBackTemplate
+		return template, []interface{}{ord, "all", []int{image, fieldIndex("Description")}}
+	default:
+		field := answerFields[d]
+		template.QFmt = fmt.Sprintf(attributeFrontTemplate, d.Question())
+		template.AFmt = fmt.Sprintf(attributeBackTemplate, field)
+		return template, []interface{}{ord, "all", []int{image, fieldIndex(field)}}
+	}
+}
+
+// directionsModelID is the note type id of the directions, the default directions keep the plain model id
+func directionsModelID(directions []Direction) int64 {
+	if len(directions) == 1 && directions[0] == DirectionNotation {
+		return modelID
+	}
+
+	h := fnv.New32a()
+	for _, direction := range directions {
+		h.Write([]byte(direction + ","))
+	}
+	return modelID + int64(h.Sum32())
+}
+
+func (d Direction) answer(note Note) string {
+	switch d {
+	case DirectionName:
+		return note.Description
+	case DirectionRoman:
+		return note.RomanNumeral
+	case DirectionQuality:
+		return note.Quality
+	case DirectionRoot:
+		return note.Root
+	case DirectionSize:
+		return note.IntervalSize
+	default:
+		return note.Name
+	}
+}
+
+// DirectionCards returns the card of the item in every direction its note has the fields for. The notation card
+// is given, the others are built from its note, each with its own GUID and tagged with its direction.
+func DirectionCards(notation Card, directions []Direction) []Card {
+	image := fmt.Sprintf(`<img src="%s">`, filepath.Base(notation.Image))
+
+	var cards []Card
+	for _, direction := range directions {
+		if direction.answer(notation.Note) == "" {
+			continue
+		}
+
+		card := notation
+		card.Direction = direction
+		card.GUID = direction.GUID(notation.GUID)
+		card.Tags = append(append([]string{}, notation.Tags...), Tag("direction", string(direction)))
+		switch direction {
+		case DirectionNotation:
+		case DirectionName:
+			card.Front = fmt.Sprintf("Draw %s<br>%s", notation.Description, notation.Scale)
+			card.Back = image
+		default:
+			card.Front = fmt.Sprintf("%s<br>%s", image, direction.Question())
+			card.Back = direction.answer(notation.Note)
+		}
+		cards = append(cards, card)
+	}
+
+	return cards
+}
@@ -0,0 +1,504 @@
+package anki
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// A minimal writer of SQLite 3 database files, enough for a freshly created collection: tables with their rows
+// and indexes on integer columns, without free pages or auto-vacuum. See https://www.sqlite.org/fileformat.html.
+
+const (
+	pageSize = 4096
+	// sqliteVersion is the SQLITE_VERSION_NUMBER stored in the header, the version that last wrote the file
+	sqliteVersion = 3031001
+
+	// table leaf cells keep up to maxLocalPayload bytes on the page, larger payloads keep at least
+	// minLocalPayload bytes and continue on overflow pages
+	maxLocalPayload = pageSize - 35
+	minLocalPayload = (pageSize-12)*32/255 - 23
+	// maxIndexPayload is the largest index key kept on the page, larger keys are not supported
+	maxIndexPayload = (pageSize-12)*64/255 - 23
+
+	pageTypeIndexInterior = 0x02
+	pageTypeTableInterior = 0x05
+	pageTypeIndexLeaf     = 0x0A
+	pageTypeTableLeaf     = 0x0D
+
+	leafHeaderSize     = 8
+	interiorHeaderSize = 12
+	// fileHeaderSize is the size of the database header on the first page, before the schema table
+	fileHeaderSize = 100
+)
+
+// sqliteRow is a row of a table. Values hold all columns, with nil in place of an integer primary key,
+// which is stored as the RowID.
+type sqliteRow struct {
+	RowID  int64
+	Values []interface{}
+}
+
+type sqliteTable struct {
+	Name string
+	SQL  string
+	Rows []sqliteRow
+}
+
+// sqliteIndex indexes integer Columns of the Table, given as positions in the row values.
+type sqliteIndex struct {
+	Name    string
+	Table   string
+	SQL     string
+	Columns []int
+}
+
+type database struct {
+	pages [][]byte
+}
+
+// writeDatabase returns the database file with the tables and the indexes on them.
+func writeDatabase(tables []sqliteTable, indexes []sqliteIndex) ([]byte, error) {
+	db := &database{}
+	// the first page holds the file header and the schema table
+	db.allocate()
+
+	var schema []sqliteRow
+	rows := map[string][]sqliteRow{}
+	for _, table := range tables {
+		root, err := db.writeTable(table.Rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write table %s: %v", table.Name, err)
+		}
+		rows[table.Name] = table.Rows
+		schema = append(schema, sqliteRow{RowID: int64(len(schema) + 1), Values: []interface{}{"table", table.Name, table.Name, int64(root), table.SQL}})
+	}
+
+	for _, index := range indexes {
+		tableRows, ok := rows[index.Table]
+		if !ok {
+			return nil, fmt.Errorf("unknown table %s of index %s", index.Table, index.Name)
+		}
+		root, err := db.writeIndex(tableRows, index.Columns)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write index %s: %v", index.Name, err)
+		}
+		schema = append(schema, sqliteRow{RowID: int64(len(schema) + 1), Values: []interface{}{"index", index.Name, index.Table, int64(root), index.SQL}})
+	}
+
+	var cells [][]byte
+	for _, row := range schema {
+		cell, err := db.tableLeafCell(row)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write schema: %v", err)
+		}
+		cells = append(cells, cell)
+	}
+	if !layoutPage(db.pages[0], fileHeaderSize, pageTypeTableLeaf, cells, 0) {
+		return nil, fmt.Errorf("schema of %d tables and indexes doesn't fit on the first page", len(schema))
+	}
+	db.writeHeader()
+
+	data := make([]byte, 0, len(db.pages)*pageSize)
+	for _, page := range db.pages {
+		data = append(data, page...)
+	}
+
+	return data, nil
+}
+
+func (db *database) writeHeader() {
+	header := db.pages[0][:fileHeaderSize]
+	copy(header, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(header[16:], pageSize)
+	// legacy rollback journal for writing and reading
+	header[18], header[19] = 1, 1
+	// maximum, minimum and leaf embedded payload fractions, always 64, 32 and 32
+	header[21], header[22], header[23] = 64, 32, 32
+	// file change counter
+	binary.BigEndian.PutUint32(header[24:], 1)
+	binary.BigEndian.PutUint32(header[28:], uint32(len(db.pages)))
+	// schema cookie and schema format
+	binary.BigEndian.PutUint32(header[40:], 1)
+	binary.BigEndian.PutUint32(header[44:], 4)
+	// UTF-8 text encoding
+	binary.BigEndian.PutUint32(header[56:], 1)
+	// the database size above is valid for this change counter
+	binary.BigEndian.PutUint32(header[92:], 1)
+	binary.BigEndian.PutUint32(header[96:], sqliteVersion)
+}
+
+// allocate appends an empty page and returns its 1-based number.
+func (db *database) allocate() (uint32, []byte) {
+	page := make([]byte, pageSize)
+	db.pages = append(db.pages, page)
+	return uint32(len(db.pages)), page
+}
+
+// writePage allocates a b-tree page with the cells, callers make sure they fit.
+func (db *database) writePage(pageType byte, cells [][]byte, rightChild uint32) uint32 {
+	number, page := db.allocate()
+	if !layoutPage(page, 0, pageType, cells, rightChild) {
+		panic(fmt.Errorf("%d cells don't fit on page %d", len(cells), number))
+	}
+	return number
+}
+
+func headerSize(pageType byte) int {
+	if pageType == pageTypeTableLeaf || pageType == pageTypeIndexLeaf {
+		return leafHeaderSize
+	}
+	return interiorHeaderSize
+}
+
+// layoutPage writes the b-tree page header at offset, followed by the cell pointers, and the cells at the end of the page.
+func layoutPage(page []byte, offset int, pageType byte, cells [][]byte, rightChild uint32) bool {
+	header := headerSize(pageType)
+	content := pageSize
+	for i, cell := range cells {
+		content -= len(cell)
+		if content < offset+header+2*len(cells) {
+			return false
+		}
+		copy(page[content:], cell)
+		binary.BigEndian.PutUint16(page[offset+header+2*i:], uint16(content))
+	}
+
+	page[offset] = pageType
+	binary.BigEndian.PutUint16(page[offset+3:], uint16(len(cells)))
+	binary.BigEndian.PutUint16(page[offset+5:], uint16(content))
+	if header == interiorHeaderSize {
+		binary.BigEndian.PutUint32(page[offset+8:], rightChild)
+	}
+
+	return true
+}
+
+// tableChild is a page of a table b-tree with the largest rowid stored under it.
+type tableChild struct {
+	page     uint32
+	maxRowID int64
+}
+
+// writeTable writes the rows as a table b-tree and returns its root page.
+func (db *database) writeTable(rows []sqliteRow) (uint32, error) {
+	sorted := append([]sqliteRow(nil), rows...)
+	sort.Slice(sorted, func(i int, j int) bool { return sorted[i].RowID < sorted[j].RowID })
+
+	var children []tableChild
+	var cells [][]byte
+	used := leafHeaderSize
+	for i, row := range sorted {
+		if i > 0 && row.RowID == sorted[i-1].RowID {
+			return 0, fmt.Errorf("duplicate rowid: %d", row.RowID)
+		}
+
+		cell, err := db.tableLeafCell(row)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode row %d: %v", row.RowID, err)
+		}
+
+		if used+len(cell)+2 > pageSize {
+			children = append(children, tableChild{page: db.writePage(pageTypeTableLeaf, cells, 0), maxRowID: sorted[i-1].RowID})
+			cells, used = nil, leafHeaderSize
+		}
+		cells = append(cells, cell)
+		used += len(cell) + 2
+	}
+
+	var maxRowID int64
+	if len(sorted) > 0 {
+		maxRowID = sorted[len(sorted)-1].RowID
+	}
+	children = append(children, tableChild{page: db.writePage(pageTypeTableLeaf, cells, 0), maxRowID: maxRowID})
+
+	for len(children) > 1 {
+		children = db.writeTableInteriors(children)
+	}
+
+	return children[0].page, nil
+}
+
+// writeTableInteriors writes the level of interior pages above the children, spreading them evenly.
+func (db *database) writeTableInteriors(children []tableChild) []tableChild {
+	// a cell is a child page number and a rowid of at most 9 bytes, the last child of a page is its right child
+	maxCells := (pageSize - interiorHeaderSize) / (4 + 9 + 2)
+	pages := (len(children) + maxCells) / (maxCells + 1)
+
+	var parents []tableChild
+	for p := 0; p < pages; p++ {
+		group := children[p*len(children)/pages : (p+1)*len(children)/pages]
+		var cells [][]byte
+		for _, child := range group[:len(group)-1] {
+			cell := appendUint32(nil, child.page)
+			cells = append(cells, appendVarint(cell, uint64(child.maxRowID)))
+		}
+
+		last := group[len(group)-1]
+		parents = append(parents, tableChild{page: db.writePage(pageTypeTableInterior, cells, last.page), maxRowID: last.maxRowID})
+	}
+
+	return parents
+}
+
+func (db *database) tableLeafCell(row sqliteRow) ([]byte, error) {
+	payload, err := encodeRecord(row.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	cell := appendVarint(nil, uint64(len(payload)))
+	cell = appendVarint(cell, uint64(row.RowID))
+	local := localPayloadSize(len(payload))
+	cell = append(cell, payload[:local]...)
+	if local < len(payload) {
+		cell = appendUint32(cell, db.writeOverflow(payload[local:]))
+	}
+
+	return cell, nil
+}
+
+func localPayloadSize(size int) int {
+	if size <= maxLocalPayload {
+		return size
+	}
+
+	local := minLocalPayload + (size-minLocalPayload)%(pageSize-4)
+	if local <= maxLocalPayload {
+		return local
+	}
+	return minLocalPayload
+}
+
+// writeOverflow writes the data to a chain of overflow pages and returns the first one.
+func (db *database) writeOverflow(data []byte) uint32 {
+	var first uint32
+	var previous []byte
+	for len(data) > 0 {
+		number, page := db.allocate()
+		if previous == nil {
+			first = number
+		} else {
+			binary.BigEndian.PutUint32(previous, number)
+		}
+
+		n := copy(page[4:], data)
+		data = data[n:]
+		previous = page
+	}
+
+	return first
+}
+
+// writeIndex writes an index b-tree of the columns of the rows and returns its root page.
+func (db *database) writeIndex(rows []sqliteRow, columns []int) (uint32, error) {
+	keys := make([][]int64, len(rows))
+	for i, row := range rows {
+		for _, column := range columns {
+			if column >= len(row.Values) {
+				return 0, fmt.Errorf("row %d has no column %d", row.RowID, column)
+			}
+			value, ok := integerValue(row.Values[column])
+			if !ok {
+				return 0, fmt.Errorf("only integer columns can be indexed, row %d has %T in column %d", row.RowID, row.Values[column], column)
+			}
+			keys[i] = append(keys[i], value)
+		}
+		keys[i] = append(keys[i], row.RowID)
+	}
+	sort.Slice(keys, func(i int, j int) bool {
+		for k := range keys[i] {
+			if keys[i][k] != keys[j][k] {
+				return keys[i][k] < keys[j][k]
+			}
+		}
+		return false
+	})
+
+	// unlike tables, interior pages of an index hold keys too: the last key of a full leaf moves up between
+	// the leaf and the next one
+	var children []uint32
+	var separators [][]byte
+	var cells [][]byte
+	used := leafHeaderSize
+	for _, key := range keys {
+		values := make([]interface{}, len(key))
+		for i, value := range key {
+			values[i] = value
+		}
+		payload, err := encodeRecord(values)
+		if err != nil {
+			return 0, err
+		}
+		if len(payload) > maxIndexPayload {
+			return 0, fmt.Errorf("index key of %d bytes is too large", len(payload))
+		}
+		cell := append(appendVarint(nil, uint64(len(payload))), payload...)
+
+		if used+len(cell)+2 > pageSize {
+			last := len(cells) - 1
+			children = append(children, db.writePage(pageTypeIndexLeaf, cells[:last], 0))
+			separators = append(separators, cells[last])
+			cells, used = nil, leafHeaderSize
+		}
+		cells = append(cells, cell)
+		used += len(cell) + 2
+	}
+	children = append(children, db.writePage(pageTypeIndexLeaf, cells, 0))
+
+	for len(children) > 1 {
+		children, separators = db.writeIndexInteriors(children, separators)
+	}
+
+	return children[0], nil
+}
+
+// writeIndexInteriors writes the level of interior pages above the children, separators[i] is the key between
+// children[i] and children[i+1]. It returns the pages and the keys between them.
+func (db *database) writeIndexInteriors(children []uint32, separators [][]byte) ([]uint32, [][]byte) {
+	var parents []uint32
+	var parentSeparators [][]byte
+	var cells [][]byte
+	first := 0
+	used := interiorHeaderSize
+	for i, separator := range separators {
+		cell := append(appendUint32(nil, children[i]), separator...)
+		if used+len(cell)+2 > pageSize {
+			// the child of the last cell becomes the right child and its key moves up
+			last := len(cells) - 1
+			parents = append(parents, db.writePage(pageTypeIndexInterior, cells[:last], children[first+last]))
+			parentSeparators = append(parentSeparators, separators[first+last])
+			cells, first, used = nil, i, interiorHeaderSize
+		}
+		cells = append(cells, cell)
+		used += len(cell) + 2
+	}
+	parents = append(parents, db.writePage(pageTypeIndexInterior, cells, children[len(children)-1]))
+
+	return parents, parentSeparators
+}
+
+func integerValue(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// encodeRecord encodes the values in the record format: a header with the serial type of every value followed by the values.
+func encodeRecord(values []interface{}) ([]byte, error) {
+	var header []byte
+	var body []byte
+	for _, value := range values {
+		if v, ok := integerValue(value); ok {
+			serialType, size := integerSerialType(v)
+			header = appendVarint(header, serialType)
+			for i := size - 1; i >= 0; i-- {
+				body = append(body, byte(v>>(8*uint(i))))
+			}
+			continue
+		}
+
+		switch v := value.(type) {
+		case nil:
+			header = appendVarint(header, 0)
+		case float64:
+			header = appendVarint(header, 7)
+			body = appendUint64(body, math.Float64bits(v))
+		case string:
+			header = appendVarint(header, uint64(2*len(v)+13))
+			body = append(body, v...)
+		case []byte:
+			header = appendVarint(header, uint64(2*len(v)+12))
+			body = append(body, v...)
+		default:
+			return nil, fmt.Errorf("unsupported value type: %T", value)
+		}
+	}
+
+	// the header size includes its own varint
+	size := len(header) + 1
+	for len(header)+varintLen(uint64(size)) != size {
+		size = len(header) + varintLen(uint64(size))
+	}
+
+	record := appendVarint(nil, uint64(size))
+	record = append(record, header...)
+	return append(record, body...), nil
+}
+
+// integerSerialType returns the serial type of the smallest encoding of the integer and its size in bytes.
+func integerSerialType(v int64) (uint64, int) {
+	switch {
+	case v == 0:
+		return 8, 0
+	case v == 1:
+		return 9, 0
+	case v >= -1<<7 && v < 1<<7:
+		return 1, 1
+	case v >= -1<<15 && v < 1<<15:
+		return 2, 2
+	case v >= -1<<23 && v < 1<<23:
+		return 3, 3
+	case v >= -1<<31 && v < 1<<31:
+		return 4, 4
+	case v >= -1<<47 && v < 1<<47:
+		return 5, 6
+	default:
+		return 6, 8
+	}
+}
+
+// appendVarint appends the big-endian variable length integer: 7 bits in every byte with the high bit set
+// on all but the last one, the ninth byte has all 8 bits.
+func appendVarint(buf []byte, v uint64) []byte {
+	if v > 1<<56-1 {
+		var varint [9]byte
+		varint[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			varint[i] = byte(v&0x7F) | 0x80
+			v >>= 7
+		}
+		return append(buf, varint[:]...)
+	}
+
+	n := varintLen(v)
+	for i := n - 1; i >= 0; i-- {
+		b := byte(v>>(7*uint(i))) & 0x7F
+		if i > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+	}
+	return buf
+}
+
+func varintLen(v uint64) int {
+	if v > 1<<56-1 {
+		return 9
+	}
+	n := 1
+	for v > 0x7F {
+		v >>= 7
+		n++
+	}
+	return n
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	return append(buf, b[:]...)
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	return append(buf, b[:]...)
+}
@@ -0,0 +1,236 @@
+package audio
+
+import (
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/midi"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"math"
+	"sort"
+)
+
+const (
+	minRelease = 0.02
+	maxRelease = 2.0
+)
+
+// NoteEvent is a single note to render. Start and Duration are in seconds, Duration doesn't include the release.
+type NoteEvent struct {
+	Start     float64
+	Duration  float64
+	Key       int
+	Velocity  int
+	Frequency float64
+}
+
+// Instrument renders a single note into mono samples in the [-1, 1] range starting at the note start.
+type Instrument interface {
+	Render(event NoteEvent, sampleRate int) []float64
+}
+
+// Options configure audio rendering. Durations are in seconds, Peak is the normalization target in the [0, 1] range.
+type Options struct {
+	SampleRate   int
+	Style        midi.PlaybackStyle
+	Duration     float64
+	ArpeggioStep float64
+	Gap          float64
+	Velocity     int
+	Tuning       notes.Tuning
+	Normalize    bool
+	Peak         float64
+	FadeIn       float64
+	FadeOut      float64
+}
+
+var DefaultOptions = Options{
+	SampleRate:   44100,
+	Style:        midi.PlaybackStyleBlock,
+	Duration:     1.5,
+	ArpeggioStep: 0.6,
+	Gap:          0.3,
+	Velocity:     90,
+	Tuning:       notes.DefaultTuning,
+	Normalize:    true,
+	Peak:         0.89,
+	FadeIn:       0.005,
+	FadeOut:      0.05,
+}
+
+// Events schedules sonorities one after another using the playback style.
+func Events(sonorities []midi.Sonority, opts Options) []NoteEvent {
+	var events []NoteEvent
+	start := 0.0
+	for _, sonority := range sonorities {
+		sonorityNotes := make([]notes.Note, len(sonority.Notes))
+		copy(sonorityNotes, sonority.Notes)
+		sort.SliceStable(sonorityNotes, func(i int, j int) bool {
+			if opts.Style == midi.PlaybackStyleArpeggioDown {
+				return sonorityNotes[i].MIDI() > sonorityNotes[j].MIDI()
+			}
+			return sonorityNotes[i].MIDI() < sonorityNotes[j].MIDI()
+		})
+
+		end := start + opts.Duration
+		if opts.Style != midi.PlaybackStyleBlock && len(sonorityNotes) > 1 {
+			end += opts.ArpeggioStep * float64(len(sonorityNotes)-1)
+		}
+
+		for i, n := range sonorityNotes {
+			noteStart := start
+			if opts.Style != midi.PlaybackStyleBlock {
+				noteStart += float64(i) * opts.ArpeggioStep
+			}
+			events = append(events, NoteEvent{
+				Start:     noteStart,
+				Duration:  end - noteStart,
+				Key:       n.MIDI(),
+				Velocity:  opts.Velocity,
+				Frequency: n.Frequency(opts.Tuning),
+			})
+		}
+
+		start = end + opts.Gap
+	}
+
+	return events
+}
+
+// Render mixes the events into mono samples, normalizes them and applies fades.
+func Render(instrument Instrument, events []NoteEvent, opts Options) []float64 {
+	var mix []float64
+	for _, event := range events {
+		offset := int(math.Round(event.Start * float64(opts.SampleRate)))
+		rendered := instrument.Render(event, opts.SampleRate)
+		if needed := offset + len(rendered); needed > len(mix) {
+			mix = append(mix, make([]float64, needed-len(mix))...)
+		}
+		for i, sample := range rendered {
+			mix[offset+i] += sample
+		}
+	}
+
+	if opts.Normalize {
+		Normalize(mix, opts.Peak)
+	}
+	Fade(mix, opts.SampleRate, opts.FadeIn, opts.FadeOut)
+
+	return mix
+}
+
+func RenderSonorities(instrument Instrument, sonorities []midi.Sonority, opts Options) []float64 {
+	return Render(instrument, Events(sonorities, opts), opts)
+}
+
+func Normalize(samples []float64, peak float64) {
+	max := 0.0
+	for _, s := range samples {
+		max = math.Max(max, math.Abs(s))
+	}
+	if max == 0 {
+		return
+	}
+
+	for i := range samples {
+		samples[i] *= peak / max
+	}
+}
+
+func Fade(samples []float64, sampleRate int, fadeIn float64, fadeOut float64) {
+	fadeInSamples := int(fadeIn * float64(sampleRate))
+	for i := 0; i < fadeInSamples && i < len(samples); i++ {
+		samples[i] *= float64(i) / float64(fadeInSamples)
+	}
+
+	fadeOutSamples := int(fadeOut * float64(sampleRate))
+	for i := 0; i < fadeOutSamples && i < len(samples); i++ {
+		samples[len(samples)-1-i] *= float64(i) / float64(fadeOutSamples)
+	}
+}
+
+func velocityGain(velocity int) float64 {
+	v := float64(velocity) / 127
+	return v * v
+}
+
+// releaseEnvelope returns the gain of the i-th sample of a note held for heldSamples.
+func releaseEnvelope(i int, heldSamples int, release float64, sampleRate int) float64 {
+	if i < heldSamples {
+		return 1
+	}
+
+	t := float64(i-heldSamples) / float64(sampleRate)
+	return math.Max(0, 1-t/release)
+}
+
+// Synth is an additive synthesizer used when no SoundFont is available. It sounds like a soft electric piano.
+type Synth struct {
+	Harmonics []float64
+	Attack    float64
+	Decay     float64
+	Release   float64
+}
+
+var DefaultSynth = Synth{
+	Harmonics: []float64{1, 0.5, 0.25, 0.15, 0.08, 0.05},
+	Attack:    0.01,
+	Decay:     1.2,
+	Release:   0.3,
+}
+
+func (s Synth) Render(event NoteEvent, sampleRate int) []float64 {
+	heldSamples := int(event.Duration * float64(sampleRate))
+	totalSamples := heldSamples + int(s.Release*float64(sampleRate))
+	out := make([]float64, totalSamples)
+
+	gain := velocityGain(event.Velocity) / harmonicsSum(s.Harmonics)
+	for i := 0; i < totalSamples; i++ {
+		t := float64(i) / float64(sampleRate)
+		sample := 0.0
+		for h, amplitude := range s.Harmonics {
+			frequency := event.Frequency * float64(h+1)
+			if frequency >= float64(sampleRate)/2 {
+				break
+			}
+			// higher harmonics fade out faster, like in struck strings
+			sample += amplitude * math.Exp(-t*float64(h+1)/s.Decay) * math.Sin(2*math.Pi*frequency*t)
+		}
+
+		envelope := releaseEnvelope(i, heldSamples, s.Release, sampleRate)
+		if s.Attack > 0 && t < s.Attack {
+			envelope *= t / s.Attack
+		}
+		out[i] = sample * gain * envelope
+	}
+
+	return out
+}
+
+func harmonicsSum(harmonics []float64) float64 {
+	sum := 0.0
+	for _, h := range harmonics {
+		sum += h
+	}
+	if sum == 0 {
+		return 1
+	}
+	return sum
+}
+
+// LoadInstrument returns a SoundFont sampler for the given preset, or the built-in synth when soundFontPath is empty.
+func LoadInstrument(soundFontPath string, bank int, program int) (Instrument, error) {
+	if soundFontPath == "" {
+		return DefaultSynth, nil
+	}
+
+	sf, err := LoadSoundFont(soundFontPath)
+	if err != nil {
+		return nil, err
+	}
+
+	sampler, err := sf.Sampler(bank, program)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load instrument from %s: %v", soundFontPath, err)
+	}
+
+	return sampler, nil
+}
@@ -0,0 +1,157 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/lsierant/notes-gen/pkg/midi"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func chunk(id string, data []byte) []byte {
+	buf := bytes.Buffer{}
+	buf.WriteString(id)
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	if len(data)%2 == 1 {
+		buf.WriteByte(0)
+	}
+	return buf.Bytes()
+}
+
+func list(listType string, chunks ...[]byte) []byte {
+	return chunk("LIST", append([]byte(listType), bytes.Join(chunks, nil)...))
+}
+
+func name20(name string) []byte {
+	return append([]byte(name), make([]byte, 20-len(name))...)
+}
+
+func le(values ...interface{}) []byte {
+	buf := bytes.Buffer{}
+	for _, v := range values {
+		_ = binary.Write(&buf, binary.LittleEndian, v)
+	}
+	return buf.Bytes()
+}
+
+// testSoundFont builds a SF2 with a single looped sine wave sample of A4 at 22050 Hz.
+func testSoundFont() []byte {
+	return soundFont(22050, 22050, 0)
+}
+
+// soundFont builds the sine wave SF2 with the sample end and loop end at end and the instrument zone generator
+// offsetting the start and the loop start by offset.
+func soundFont(length int, end uint32, offset int16) []byte {
+	const sampleRate = 22050
+	samples := make([]int16, length+46)
+	for i := 0; i < length; i++ {
+		samples[i] = int16(16000 * math.Sin(2*math.Pi*440*float64(i)/sampleRate))
+	}
+
+	phdr := bytes.Join([][]byte{
+		name20("Sine"), le(uint16(0), uint16(0), uint16(0), uint32(0), uint32(0), uint32(0)),
+		name20("EOP"), le(uint16(0), uint16(0), uint16(1), uint32(0), uint32(0), uint32(0)),
+	}, nil)
+	pbag := le(uint16(0), uint16(0), uint16(1), uint16(0))
+	pgen := le(uint16(genInstrument), uint16(0), uint16(0), uint16(0))
+	inst := bytes.Join([][]byte{name20("Sine"), le(uint16(0)), name20("EOI"), le(uint16(1))}, nil)
+	ibag := le(uint16(0), uint16(0), uint16(4), uint16(0))
+	igen := le(uint16(genSampleModes), uint16(1), uint16(genStartAddrsOffset), offset, uint16(genStartloopAddrsOffset), offset,
+		uint16(genSampleID), uint16(0), uint16(0), uint16(0))
+	shdr := bytes.Join([][]byte{
+		name20("sine"), le(uint32(0), end, uint32(0), end, uint32(sampleRate), uint8(69), int8(0), uint16(0), uint16(1)),
+		name20("EOS"), make([]byte, 26),
+	}, nil)
+
+	return chunk("RIFF", bytes.Join([][]byte{
+		[]byte("sfbk"),
+		list("INFO", chunk("ifil", le(uint16(2), uint16(1)))),
+		list("sdta", chunk("smpl", le(samples))),
+		list("pdta", chunk("phdr", phdr), chunk("pbag", pbag), chunk("pmod", make([]byte, 10)), chunk("pgen", pgen),
+			chunk("inst", inst), chunk("ibag", ibag), chunk("imod", make([]byte, 10)), chunk("igen", igen), chunk("shdr", shdr)),
+	}, nil))
+}
+
+// zeroCrossings estimates the frequency of a mono signal.
+func zeroCrossings(samples []float64, sampleRate int) float64 {
+	crossings := 0
+	for i := 1; i < len(samples); i++ {
+		if samples[i-1] < 0 && samples[i] >= 0 {
+			crossings++
+		}
+	}
+	return float64(crossings) / (float64(len(samples)) / float64(sampleRate))
+}
+
+func TestSampler(t *testing.T) {
+	sf, err := ParseSoundFont(testSoundFont())
+	assert.NoError(t, err)
+	assert.Len(t, sf.Presets, 1)
+
+	sampler, err := sf.Sampler(0, 0)
+	assert.NoError(t, err)
+	_, err = sf.Sampler(0, 1)
+	assert.Error(t, err)
+
+	e4, _ := notes.ParseNote("E5")
+	samples := sampler.Render(NoteEvent{Duration: 1, Key: e4.MIDI(), Velocity: 127, Frequency: e4.Frequency(notes.DefaultTuning)}, 44100)
+	assert.True(t, len(samples) > 44100)
+	assert.InDelta(t, e4.Frequency(notes.DefaultTuning), zeroCrossings(samples[:44100], 44100), 3)
+}
+
+func TestSamplerOutOfRange(t *testing.T) {
+	e4, _ := notes.ParseNote("E5")
+	event := NoteEvent{Duration: 1, Key: e4.MIDI(), Velocity: 127, Frequency: e4.Frequency(notes.DefaultTuning)}
+
+	// the sample ends past the sample data, the loop is dropped and the voice stops at the end of the data
+	sf, err := ParseSoundFont(soundFont(22050, 1<<20, 0))
+	assert.NoError(t, err)
+	sampler, err := sf.Sampler(0, 0)
+	assert.NoError(t, err)
+	samples := sampler.Render(event, 44100)
+	assert.NotEmpty(t, samples)
+	assert.True(t, len(samples) < 44100)
+
+	// the offset moves the start before the sample data
+	sf, err = ParseSoundFont(soundFont(22050, 22050, -100))
+	assert.NoError(t, err)
+	sampler, err = sf.Sampler(0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, sampler.Render(event, 44100))
+}
+
+func TestSynthAndRender(t *testing.T) {
+	a4, _ := notes.ParseNote("A4")
+	c5, _ := notes.ParseNote("C5")
+
+	opts := DefaultOptions
+	opts.SampleRate = 8000
+	opts.Style = midi.PlaybackStyleArpeggioUp
+	sonorities := []midi.Sonority{{Notes: []notes.Note{c5, a4}}}
+
+	events := Events(sonorities, opts)
+	assert.Len(t, events, 2)
+	assert.Equal(t, a4.MIDI(), events[0].Key)
+	assert.InDelta(t, opts.ArpeggioStep, events[1].Start, 0.0001)
+
+	first := RenderSonorities(DefaultSynth, sonorities, opts)
+	second := RenderSonorities(DefaultSynth, sonorities, opts)
+	assert.Equal(t, first, second)
+
+	peak := 0.0
+	for _, s := range first {
+		peak = math.Max(peak, math.Abs(s))
+	}
+	assert.InDelta(t, opts.Peak, peak, 0.0001)
+	assert.Equal(t, 0.0, first[0])
+	assert.Equal(t, 0.0, first[len(first)-1])
+
+	buf := bytes.Buffer{}
+	assert.NoError(t, WriteWAV(&buf, first, opts.SampleRate))
+	assert.Equal(t, []byte("RIFF"), buf.Bytes()[0:4])
+	assert.Equal(t, []byte("WAVE"), buf.Bytes()[8:12])
+	assert.Equal(t, 44+2*len(first), buf.Len())
+}
@@ -0,0 +1,389 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strings"
+)
+
+// SoundFont generator operators used by the sampler, see the SoundFont 2.04 specification, section 8.1.2.
+const (
+	genStartAddrsOffset       = 0
+	genEndAddrsOffset         = 1
+	genStartloopAddrsOffset   = 2
+	genEndloopAddrsOffset     = 3
+	genStartAddrsCoarseOffset = 4
+	genEndAddrsCoarseOffset   = 12
+	genReleaseVolEnv          = 38
+	genInstrument             = 41
+	genKeyRange               = 43
+	genVelRange               = 44
+	genStartloopCoarseOffset  = 45
+	genInitialAttenuation     = 48
+	genEndloopCoarseOffset    = 50
+	genCoarseTune             = 51
+	genFineTune               = 52
+	genSampleID               = 53
+	genSampleModes            = 54
+	genOverridingRootKey      = 58
+)
+
+type sfZone struct {
+	generators map[uint16]uint16
+}
+
+func (z sfZone) has(op uint16) bool {
+	_, ok := z.generators[op]
+	return ok
+}
+
+func (z sfZone) signed(op uint16, defaultValue int) int {
+	if v, ok := z.generators[op]; ok {
+		return int(int16(v))
+	}
+	return defaultValue
+}
+
+func (z sfZone) inRange(op uint16, value int) bool {
+	v, ok := z.generators[op]
+	if !ok {
+		return true
+	}
+	return value >= int(v&0xFF) && value <= int(v>>8)
+}
+
+type sfPreset struct {
+	Name    string
+	Program int
+	Bank    int
+	zones   []sfZone
+}
+
+type sfInstrument struct {
+	name  string
+	zones []sfZone
+}
+
+type sfSampleHeader struct {
+	name            string
+	start           int
+	end             int
+	startLoop       int
+	endLoop         int
+	sampleRate      int
+	originalPitch   int
+	pitchCorrection int
+}
+
+// SoundFont holds the parsed contents of a SF2 file needed to play its presets.
+type SoundFont struct {
+	Presets     []sfPreset
+	instruments []sfInstrument
+	headers     []sfSampleHeader
+	samples     []int16
+}
+
+func LoadSoundFont(path string) (*SoundFont, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read soundfont %s: %v", path, err)
+	}
+
+	sf, err := ParseSoundFont(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse soundfont %s: %v", path, err)
+	}
+
+	return sf, nil
+}
+
+type riffChunk struct {
+	id   string
+	data []byte
+}
+
+func readChunks(data []byte) ([]riffChunk, error) {
+	var chunks []riffChunk
+	for len(data) >= 8 {
+		id := string(data[0:4])
+		size := int(binary.LittleEndian.Uint32(data[4:8]))
+		if 8+size > len(data) {
+			return nil, fmt.Errorf("chunk %s is truncated", id)
+		}
+		chunks = append(chunks, riffChunk{id: id, data: data[8 : 8+size]})
+		data = data[8+size:]
+		if size%2 == 1 && len(data) > 0 {
+			data = data[1:]
+		}
+	}
+
+	return chunks, nil
+}
+
+func ParseSoundFont(data []byte) (*SoundFont, error) {
+	top, err := readChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(top) == 0 || top[0].id != "RIFF" || len(top[0].data) < 4 || string(top[0].data[0:4]) != "sfbk" {
+		return nil, fmt.Errorf("not a SF2 file")
+	}
+
+	lists, err := readChunks(top[0].data[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	subChunks := map[string][]byte{}
+	for _, list := range lists {
+		if list.id != "LIST" || len(list.data) < 4 {
+			continue
+		}
+		chunks, err := readChunks(list.data[4:])
+		if err != nil {
+			return nil, err
+		}
+		for _, chunk := range chunks {
+			subChunks[chunk.id] = chunk.data
+		}
+	}
+
+	for _, id := range []string{"smpl", "phdr", "pbag", "pgen", "inst", "ibag", "igen", "shdr"} {
+		if _, ok := subChunks[id]; !ok {
+			return nil, fmt.Errorf("missing %s chunk", id)
+		}
+	}
+
+	sf := &SoundFont{}
+	smpl := subChunks["smpl"]
+	sf.samples = make([]int16, len(smpl)/2)
+	if err := binary.Read(bytes.NewReader(smpl[:len(sf.samples)*2]), binary.LittleEndian, sf.samples); err != nil {
+		return nil, fmt.Errorf("failed to read samples: %v", err)
+	}
+
+	pgen := readGenerators(subChunks["pgen"])
+	pbag := readBags(subChunks["pbag"])
+	igen := readGenerators(subChunks["igen"])
+	ibag := readBags(subChunks["ibag"])
+
+	phdr := subChunks["phdr"]
+	for i := 0; i+2*38 <= len(phdr); i += 38 {
+		first := int(binary.LittleEndian.Uint16(phdr[i+24:]))
+		last := int(binary.LittleEndian.Uint16(phdr[i+38+24:]))
+		sf.Presets = append(sf.Presets, sfPreset{
+			Name:    cString(phdr[i : i+20]),
+			Program: int(binary.LittleEndian.Uint16(phdr[i+20:])),
+			Bank:    int(binary.LittleEndian.Uint16(phdr[i+22:])),
+			zones:   readZones(pbag, pgen, first, last),
+		})
+	}
+
+	inst := subChunks["inst"]
+	for i := 0; i+2*22 <= len(inst); i += 22 {
+		first := int(binary.LittleEndian.Uint16(inst[i+20:]))
+		last := int(binary.LittleEndian.Uint16(inst[i+22+20:]))
+		sf.instruments = append(sf.instruments, sfInstrument{
+			name:  cString(inst[i : i+20]),
+			zones: readZones(ibag, igen, first, last),
+		})
+	}
+
+	shdr := subChunks["shdr"]
+	for i := 0; i+46 <= len(shdr); i += 46 {
+		sf.headers = append(sf.headers, sfSampleHeader{
+			name:            cString(shdr[i : i+20]),
+			start:           int(binary.LittleEndian.Uint32(shdr[i+20:])),
+			end:             int(binary.LittleEndian.Uint32(shdr[i+24:])),
+			startLoop:       int(binary.LittleEndian.Uint32(shdr[i+28:])),
+			endLoop:         int(binary.LittleEndian.Uint32(shdr[i+32:])),
+			sampleRate:      int(binary.LittleEndian.Uint32(shdr[i+36:])),
+			originalPitch:   int(shdr[i+40]),
+			pitchCorrection: int(int8(shdr[i+41])),
+		})
+	}
+
+	return sf, nil
+}
+
+type sfGenerator struct {
+	op     uint16
+	amount uint16
+}
+
+func readGenerators(data []byte) []sfGenerator {
+	var gens []sfGenerator
+	for i := 0; i+4 <= len(data); i += 4 {
+		gens = append(gens, sfGenerator{op: binary.LittleEndian.Uint16(data[i:]), amount: binary.LittleEndian.Uint16(data[i+2:])})
+	}
+	return gens
+}
+
+func readBags(data []byte) []int {
+	var bags []int
+	for i := 0; i+4 <= len(data); i += 4 {
+		bags = append(bags, int(binary.LittleEndian.Uint16(data[i:])))
+	}
+	return bags
+}
+
+func readZones(bags []int, gens []sfGenerator, firstBag int, lastBag int) []sfZone {
+	var zones []sfZone
+	for b := firstBag; b < lastBag && b+1 < len(bags); b++ {
+		zone := sfZone{generators: map[uint16]uint16{}}
+		for g := bags[b]; g < bags[b+1] && g < len(gens); g++ {
+			zone.generators[gens[g].op] = gens[g].amount
+		}
+		zones = append(zones, zone)
+	}
+	return zones
+}
+
+func cString(data []byte) string {
+	if idx := bytes.IndexByte(data, 0); idx >= 0 {
+		data = data[:idx]
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Sampler plays a single preset of a SoundFont.
+type Sampler struct {
+	font   *SoundFont
+	preset sfPreset
+}
+
+func (sf *SoundFont) Sampler(bank int, program int) (*Sampler, error) {
+	for _, preset := range sf.Presets {
+		if preset.Bank == bank && preset.Program == program && preset.Name != "EOP" {
+			return &Sampler{font: sf, preset: preset}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("preset %d:%d not found in soundfont", bank, program)
+}
+
+// splitGlobalZone returns the global zone (if present) and the remaining zones. A global zone is
+// the first zone without the terminal generator (instrument for presets, sample for instruments).
+func splitGlobalZone(zones []sfZone, terminal uint16) (sfZone, []sfZone) {
+	if len(zones) > 0 && !zones[0].has(terminal) {
+		return zones[0], zones[1:]
+	}
+	return sfZone{generators: map[uint16]uint16{}}, zones
+}
+
+type samplerVoice struct {
+	header      sfSampleHeader
+	start       int
+	end         int
+	loopStart   int
+	loopEnd     int
+	loop        bool
+	rootKey     int
+	tuneCents   float64
+	attenuation float64
+	release     float64
+}
+
+func (s *Sampler) voice(key int, velocity int) (samplerVoice, bool) {
+	presetGlobal, presetZones := splitGlobalZone(s.preset.zones, genInstrument)
+	for _, presetZone := range presetZones {
+		if !presetZone.inRange(genKeyRange, key) || !presetZone.inRange(genVelRange, velocity) {
+			continue
+		}
+		instrumentIdx := presetZone.signed(genInstrument, -1)
+		if instrumentIdx < 0 || instrumentIdx >= len(s.font.instruments) {
+			continue
+		}
+
+		instGlobal, instZones := splitGlobalZone(s.font.instruments[instrumentIdx].zones, genSampleID)
+		for _, zone := range instZones {
+			if !zone.inRange(genKeyRange, key) || !zone.inRange(genVelRange, velocity) {
+				continue
+			}
+			sampleIdx := zone.signed(genSampleID, -1)
+			if sampleIdx < 0 || sampleIdx >= len(s.font.headers) {
+				continue
+			}
+
+			// instrument generators are absolute values, preset generators are added on top of them
+			gen := func(op uint16, defaultValue int) int {
+				value := zone.signed(op, instGlobal.signed(op, defaultValue))
+				return value + presetZone.signed(op, presetGlobal.signed(op, 0))
+			}
+
+			header := s.font.headers[sampleIdx]
+			v := samplerVoice{
+				header:      header,
+				start:       header.start + gen(genStartAddrsOffset, 0) + gen(genStartAddrsCoarseOffset, 0)*32768,
+				end:         header.end + gen(genEndAddrsOffset, 0) + gen(genEndAddrsCoarseOffset, 0)*32768,
+				loopStart:   header.startLoop + gen(genStartloopAddrsOffset, 0) + gen(genStartloopCoarseOffset, 0)*32768,
+				loopEnd:     header.endLoop + gen(genEndloopAddrsOffset, 0) + gen(genEndloopCoarseOffset, 0)*32768,
+				loop:        zone.signed(genSampleModes, instGlobal.signed(genSampleModes, 0))&1 == 1,
+				rootKey:     zone.signed(genOverridingRootKey, instGlobal.signed(genOverridingRootKey, -1)),
+				tuneCents:   float64(gen(genCoarseTune, 0)*100 + gen(genFineTune, 0) + header.pitchCorrection),
+				attenuation: float64(gen(genInitialAttenuation, 0)) / 10,
+				release:     math.Pow(2, float64(gen(genReleaseVolEnv, -12000))/1200),
+			}
+			if v.rootKey < 0 {
+				v.rootKey = header.originalPitch
+				if v.rootKey > 127 {
+					v.rootKey = 60
+				}
+			}
+			// offsets and headers of malformed fonts may point outside of the sample data
+			if v.end > len(s.font.samples) {
+				v.end = len(s.font.samples)
+			}
+			if v.start < 0 || v.start >= v.end {
+				continue
+			}
+			if v.loopStart < 0 || v.loopEnd <= v.loopStart || v.loopEnd > v.end {
+				v.loop = false
+			}
+
+			return v, true
+		}
+	}
+
+	return samplerVoice{}, false
+}
+
+func (s *Sampler) Render(event NoteEvent, sampleRate int) []float64 {
+	v, ok := s.voice(event.Key, event.Velocity)
+	if !ok || v.end <= v.start || v.header.sampleRate <= 0 {
+		return nil
+	}
+
+	release := math.Min(math.Max(v.release, minRelease), maxRelease)
+	rootFrequency := 440 * math.Pow(2, float64(v.rootKey-69)/12)
+	step := event.Frequency / rootFrequency * math.Pow(2, v.tuneCents/1200) * float64(v.header.sampleRate) / float64(sampleRate)
+	gain := math.Pow(10, -v.attenuation/20) * velocityGain(event.Velocity)
+
+	heldSamples := int(event.Duration * float64(sampleRate))
+	totalSamples := heldSamples + int(release*float64(sampleRate))
+	out := make([]float64, 0, totalSamples)
+
+	position := float64(v.start)
+	for i := 0; i < totalSamples; i++ {
+		if v.loop && position >= float64(v.loopEnd) {
+			position -= float64(v.loopEnd - v.loopStart)
+		}
+		idx := int(position)
+		next := idx + 1
+		if v.loop && next >= v.loopEnd {
+			next = v.loopStart
+		}
+		if idx >= v.end || next >= v.end {
+			break
+		}
+		frac := position - float64(idx)
+		sample := (float64(s.font.samples[idx])*(1-frac) + float64(s.font.samples[next])*frac) / 32768
+
+		out = append(out, sample*gain*releaseEnvelope(i, heldSamples, release, sampleRate))
+		position += step
+	}
+
+	return out
+}
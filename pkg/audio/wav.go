@@ -0,0 +1,55 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+)
+
+// WriteWAV writes mono 16-bit PCM samples. Samples outside of [-1, 1] are clipped.
+func WriteWAV(w io.Writer, samples []float64, sampleRate int) error {
+	const bitsPerSample = 16
+	const channels = 1
+	dataSize := len(samples) * channels * bitsPerSample / 8
+
+	bw := bufio.NewWriter(w)
+	header := []interface{}{
+		[]byte("RIFF"), uint32(36 + dataSize), []byte("WAVE"),
+		[]byte("fmt "), uint32(16), uint16(1), uint16(channels), uint32(sampleRate),
+		uint32(sampleRate * channels * bitsPerSample / 8), uint16(channels * bitsPerSample / 8), uint16(bitsPerSample),
+		[]byte("data"), uint32(dataSize),
+	}
+	for _, v := range header {
+		if err := binary.Write(bw, binary.LittleEndian, v); err != nil {
+			return fmt.Errorf("failed to write wav header: %v", err)
+		}
+	}
+
+	pcm := make([]int16, len(samples))
+	for i, s := range samples {
+		pcm[i] = int16(math.Round(math.Max(-1, math.Min(1, s)) * 32767))
+	}
+	if err := binary.Write(bw, binary.LittleEndian, pcm); err != nil {
+		return fmt.Errorf("failed to write wav samples: %v", err)
+	}
+
+	return bw.Flush()
+}
+
+func WriteWAVFile(path string, samples []float64, sampleRate int) error {
+	buf := bytes.Buffer{}
+	if err := WriteWAV(&buf, samples, sampleRate); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), os.FileMode(0660)); err != nil {
+		return fmt.Errorf("failed to write wav file %s: %v", path, err)
+	}
+
+	return nil
+}
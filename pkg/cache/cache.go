@@ -0,0 +1,247 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const entryExtension = ".bin"
+
+// pruneRatio is the part of MaxBytes left after evicting on put
+const pruneRatio = 0.9
+
+// Cache stores rendered images in Dir under the hash of everything they were rendered from. Reading an
+// entry marks it as used, when MaxBytes is exceeded the least recently used entries are evicted.
+type Cache struct {
+	Dir      string
+	MaxBytes int64
+	mutex    sync.Mutex
+	// size is the running total of the entries, counted on the first put with a size limit
+	size  int64
+	sized bool
+}
+
+type Entry struct {
+	Key      string
+	Size     int64
+	LastUsed time.Time
+}
+
+func Open(dir string, maxBytes int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0770); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir %s: %v", dir, err)
+	}
+
+	return &Cache{Dir: dir, MaxBytes: maxBytes}, nil
+}
+
+// Key hashes the parts, each part is length-prefixed so they can't run into each other.
+func Key(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		fmt.Fprintf(hash, "%d:%s", len(part), part)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.Dir, key[:2], key+entryExtension)
+}
+
+func (c *Cache) Get(key string) ([]byte, bool) {
+	path := c.path(key)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return data, true
+}
+
+// Put stores the entry. When the cache grows over MaxBytes, the least recently used entries are evicted
+// until it's down to pruneRatio of MaxBytes, so a full cache isn't listed again on every put.
+func (c *Cache) Put(key string, data []byte) error {
+	path := c.path(key)
+	var previousSize int64
+	if info, err := os.Stat(path); err == nil {
+		previousSize = info.Size()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+		return fmt.Errorf("failed to create cache dir: %v", err)
+	}
+
+	// written next to the entry and renamed, so readers never see a partial file
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), "tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %v", err)
+	}
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("failed to write cache file: %v", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("failed to write cache file: %v", err)
+	}
+	if err := os.Rename(tmpFile.Name(), path); err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("failed to store cache file %s: %v", path, err)
+	}
+
+	if c.MaxBytes <= 0 {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.sized {
+		entries, err := c.Entries()
+		if err != nil {
+			return err
+		}
+		c.size, c.sized = 0, true
+		for _, entry := range entries {
+			c.size += entry.Size
+		}
+	} else {
+		c.size += int64(len(data)) - previousSize
+	}
+
+	if c.size > c.MaxBytes {
+		if _, _, err := c.prune(int64(float64(c.MaxBytes)*pruneRatio), 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Entries returns all entries, the least recently used first.
+func (c *Cache) Entries() ([]Entry, error) {
+	var entries []Entry
+	err := filepath.Walk(c.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// temporary files of other writers are renamed while walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(info.Name(), entryExtension) {
+			return nil
+		}
+
+		entries = append(entries, Entry{
+			Key:      strings.TrimSuffix(info.Name(), entryExtension),
+			Size:     info.Size(),
+			LastUsed: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cache dir %s: %v", c.Dir, err)
+	}
+
+	sort.Slice(entries, func(i int, j int) bool {
+		if !entries[i].LastUsed.Equal(entries[j].LastUsed) {
+			return entries[i].LastUsed.Before(entries[j].LastUsed)
+		}
+		return entries[i].Key < entries[j].Key
+	})
+
+	return entries, nil
+}
+
+func (c *Cache) Remove(key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// the size is counted again on the next put
+	c.sized = false
+	return c.remove(key)
+}
+
+func (c *Cache) remove(key string) error {
+	if err := os.Remove(c.path(key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove cache entry %s: %v", key, err)
+	}
+	return nil
+}
+
+// Prune removes entries not used for longer than maxAge and then the least recently used ones until
+// the cache fits in maxBytes. Zero disables either limit. It returns the number of removed entries and freed bytes.
+func (c *Cache) Prune(maxBytes int64, maxAge time.Duration) (int, int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.prune(maxBytes, maxAge)
+}
+
+func (c *Cache) prune(maxBytes int64, maxAge time.Duration) (int, int64, error) {
+	entries, err := c.Entries()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size
+	}
+
+	removed := 0
+	var freed int64
+	now := time.Now()
+	for _, entry := range entries {
+		expired := maxAge > 0 && now.Sub(entry.LastUsed) > maxAge
+		tooBig := maxBytes > 0 && total > maxBytes
+		if !expired && !tooBig {
+			continue
+		}
+
+		if err := c.remove(entry.Key); err != nil {
+			c.sized = false
+			return removed, freed, err
+		}
+		removed++
+		freed += entry.Size
+		total -= entry.Size
+	}
+
+	c.size, c.sized = total, true
+	return removed, freed, nil
+}
+
+func (c *Cache) Clear() (int, int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.sized = false
+	entries, err := c.Entries()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	removed := 0
+	var freed int64
+	for _, entry := range entries {
+		if err := c.remove(entry.Key); err != nil {
+			return removed, freed, err
+		}
+		removed++
+		freed += entry.Size
+	}
+
+	return removed, freed, nil
+}
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	assert.Equal(t, Key("a", "b"), Key("a", "b"))
+	assert.NotEqual(t, Key("ab", ""), Key("a", "b"))
+	assert.Len(t, Key("source"), 64)
+}
+
+func TestGetPutAndPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-test-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Open(dir, 0)
+	assert.NoError(t, err)
+
+	_, ok := c.Get(Key("missing"))
+	assert.False(t, ok)
+
+	keys := []string{Key("1"), Key("2"), Key("3")}
+	for i, key := range keys {
+		assert.NoError(t, c.Put(key, []byte("0123456789")))
+		// entries are ordered by the modification time, make it distinct
+		used := time.Now().Add(time.Duration(i-10) * time.Hour)
+		assert.NoError(t, os.Chtimes(c.path(key), used, used))
+	}
+
+	data, ok := c.Get(keys[0])
+	assert.True(t, ok)
+	assert.Equal(t, "0123456789", string(data))
+
+	entries, err := c.Entries()
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, []string{keys[1], keys[2], keys[0]}, []string{entries[0].Key, entries[1].Key, entries[2].Key})
+	}
+
+	// the least recently used entry goes first
+	removed, freed, err := c.Prune(25, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.Equal(t, int64(10), freed)
+	_, ok = c.Get(keys[1])
+	assert.False(t, ok)
+
+	removed, _, err = c.Prune(0, 5*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	_, ok = c.Get(keys[0])
+	assert.True(t, ok)
+
+	// a size limit evicts on put
+	used := time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes(c.path(keys[0]), used, used))
+	c.MaxBytes = 15
+	assert.NoError(t, c.Put(Key("4"), []byte("0123456789")))
+	entries, err = c.Entries()
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, Key("4"), entries[0].Key)
+	}
+
+	// puts under the limit only update the running size, over the limit the cache is pruned below it
+	c.MaxBytes = 35
+	for _, key := range []string{Key("5"), Key("6"), Key("4")} {
+		assert.NoError(t, c.Put(key, []byte("0123456789")))
+	}
+	assert.Equal(t, int64(30), c.size)
+	used = time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes(c.path(Key("5")), used, used))
+	assert.NoError(t, c.Put(Key("7"), []byte("0123456789")))
+	assert.Equal(t, int64(30), c.size)
+	entries, err = c.Entries()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	_, ok = c.Get(Key("5"))
+	assert.False(t, ok)
+}
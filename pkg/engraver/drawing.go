@@ -0,0 +1,207 @@
+package engraver
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Drawing coordinates are in staff spaces with y growing downwards. Transparent leaves out the white background.
+type Drawing struct {
+	MinX        float64
+	MinY        float64
+	Width       float64
+	Height      float64
+	Shapes      []Shape
+	Transparent bool
+}
+
+type point struct {
+	x float64
+	y float64
+}
+
+// segment is a path command: 'M' and 'L' have one point, 'C' has two control points and the end point, 'Z' has none.
+type segment struct {
+	op     byte
+	points []point
+}
+
+// Shape is a filled path or, when StrokeWidth is set, a stroked one. Color is an SVG hex color, black when empty.
+type Shape struct {
+	segments    []segment
+	StrokeWidth float64
+	Color       string
+}
+
+type pathBuilder struct {
+	segments []segment
+}
+
+func (b *pathBuilder) moveTo(x float64, y float64) *pathBuilder {
+	b.segments = append(b.segments, segment{op: 'M', points: []point{{x, y}}})
+	return b
+}
+
+func (b *pathBuilder) lineTo(x float64, y float64) *pathBuilder {
+	b.segments = append(b.segments, segment{op: 'L', points: []point{{x, y}}})
+	return b
+}
+
+func (b *pathBuilder) curveTo(x1 float64, y1 float64, x2 float64, y2 float64, x float64, y float64) *pathBuilder {
+	b.segments = append(b.segments, segment{op: 'C', points: []point{{x1, y1}, {x2, y2}, {x, y}}})
+	return b
+}
+
+func (b *pathBuilder) close() *pathBuilder {
+	b.segments = append(b.segments, segment{op: 'Z'})
+	return b
+}
+
+func (b *pathBuilder) fill() Shape {
+	return Shape{segments: b.segments}
+}
+
+func (b *pathBuilder) stroke(width float64) Shape {
+	return Shape{segments: b.segments, StrokeWidth: width}
+}
+
+func rect(x float64, y float64, width float64, height float64) Shape {
+	b := &pathBuilder{}
+	return b.moveTo(x, y).lineTo(x+width, y).lineTo(x+width, y+height).lineTo(x, y+height).close().fill()
+}
+
+// polygon returns a filled shape through the given points
+func polygon(points ...point) Shape {
+	b := &pathBuilder{}
+	b.moveTo(points[0].x, points[0].y)
+	for _, p := range points[1:] {
+		b.lineTo(p.x, p.y)
+	}
+	return b.close().fill()
+}
+
+// ellipse approximates a filled ellipse rotated by angle (in degrees) with four cubic curves.
+func ellipse(cx float64, cy float64, rx float64, ry float64, angle float64) Shape {
+	const k = 0.5522847498
+	rad := angle * math.Pi / 180
+	sin, cos := math.Sin(rad), math.Cos(rad)
+	tr := func(x float64, y float64) (float64, float64) {
+		return cx + x*cos - y*sin, cy + x*sin + y*cos
+	}
+
+	b := &pathBuilder{}
+	x, y := tr(rx, 0)
+	b.moveTo(x, y)
+	quadrants := [][6]float64{
+		{rx, k * ry, k * rx, ry, 0, ry},
+		{-k * rx, ry, -rx, k * ry, -rx, 0},
+		{-rx, -k * ry, -k * rx, -ry, 0, -ry},
+		{k * rx, -ry, rx, -k * ry, rx, 0},
+	}
+	for _, q := range quadrants {
+		x1, y1 := tr(q[0], q[1])
+		x2, y2 := tr(q[2], q[3])
+		x3, y3 := tr(q[4], q[5])
+		b.curveTo(x1, y1, x2, y2, x3, y3)
+	}
+
+	return b.close().fill()
+}
+
+func circle(cx float64, cy float64, r float64) Shape {
+	return ellipse(cx, cy, r, r, 0)
+}
+
+// transform returns the shape scaled around the origin and then moved by dx, dy
+func (s Shape) transform(dx float64, dy float64, scale float64) Shape {
+	result := Shape{StrokeWidth: s.StrokeWidth * scale, Color: s.Color}
+	for _, seg := range s.segments {
+		moved := segment{op: seg.op}
+		for _, p := range seg.points {
+			moved.points = append(moved.points, point{p.x*scale + dx, p.y*scale + dy})
+		}
+		result.segments = append(result.segments, moved)
+	}
+
+	return result
+}
+
+func (s Shape) bounds() (float64, float64, float64, float64) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, seg := range s.segments {
+		for _, p := range seg.points {
+			minX, maxX = math.Min(minX, p.x), math.Max(maxX, p.x)
+			minY, maxY = math.Min(minY, p.y), math.Max(maxY, p.y)
+		}
+	}
+
+	return minX - s.StrokeWidth/2, minY - s.StrokeWidth/2, maxX + s.StrokeWidth/2, maxY + s.StrokeWidth/2
+}
+
+// fit sets the drawing bounds to the shapes with the given padding around them
+func (d *Drawing) fit(padding float64) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, s := range d.Shapes {
+		x1, y1, x2, y2 := s.bounds()
+		minX, minY = math.Min(minX, x1), math.Min(minY, y1)
+		maxX, maxY = math.Max(maxX, x2), math.Max(maxY, y2)
+	}
+
+	if len(d.Shapes) == 0 {
+		minX, minY, maxX, maxY = 0, 0, 0, 0
+	}
+
+	d.MinX, d.MinY = minX-padding, minY-padding
+	d.Width, d.Height = maxX-minX+2*padding, maxY-minY+2*padding
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1000)/1000, 'f', -1, 64)
+}
+
+func (s Shape) pathData() string {
+	buf := bytes.Buffer{}
+	for _, seg := range s.segments {
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteByte(seg.op)
+		for _, p := range seg.points {
+			fmt.Fprintf(&buf, " %s %s", formatFloat(p.x), formatFloat(p.y))
+		}
+	}
+
+	return buf.String()
+}
+
+// SVG returns the drawing as an SVG document, pixelsPerSpace sets its width and height.
+func (d Drawing) SVG(pixelsPerSpace float64) []byte {
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="%s %s %s %s">`+"\n",
+		formatFloat(d.Width*pixelsPerSpace), formatFloat(d.Height*pixelsPerSpace),
+		formatFloat(d.MinX), formatFloat(d.MinY), formatFloat(d.Width), formatFloat(d.Height))
+	if !d.Transparent {
+		fmt.Fprintf(&buf, `<rect x="%s" y="%s" width="%s" height="%s" fill="white"/>`+"\n",
+			formatFloat(d.MinX), formatFloat(d.MinY), formatFloat(d.Width), formatFloat(d.Height))
+	}
+
+	for _, s := range d.Shapes {
+		color := s.Color
+		if color == "" {
+			color = "#000000"
+		}
+		if s.StrokeWidth > 0 {
+			fmt.Fprintf(&buf, `<path d="%s" fill="none" stroke="%s" stroke-width="%s" stroke-linecap="round" stroke-linejoin="round"/>`+"\n",
+				s.pathData(), color, formatFloat(s.StrokeWidth))
+		} else {
+			fmt.Fprintf(&buf, `<path d="%s" fill="%s"/>`+"\n", s.pathData(), color)
+		}
+	}
+	buf.WriteString("</svg>\n")
+
+	return buf.Bytes()
+}
@@ -0,0 +1,367 @@
+package engraver
+
+import (
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"sort"
+)
+
+// DefaultPixelsPerSpace gives images of roughly the size of LilyPond's 300 dpi output
+const DefaultPixelsPerSpace = 20
+
+// Version changes whenever the output of the engraver does, cached images of older versions aren't used
+const Version = "2"
+
+const (
+	lineThickness    = 0.1
+	stemThickness    = 0.12
+	stemLength       = 3.5
+	ledgerOverhang   = 0.35
+	noteHeadWidth    = 1.18
+	accidentalWidth  = 1.1
+	keyAccidentalGap = 1.25
+	columnSpacing    = 3.0
+	staffDistance    = 10.0
+	padding          = 1.0
+)
+
+type Clef int
+
+const (
+	ClefTreble Clef = iota
+	ClefBass
+)
+
+// bottomLineStep is the diatonic step (octave*7 + letter index) of the bottom line of the staff
+func (c Clef) bottomLineStep() int {
+	if c == ClefBass {
+		return 18
+	}
+	return 30
+}
+
+// Column is a single sonority, Upper and Lower are the notes on the upper and lower staff.
+// Colors are the hex colors of highlighted noteheads by the lilypond symbol of their note.
+type Column struct {
+	Upper  []notes.Note
+	Lower  []notes.Note
+	Colors map[string]string
+}
+
+// Score is a grand staff, bar lines are drawn after every BeatsPerMeasure columns, never when it's 0.
+type Score struct {
+	KeySignature    int
+	UpperClef       Clef
+	LowerClef       Clef
+	Columns         []Column
+	BeatsPerMeasure int
+}
+
+var letterSteps = map[string]int{"c": 0, "d": 1, "e": 2, "f": 3, "g": 4, "a": 5, "b": 6}
+
+// step returns the diatonic step of the note, which gives its vertical position on the staff
+func step(n notes.Note) int {
+	return n.Octave()*7 + letterSteps[n.BaseName]
+}
+
+// treble clef positions of the key signature accidentals, the bass clef ones are two octaves lower
+var sharpSteps = []int{38, 35, 39, 36, 33, 37, 34}
+var flatSteps = []int{34, 37, 33, 36, 32, 35, 31}
+
+type staff struct {
+	clef Clef
+	top  float64
+}
+
+func (s staff) y(step int) float64 {
+	return s.top + 4 - float64(step-s.clef.bottomLineStep())/2
+}
+
+func (s staff) middleStep() int {
+	return s.clef.bottomLineStep() + 4
+}
+
+func (s staff) topLineStep() int {
+	return s.clef.bottomLineStep() + 8
+}
+
+type engraver struct {
+	shapes []Shape
+}
+
+func (e *engraver) add(shapes ...Shape) {
+	e.shapes = append(e.shapes, shapes...)
+}
+
+// Engrave lays out the score on a grand staff with a brace, clefs and the key signature.
+func Engrave(score Score) Drawing {
+	e := &engraver{}
+	staves := []staff{{clef: score.UpperClef, top: 0}, {clef: score.LowerClef, top: staffDistance}}
+
+	x := 1.0
+	for _, s := range staves {
+		e.clef(s, x)
+	}
+	x += 4.0
+
+	for _, s := range staves {
+		e.keySignature(s, score.KeySignature, x)
+	}
+	x += float64(abs(score.KeySignature))*keyAccidentalGap + 1.0
+
+	// accidentals in effect in the current measure, keyed by staff and step
+	accidentals := []map[int]notes.NoteModifier{{}, {}}
+	end := x
+	for i, column := range score.Columns {
+		columnNotes := [][]notes.Note{column.Upper, column.Lower}
+
+		var placements []columnPlacement
+		leftWidth := 0.0
+		for j, s := range staves {
+			placement := placeNotes(s, columnNotes[j], column.Colors, score.KeySignature, accidentals[j])
+			placements = append(placements, placement)
+			if w := placement.leftWidth(); w > leftWidth {
+				leftWidth = w
+			}
+		}
+
+		noteX := x + leftWidth
+		rightWidth := noteHeadWidth / 2
+		for j, s := range staves {
+			if w := e.column(s, placements[j], noteX); w > rightWidth {
+				rightWidth = w
+			}
+		}
+		x = noteX + rightWidth + columnSpacing
+		end = x - columnSpacing + 1.5
+
+		if score.BeatsPerMeasure > 0 && (i+1)%score.BeatsPerMeasure == 0 {
+			barX := x - columnSpacing/2
+			for _, s := range staves {
+				e.add(rect(barX, s.top, lineThickness*1.6, 4))
+			}
+			end = barX + lineThickness*1.6
+			x = barX + 1.5
+			accidentals = []map[int]notes.NoteModifier{{}, {}}
+		}
+	}
+
+	for _, s := range staves {
+		for line := 0; line < 5; line++ {
+			e.add(rect(0, s.top+float64(line)-lineThickness/2, end, lineThickness))
+		}
+	}
+	e.add(rect(0, 0, lineThickness*1.6, staffDistance+4))
+	e.add(brace(-1.4, -0.1, staffDistance+4.1))
+
+	d := Drawing{Shapes: e.shapes}
+	d.fit(padding)
+	return d
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func (e *engraver) clef(s staff, x float64) {
+	if s.clef == ClefBass {
+		e.add(bassClef(x-0.2, s.top+1)...)
+	} else {
+		e.add(trebleClef(x+0.9, s.top+3)...)
+	}
+}
+
+func (e *engraver) keySignature(s staff, keySignature int, x float64) {
+	offset := 0
+	if s.clef == ClefBass {
+		offset = -14
+	}
+
+	for i := 0; i < abs(keySignature); i++ {
+		ax := x + float64(i)*keyAccidentalGap
+		if keySignature > 0 {
+			e.add(sharp(ax, s.y(sharpSteps[i]+offset))...)
+		} else {
+			e.add(flat(ax, s.y(flatSteps[i]+offset))...)
+		}
+	}
+}
+
+type placedNote struct {
+	step       int
+	displaced  bool
+	accidental bool
+	modifier   notes.NoteModifier
+	color      string
+	// accidentalColumn counts from the noteheads to the left
+	accidentalColumn int
+}
+
+type columnPlacement struct {
+	notes             []placedNote
+	stemUp            bool
+	accidentalColumns int
+	leftDisplaced     bool
+}
+
+func (p columnPlacement) leftWidth() float64 {
+	width := noteHeadWidth/2 + 0.2
+	if p.leftDisplaced {
+		width += noteHeadWidth
+	}
+	if p.accidentalColumns > 0 {
+		width += float64(p.accidentalColumns)*accidentalWidth + 0.2
+	}
+	return width
+}
+
+// placeNotes decides the stem direction, which noteheads are moved to the other side of the stem
+// and where accidentals go. It updates the accidentals in effect in the measure.
+func placeNotes(s staff, columnNotes []notes.Note, colors map[string]string, keySignature int, accidentals map[int]notes.NoteModifier) columnPlacement {
+	placement := columnPlacement{}
+	if len(columnNotes) == 0 {
+		return placement
+	}
+
+	for _, n := range columnNotes {
+		st := step(n)
+		current, ok := accidentals[st]
+		if !ok {
+			current = notes.KeySignatureModifier(keySignature, n.BaseName)
+		}
+		placement.notes = append(placement.notes, placedNote{step: st, modifier: n.Modifier, accidental: current != n.Modifier, color: colors[n.LilypondSymbol()]})
+		accidentals[st] = n.Modifier
+	}
+	sort.SliceStable(placement.notes, func(i int, j int) bool {
+		return placement.notes[i].step < placement.notes[j].step
+	})
+
+	lowest, highest := placement.notes[0].step, placement.notes[len(placement.notes)-1].step
+	placement.stemUp = s.middleStep()-lowest > highest-s.middleStep()
+
+	// noteheads a second apart can't share a side of the stem: with the stem up the upper one moves right,
+	// with the stem down the lower one moves left
+	if placement.stemUp {
+		for i := 1; i < len(placement.notes); i++ {
+			prev := placement.notes[i-1]
+			if placement.notes[i].step-prev.step == 1 && !prev.displaced {
+				placement.notes[i].displaced = true
+			}
+		}
+	} else {
+		for i := len(placement.notes) - 2; i >= 0; i-- {
+			next := placement.notes[i+1]
+			if next.step-placement.notes[i].step == 1 && !next.displaced {
+				placement.notes[i].displaced = true
+				placement.leftDisplaced = true
+			}
+		}
+	}
+
+	// accidentals from the top go to the first column they don't collide with
+	var columns [][]int
+	for i := len(placement.notes) - 1; i >= 0; i-- {
+		if !placement.notes[i].accidental {
+			continue
+		}
+		st := placement.notes[i].step
+		column := 0
+		for ; column < len(columns); column++ {
+			collides := false
+			for _, other := range columns[column] {
+				if other-st < 6 {
+					collides = true
+					break
+				}
+			}
+			if !collides {
+				break
+			}
+		}
+		if column == len(columns) {
+			columns = append(columns, nil)
+		}
+		columns[column] = append(columns[column], st)
+		placement.notes[i].accidentalColumn = column
+	}
+	placement.accidentalColumns = len(columns)
+
+	return placement
+}
+
+// column draws the placed notes with the main noteheads at noteX and returns the width right of noteX it uses.
+func (e *engraver) column(s staff, placement columnPlacement, noteX float64) float64 {
+	if len(placement.notes) == 0 {
+		return 0
+	}
+
+	headX := func(n placedNote) float64 {
+		if !n.displaced {
+			return noteX
+		}
+		if placement.stemUp {
+			return noteX + noteHeadWidth - stemThickness
+		}
+		return noteX - noteHeadWidth + stemThickness
+	}
+
+	minX, maxX := noteX, noteX
+	for _, n := range placement.notes {
+		hx := headX(n)
+		if hx < minX {
+			minX = hx
+		}
+		if hx > maxX {
+			maxX = hx
+		}
+		head := noteHead(hx, s.y(n.step))
+		head.Color = n.color
+		e.add(head)
+	}
+
+	accidentalX := minX - noteHeadWidth/2 - 0.2 - accidentalWidth/2
+	for _, n := range placement.notes {
+		if !n.accidental {
+			continue
+		}
+		ax := accidentalX - float64(n.accidentalColumn)*accidentalWidth
+		y := s.y(n.step)
+		switch n.modifier {
+		case notes.NoteModifierSharp:
+			e.add(sharp(ax, y)...)
+		case notes.NoteModifierFlat:
+			e.add(flat(ax, y)...)
+		default:
+			e.add(natural(ax, y)...)
+		}
+	}
+
+	lowest, highest := placement.notes[0].step, placement.notes[len(placement.notes)-1].step
+	for st := s.topLineStep() + 2; st <= highest; st += 2 {
+		e.add(rect(minX-noteHeadWidth/2-ledgerOverhang, s.y(st)-lineThickness/2, maxX-minX+noteHeadWidth+2*ledgerOverhang, lineThickness))
+	}
+	for st := s.clef.bottomLineStep() - 2; st >= lowest; st -= 2 {
+		e.add(rect(minX-noteHeadWidth/2-ledgerOverhang, s.y(st)-lineThickness/2, maxX-minX+noteHeadWidth+2*ledgerOverhang, lineThickness))
+	}
+
+	// stems reach at least the middle line
+	if placement.stemUp {
+		stemX := noteX + noteHeadWidth/2 - stemThickness
+		top := s.y(highest) - stemLength
+		if middle := s.y(s.middleStep()); top > middle {
+			top = middle
+		}
+		e.add(rect(stemX, top, stemThickness, s.y(lowest)-top))
+	} else {
+		stemX := noteX - noteHeadWidth/2
+		bottom := s.y(lowest) + stemLength
+		if middle := s.y(s.middleStep()); bottom < middle {
+			bottom = middle
+		}
+		e.add(rect(stemX, s.y(highest), stemThickness, bottom-s.y(highest)))
+	}
+
+	return maxX - noteX + noteHeadWidth/2
+}
@@ -0,0 +1,150 @@
+package engraver
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"github.com/stretchr/testify/assert"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+)
+
+var chordSource = `
+upper = {
+  \clef treble
+  \once \override Staff.TimeSignature #'transparent = ##t
+  \key fis \minor
+
+	<cis' eis' gis'>4
+	s4
+}
+
+lower = {
+    \once \override Staff.TimeSignature #'transparent = ##t
+	\key fis \minor
+
+    \clef bass
+	<fis, a>4
+	<b, d>4
+}
+`
+
+func TestParseLilypond(t *testing.T) {
+	score, err := ParseLilypond(chordSource)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, score.KeySignature)
+	assert.Equal(t, ClefTreble, score.UpperClef)
+	assert.Equal(t, ClefBass, score.LowerClef)
+	if assert.Len(t, score.Columns, 2) {
+		assert.Len(t, score.Columns[0].Upper, 3)
+		assert.Equal(t, "eis'", score.Columns[0].Upper[1].LilypondSymbol())
+		assert.Len(t, score.Columns[0].Lower, 2)
+		assert.Empty(t, score.Columns[1].Upper)
+		assert.Equal(t, "b,", score.Columns[1].Lower[0].LilypondSymbol())
+	}
+
+	_, err = ParseLilypond(strings.Replace(chordSource, `\clef bass`, `\clef alto`, 1))
+	assert.Error(t, err)
+}
+
+func TestParseKey(t *testing.T) {
+	for _, tc := range []struct {
+		tonic    string
+		mode     string
+		expected int
+	}{
+		{"c", `\major`, 0},
+		{"a", `\minor`, 0},
+		{"fis", `\major`, 6},
+		{"es", `\major`, -3},
+		{"bes", `\minor`, -5},
+		{"cis", `\minor`, 4},
+	} {
+		keySignature, err := parseKey(tc.tonic, tc.mode)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, keySignature, "%s %s", tc.tonic, tc.mode)
+	}
+}
+
+func noteNamed(t *testing.T, name string) notes.Note {
+	n, err := notes.ParseNote(name)
+	assert.NoError(t, err)
+	return n
+}
+
+func TestPlaceNotes(t *testing.T) {
+	treble := staff{clef: ClefTreble}
+	accidentals := map[int]notes.NoteModifier{}
+
+	// a cluster low on the staff: stem up, the upper notes of seconds move right
+	placement := placeNotes(treble, []notes.Note{noteNamed(t, "d'"), noteNamed(t, "c'"), noteNamed(t, "e'")}, nil, 0, accidentals)
+	assert.True(t, placement.stemUp)
+	assert.Equal(t, []bool{false, true, false}, []bool{placement.notes[0].displaced, placement.notes[1].displaced, placement.notes[2].displaced})
+
+	// the key signature of G major makes f sharp, a natural is needed and then persists in the measure
+	accidentals = map[int]notes.NoteModifier{}
+	placement = placeNotes(treble, []notes.Note{noteNamed(t, "f''"), noteNamed(t, "a''")}, nil, 1, accidentals)
+	assert.False(t, placement.stemUp)
+	assert.True(t, placement.notes[0].accidental)
+	assert.False(t, placement.notes[1].accidental)
+	placement = placeNotes(treble, []notes.Note{noteNamed(t, "f''")}, nil, 1, accidentals)
+	assert.False(t, placement.notes[0].accidental)
+
+	// accidentals closer than a seventh are put in separate columns
+	accidentals = map[int]notes.NoteModifier{}
+	placement = placeNotes(treble, []notes.Note{noteNamed(t, "cis''"), noteNamed(t, "eis''"), noteNamed(t, "fis'")}, nil, 0, accidentals)
+	assert.Equal(t, 2, placement.accidentalColumns)
+}
+
+func TestEngrave(t *testing.T) {
+	score, err := ParseLilypond(chordSource)
+	assert.NoError(t, err)
+	drawing := Engrave(score)
+	assert.True(t, drawing.Width > drawing.Height)
+
+	svg := string(drawing.SVG(DefaultPixelsPerSpace))
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg"`))
+	assert.True(t, strings.HasSuffix(svg, "</svg>\n"))
+
+	pngBytes, err := drawing.PNG(DefaultPixelsPerSpace)
+	assert.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(pngBytes))
+	assert.NoError(t, err)
+	assert.Equal(t, int(math.Ceil(drawing.Width*DefaultPixelsPerSpace)), img.Bounds().Dx())
+
+	// staff lines are black, the corner is white
+	top := int((0 - drawing.MinY) * DefaultPixelsPerSpace)
+	r, _, _, _ := img.At(int((0.5-drawing.MinX)*DefaultPixelsPerSpace), top).RGBA()
+	assert.True(t, r < 0x8000)
+	r, _, _, _ = img.At(0, 0).RGBA()
+	assert.Equal(t, uint32(0xFFFF), r)
+}
+
+func TestVectorFormats(t *testing.T) {
+	score, err := ParseLilypond(chordSource)
+	assert.NoError(t, err)
+	drawing := Engrave(score)
+	width, height := drawing.sizeInPoints()
+
+	pdf := string(drawing.PDF())
+	assert.True(t, strings.HasPrefix(pdf, "%PDF-1.4\n"))
+	assert.True(t, strings.Contains(pdf, fmt.Sprintf("/MediaBox [0 0 %d %d]", int(width), int(height))))
+	assert.True(t, strings.HasSuffix(pdf, "%%EOF\n"))
+
+	eps := string(drawing.EPS())
+	assert.True(t, strings.HasPrefix(eps, "%!PS-Adobe-3.0 EPSF-3.0\n"))
+	assert.True(t, strings.Contains(eps, fmt.Sprintf("%%%%BoundingBox: 0 0 %d %d\n", int(width), int(height))))
+
+	// a transparent background leaves the corner empty
+	drawing.Transparent = true
+	pngBytes, err := drawing.PNG(PixelsPerSpace(72))
+	assert.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(pngBytes))
+	assert.NoError(t, err)
+	assert.Equal(t, int(width), img.Bounds().Dx())
+	_, _, _, a := img.At(0, 0).RGBA()
+	assert.Equal(t, uint32(0), a)
+	assert.False(t, strings.Contains(string(drawing.SVG(DefaultPixelsPerSpace)), `fill="white"`))
+}
@@ -0,0 +1,89 @@
+package engraver
+
+// Glyphs are drawn in staff spaces around their reference point, y grows downwards.
+
+// trebleClef is placed on the G line: it spirals around the line, loops above the staff
+// and ends with a hook below it.
+func trebleClef(x float64, y float64) []Shape {
+	b := &pathBuilder{}
+	b.moveTo(0.15, 0.05).
+		curveTo(0.7, 0.05, 0.75, -0.85, 0.05, -0.9).
+		curveTo(-0.85, -0.9, -1.0, 0.65, 0.1, 0.75).
+		curveTo(1.35, 0.85, 1.45, -0.75, 0.65, -1.45).
+		curveTo(-0.1, -2.05, -0.65, -2.9, -0.3, -3.95).
+		curveTo(-0.05, -4.7, 0.65, -4.85, 0.6, -3.95).
+		curveTo(0.55, -3.05, -0.25, -2.75, -0.15, -1.6).
+		curveTo(-0.05, -0.4, 0.35, 1.2, 0.35, 2.1).
+		curveTo(0.35, 2.85, -0.35, 2.95, -0.55, 2.5)
+
+	return []Shape{
+		b.stroke(0.2).transform(x, y, 1),
+		circle(x-0.4, y+2.4, 0.3),
+	}
+}
+
+// bassClef is placed on the F line: a dot on the line, an arc over it ending below and two dots right of the line.
+func bassClef(x float64, y float64) []Shape {
+	b := &pathBuilder{}
+	b.moveTo(0.05, -0.05).
+		curveTo(-0.05, -0.95, 1.15, -1.3, 1.75, -0.65).
+		curveTo(2.35, 0.05, 1.7, 1.5, -0.05, 2.55)
+
+	return []Shape{
+		b.stroke(0.25).transform(x, y, 1),
+		circle(x+0.3, y, 0.38),
+		circle(x+2.45, y-0.5, 0.16),
+		circle(x+2.45, y+0.5, 0.16),
+	}
+}
+
+// sharp is centered on its note
+func sharp(x float64, y float64) []Shape {
+	return []Shape{
+		rect(x-0.33, y-1.2, 0.12, 2.55),
+		rect(x+0.21, y-1.35, 0.12, 2.55),
+		polygon(point{x - 0.55, y - 0.3}, point{x + 0.55, y - 0.6}, point{x + 0.55, y - 0.35}, point{x - 0.55, y - 0.05}),
+		polygon(point{x - 0.55, y + 0.55}, point{x + 0.55, y + 0.25}, point{x + 0.55, y + 0.5}, point{x - 0.55, y + 0.8}),
+	}
+}
+
+// flat has its bowl on the note
+func flat(x float64, y float64) []Shape {
+	b := &pathBuilder{}
+	b.moveTo(x-0.3, y+0.5).
+		curveTo(x+0.75, y-0.05, x+0.5, y-0.95, x-0.3, y-0.3)
+
+	return []Shape{
+		rect(x-0.38, y-2.0, 0.12, 2.55),
+		b.stroke(0.2),
+	}
+}
+
+func natural(x float64, y float64) []Shape {
+	return []Shape{
+		rect(x-0.3, y-1.35, 0.11, 1.9),
+		rect(x+0.2, y-0.55, 0.11, 1.9),
+		polygon(point{x - 0.3, y - 0.3}, point{x + 0.3, y - 0.5}, point{x + 0.3, y - 0.25}, point{x - 0.3, y - 0.05}),
+		polygon(point{x - 0.3, y + 0.45}, point{x + 0.3, y + 0.25}, point{x + 0.3, y + 0.5}, point{x - 0.3, y + 0.7}),
+	}
+}
+
+func noteHead(x float64, y float64) Shape {
+	return ellipse(x, y, 0.62, 0.42, -20)
+}
+
+// brace connects the staves of a grand staff from top to bottom, its tip points left at the middle.
+func brace(x float64, top float64, bottom float64) Shape {
+	h := bottom - top
+	mid := top + h/2
+	w := 0.9
+	b := &pathBuilder{}
+	b.moveTo(x+w, top).
+		curveTo(x-0.25*w, top+0.08*h, x+1.0*w, mid-0.12*h, x, mid).
+		curveTo(x+1.0*w, mid+0.12*h, x-0.25*w, bottom-0.08*h, x+w, bottom).
+		curveTo(x+0.25*w, bottom-0.1*h, x+1.7*w, mid+0.1*h, x, mid).
+		curveTo(x+1.7*w, mid-0.1*h, x+0.25*w, top+0.1*h, x+w, top).
+		close()
+
+	return b.fill()
+}
@@ -0,0 +1,245 @@
+package engraver
+
+import (
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var staffBlockRegexp = regexp.MustCompile(`(?s)\b(upper|lower) = \{(.*?)\n\}`)
+
+var tonicFifths = map[string]int{"f": -1, "c": 0, "g": 1, "d": 2, "a": 3, "e": 4, "b": 5}
+
+// ParseLilypond reads the subset of LilyPond the chord and interval templates generate: an upper and
+// a lower staff with a clef, a key and a sequence of quarter note chords, single notes and skips.
+func ParseLilypond(source string) (Score, error) {
+	score := Score{UpperClef: ClefTreble, LowerClef: ClefBass, BeatsPerMeasure: 4}
+
+	blocks := map[string]string{}
+	for _, match := range staffBlockRegexp.FindAllStringSubmatch(source, -1) {
+		blocks[match[1]] = match[2]
+	}
+	if _, ok := blocks["upper"]; !ok {
+		return Score{}, fmt.Errorf("missing upper staff in lilypond source")
+	}
+
+	var staves [2]staffContent
+	for i, name := range []string{"upper", "lower"} {
+		content, err := parseStaff(blocks[name])
+		if err != nil {
+			return Score{}, fmt.Errorf("failed to parse %s staff: %v", name, err)
+		}
+		staves[i] = content
+	}
+
+	if staves[0].clef != nil {
+		score.UpperClef = *staves[0].clef
+	}
+	if staves[1].clef != nil {
+		score.LowerClef = *staves[1].clef
+	}
+	score.KeySignature = staves[0].keySignature
+
+	for i := 0; i < len(staves[0].elements) || i < len(staves[1].elements); i++ {
+		column := Column{}
+		if i < len(staves[0].elements) {
+			column.Upper = staves[0].elements[i]
+			column.Colors = staves[0].colors[i]
+		}
+		if i < len(staves[1].elements) {
+			column.Lower = staves[1].elements[i]
+			for symbol, color := range staves[1].colors[i] {
+				if column.Colors == nil {
+					column.Colors = map[string]string{}
+				}
+				column.Colors[symbol] = color
+			}
+		}
+		score.Columns = append(score.Columns, column)
+	}
+
+	return score, nil
+}
+
+type staffContent struct {
+	clef         *Clef
+	keySignature int
+	elements     [][]notes.Note
+	colors       []map[string]string
+}
+
+func parseStaff(block string) (staffContent, error) {
+	content := staffContent{}
+	tokens := strings.Fields(block)
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch {
+		case token == `\clef`:
+			if i+1 >= len(tokens) {
+				return content, fmt.Errorf("missing clef name")
+			}
+			i++
+			clef := ClefTreble
+			switch tokens[i] {
+			case "treble":
+			case "bass":
+				clef = ClefBass
+			default:
+				return content, fmt.Errorf("unsupported clef: %s", tokens[i])
+			}
+			content.clef = &clef
+		case token == `\key`:
+			if i+2 >= len(tokens) {
+				return content, fmt.Errorf("missing key tonic or mode")
+			}
+			keySignature, err := parseKey(tokens[i+1], tokens[i+2])
+			if err != nil {
+				return content, err
+			}
+			content.keySignature = keySignature
+			i += 2
+		case token == `\once`:
+		case token == `\override`:
+			// \override Staff.TimeSignature #'transparent = ##t
+			i += 4
+		case strings.HasPrefix(token, "<"):
+			chord := strings.TrimPrefix(token, "<")
+			for !strings.Contains(token, ">") {
+				i++
+				if i >= len(tokens) {
+					return content, fmt.Errorf("unterminated chord")
+				}
+				token = tokens[i]
+				chord += " " + token
+			}
+			chord = chord[:strings.Index(chord, ">")]
+
+			chordNotes, colors, err := parseChord(strings.Fields(chord))
+			if err != nil {
+				return content, err
+			}
+			content.elements = append(content.elements, chordNotes)
+			content.colors = append(content.colors, colors)
+		case strings.HasPrefix(token, "s") || strings.HasPrefix(token, "r"):
+			content.elements = append(content.elements, nil)
+			content.colors = append(content.colors, nil)
+		case strings.HasPrefix(token, `\`):
+			return content, fmt.Errorf("unsupported command: %s", token)
+		default:
+			n, err := notes.ParseNote(strings.TrimRight(token, "0123456789."))
+			if err != nil {
+				return content, err
+			}
+			content.elements = append(content.elements, []notes.Note{n})
+			content.colors = append(content.colors, nil)
+		}
+	}
+
+	return content, nil
+}
+
+// parseChord reads the notes of a chord with their tweaks, e.g. \tweak #'color #(rgb-color 0.839 0.153 0.157) c'.
+// Only the color is drawn, other tweaks and \parenthesize are skipped.
+func parseChord(tokens []string) ([]notes.Note, map[string]string, error) {
+	var chordNotes []notes.Note
+	var colors map[string]string
+	color := ""
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case `\parenthesize`:
+			continue
+		case `\tweak`:
+			if i+2 >= len(tokens) {
+				return nil, nil, fmt.Errorf("missing tweak property or value")
+			}
+			property := tokens[i+1]
+			value := tokens[i+2]
+			i += 2
+			for strings.HasPrefix(value, "#(") && !strings.HasSuffix(value, ")") {
+				i++
+				if i >= len(tokens) {
+					return nil, nil, fmt.Errorf("unterminated tweak value: %s", value)
+				}
+				value += " " + tokens[i]
+			}
+			if property == "#'color" {
+				parsed, err := parseRGBColor(value)
+				if err != nil {
+					return nil, nil, err
+				}
+				color = parsed
+			}
+			continue
+		}
+
+		n, err := notes.ParseNote(tokens[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		chordNotes = append(chordNotes, n)
+		if color != "" {
+			if colors == nil {
+				colors = map[string]string{}
+			}
+			colors[n.LilypondSymbol()] = color
+			color = ""
+		}
+	}
+
+	return chordNotes, colors, nil
+}
+
+// parseRGBColor returns the hex color of e.g. #(rgb-color 0.839 0.153 0.157)
+func parseRGBColor(value string) (string, error) {
+	fields := strings.Fields(strings.TrimSuffix(strings.TrimPrefix(value, "#(rgb-color"), ")"))
+	if !strings.HasPrefix(value, "#(rgb-color") || len(fields) != 3 {
+		return "", fmt.Errorf("unsupported color: %s", value)
+	}
+
+	hex := "#"
+	for _, field := range fields {
+		component, err := strconv.ParseFloat(field, 64)
+		if err != nil || component < 0 || component > 1 {
+			return "", fmt.Errorf("invalid color component: %s", field)
+		}
+		hex += fmt.Sprintf("%02x", int(math.Round(component*255)))
+	}
+
+	return hex, nil
+}
+
+// parseKey returns the key signature of e.g. "fis" "\minor"
+func parseKey(tonic string, mode string) (int, error) {
+	fifths, ok := tonicFifths[tonic[:1]]
+	if !ok {
+		return 0, fmt.Errorf("invalid key tonic: %s", tonic)
+	}
+
+	switch tonic[1:] {
+	case "":
+	case "is":
+		fifths += 7
+	case "es", "s":
+		fifths -= 7
+	default:
+		return 0, fmt.Errorf("invalid key tonic: %s", tonic)
+	}
+
+	switch mode {
+	case `\major`:
+	case `\minor`:
+		fifths -= 3
+	default:
+		return 0, fmt.Errorf("unsupported key mode: %s", mode)
+	}
+
+	if fifths < -7 || fifths > 7 {
+		return 0, fmt.Errorf("unsupported key: %s %s", tonic, mode)
+	}
+
+	return fifths, nil
+}
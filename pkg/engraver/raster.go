@@ -0,0 +1,257 @@
+package engraver
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sort"
+	"strconv"
+)
+
+const (
+	// subsamples is the number of scanlines per pixel used for anti-aliasing
+	subsamples = 4
+	// curveSteps is the number of line segments a cubic curve is flattened to
+	curveSteps = 16
+	// joinSteps is the number of line segments of round joins of strokes
+	joinSteps = 12
+)
+
+// Image rasterizes the drawing on a white background, or a transparent one when the drawing is Transparent.
+func (d Drawing) Image(pixelsPerSpace float64) *image.RGBA {
+	width := int(math.Ceil(d.Width * pixelsPerSpace))
+	height := int(math.Ceil(d.Height * pixelsPerSpace))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if !d.Transparent {
+		for i := range img.Pix {
+			img.Pix[i] = 0xFF
+		}
+	}
+
+	for _, s := range d.Shapes {
+		placed := s.transform(-d.MinX*pixelsPerSpace, -d.MinY*pixelsPerSpace, pixelsPerSpace)
+		fillPolygons(img, placed.polygons(), parseColor(placed.Color))
+	}
+
+	return img
+}
+
+// PNG rasterizes the drawing and encodes it as PNG.
+func (d Drawing) PNG(pixelsPerSpace float64) ([]byte, error) {
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, d.Image(pixelsPerSpace)); err != nil {
+		return nil, fmt.Errorf("failed to encode png: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func parseColor(hex string) color.RGBA {
+	c := color.RGBA{A: 0xFF}
+	if len(hex) != 7 || hex[0] != '#' {
+		return c
+	}
+
+	value, err := strconv.ParseUint(hex[1:], 16, 32)
+	if err != nil {
+		return c
+	}
+	c.R, c.G, c.B = uint8(value>>16), uint8(value>>8), uint8(value)
+
+	return c
+}
+
+// flatten converts the path to polylines, curves are replaced with line segments
+func (s Shape) flatten() [][]point {
+	var polylines [][]point
+	var current []point
+	for _, seg := range s.segments {
+		switch seg.op {
+		case 'M':
+			if len(current) > 0 {
+				polylines = append(polylines, current)
+			}
+			current = []point{seg.points[0]}
+		case 'L':
+			current = append(current, seg.points[0])
+		case 'C':
+			p0 := current[len(current)-1]
+			p1, p2, p3 := seg.points[0], seg.points[1], seg.points[2]
+			for i := 1; i <= curveSteps; i++ {
+				t := float64(i) / curveSteps
+				mt := 1 - t
+				current = append(current, point{
+					x: mt*mt*mt*p0.x + 3*mt*mt*t*p1.x + 3*mt*t*t*p2.x + t*t*t*p3.x,
+					y: mt*mt*mt*p0.y + 3*mt*mt*t*p1.y + 3*mt*t*t*p2.y + t*t*t*p3.y,
+				})
+			}
+		case 'Z':
+			if len(current) > 0 {
+				current = append(current, current[0])
+			}
+		}
+	}
+	if len(current) > 0 {
+		polylines = append(polylines, current)
+	}
+
+	return polylines
+}
+
+// polygons returns closed polygons covering the shape. Strokes are built from a quad for every
+// line segment and a disc for every joint, all oriented the same way so the nonzero rule merges them.
+func (s Shape) polygons() [][]point {
+	polylines := s.flatten()
+	if s.StrokeWidth <= 0 {
+		return polylines
+	}
+
+	r := s.StrokeWidth / 2
+	var polygons [][]point
+	for _, line := range polylines {
+		for i, p := range line {
+			polygons = append(polygons, oriented(discPolygon(p, r)))
+			if i == 0 {
+				continue
+			}
+			prev := line[i-1]
+			dx, dy := p.x-prev.x, p.y-prev.y
+			length := math.Hypot(dx, dy)
+			if length == 0 {
+				continue
+			}
+			nx, ny := -dy/length*r, dx/length*r
+			polygons = append(polygons, oriented([]point{
+				{prev.x + nx, prev.y + ny},
+				{p.x + nx, p.y + ny},
+				{p.x - nx, p.y - ny},
+				{prev.x - nx, prev.y - ny},
+			}))
+		}
+	}
+
+	return polygons
+}
+
+func discPolygon(center point, r float64) []point {
+	var points []point
+	for i := 0; i < joinSteps; i++ {
+		angle := 2 * math.Pi * float64(i) / joinSteps
+		points = append(points, point{center.x + r*math.Cos(angle), center.y + r*math.Sin(angle)})
+	}
+
+	return points
+}
+
+func oriented(points []point) []point {
+	area := 0.0
+	for i := range points {
+		j := (i + 1) % len(points)
+		area += points[i].x*points[j].y - points[j].x*points[i].y
+	}
+	if area >= 0 {
+		return points
+	}
+
+	reversed := make([]point, len(points))
+	for i := range points {
+		reversed[len(points)-1-i] = points[i]
+	}
+
+	return reversed
+}
+
+type crossing struct {
+	x         float64
+	direction int
+}
+
+// fillPolygons fills the polygons with the nonzero winding rule. Every pixel row is sampled with
+// several scanlines and horizontal coverage is exact, which gives smooth edges.
+func fillPolygons(img *image.RGBA, polygons [][]point, c color.RGBA) {
+	bounds := img.Bounds()
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, polygon := range polygons {
+		for _, p := range polygon {
+			minY, maxY = math.Min(minY, p.y), math.Max(maxY, p.y)
+		}
+	}
+	if math.IsInf(minY, 1) {
+		return
+	}
+
+	startY := int(math.Max(math.Floor(minY), float64(bounds.Min.Y)))
+	endY := int(math.Min(math.Ceil(maxY), float64(bounds.Max.Y)))
+	coverage := make([]float64, bounds.Dx())
+
+	for y := startY; y < endY; y++ {
+		for i := range coverage {
+			coverage[i] = 0
+		}
+		touched := false
+
+		for sub := 0; sub < subsamples; sub++ {
+			sy := float64(y) + (float64(sub)+0.5)/subsamples
+			var crossings []crossing
+			for _, polygon := range polygons {
+				for i := range polygon {
+					p1, p2 := polygon[i], polygon[(i+1)%len(polygon)]
+					if (p1.y <= sy && p2.y > sy) || (p2.y <= sy && p1.y > sy) {
+						direction := 1
+						if p2.y < p1.y {
+							direction = -1
+						}
+						crossings = append(crossings, crossing{x: p1.x + (sy-p1.y)*(p2.x-p1.x)/(p2.y-p1.y), direction: direction})
+					}
+				}
+			}
+			sort.Slice(crossings, func(i int, j int) bool {
+				return crossings[i].x < crossings[j].x
+			})
+
+			winding := 0
+			for i, cr := range crossings {
+				winding += cr.direction
+				if winding != 0 && i+1 < len(crossings) {
+					addSpan(coverage, cr.x, crossings[i+1].x)
+					touched = true
+				}
+			}
+		}
+
+		if !touched {
+			continue
+		}
+		for x, cov := range coverage {
+			if cov <= 0 {
+				continue
+			}
+			alpha := math.Min(cov, 1) * float64(c.A) / 0xFF
+			offset := img.PixOffset(x+bounds.Min.X, y)
+			img.Pix[offset] = blend(img.Pix[offset], c.R, alpha)
+			img.Pix[offset+1] = blend(img.Pix[offset+1], c.G, alpha)
+			img.Pix[offset+2] = blend(img.Pix[offset+2], c.B, alpha)
+			img.Pix[offset+3] = blend(img.Pix[offset+3], 0xFF, alpha)
+		}
+	}
+}
+
+// addSpan adds the horizontal coverage of one scanline span to the pixels it crosses
+func addSpan(coverage []float64, from float64, to float64) {
+	from = math.Max(from, 0)
+	to = math.Min(to, float64(len(coverage)))
+	for x := int(from); x < len(coverage) && float64(x) < to; x++ {
+		overlap := math.Min(to, float64(x+1)) - math.Max(from, float64(x))
+		if overlap > 0 {
+			coverage[x] += overlap / subsamples
+		}
+	}
+}
+
+// blend composites src over dst, the pixels are premultiplied so the alpha channel blends the same way
+func blend(dst uint8, src uint8, alpha float64) uint8 {
+	return uint8(math.Round(float64(dst)*(1-alpha) + float64(src)*alpha))
+}
@@ -0,0 +1,150 @@
+package engraver
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// PointsPerSpace matches LilyPond's default 20pt staff
+const PointsPerSpace = 5.0
+
+// PixelsPerSpace converts a resolution in dots per inch to the scale used by Image and PNG.
+func PixelsPerSpace(dpi int) float64 {
+	return float64(dpi) * PointsPerSpace / 72
+}
+
+// postScriptOperators are the path operators of PostScript and PDF, both have the origin in the lower left corner
+type postScriptOperators struct {
+	moveTo      string
+	lineTo      string
+	curveTo     string
+	closePath   string
+	fill        string
+	stroke      string
+	lineWidth   string
+	fillColor   string
+	strokeColor string
+	newPath     string
+}
+
+var epsOperators = postScriptOperators{
+	moveTo:      "moveto",
+	lineTo:      "lineto",
+	curveTo:     "curveto",
+	closePath:   "closepath",
+	fill:        "fill",
+	stroke:      "stroke",
+	lineWidth:   "setlinewidth",
+	fillColor:   "setrgbcolor",
+	strokeColor: "setrgbcolor",
+	newPath:     "newpath",
+}
+
+var pdfOperators = postScriptOperators{
+	moveTo:      "m",
+	lineTo:      "l",
+	curveTo:     "c",
+	closePath:   "h",
+	fill:        "f",
+	stroke:      "S",
+	lineWidth:   "w",
+	fillColor:   "rg",
+	strokeColor: "RG",
+}
+
+func (d Drawing) sizeInPoints() (float64, float64) {
+	return math.Ceil(d.Width * PointsPerSpace), math.Ceil(d.Height * PointsPerSpace)
+}
+
+// writePaths writes the shapes in points with the y axis flipped
+func (d Drawing) writePaths(buf *bytes.Buffer, ops postScriptOperators) {
+	_, height := d.sizeInPoints()
+	coordinates := func(p point) string {
+		return fmt.Sprintf("%s %s", formatFloat((p.x-d.MinX)*PointsPerSpace), formatFloat(height-(p.y-d.MinY)*PointsPerSpace))
+	}
+
+	for _, s := range d.Shapes {
+		c := parseColor(s.Color)
+		rgb := fmt.Sprintf("%s %s %s", formatFloat(float64(c.R)/0xFF), formatFloat(float64(c.G)/0xFF), formatFloat(float64(c.B)/0xFF))
+		if s.StrokeWidth > 0 {
+			fmt.Fprintf(buf, "%s %s\n%s %s\n", rgb, ops.strokeColor, formatFloat(s.StrokeWidth*PointsPerSpace), ops.lineWidth)
+		} else {
+			fmt.Fprintf(buf, "%s %s\n", rgb, ops.fillColor)
+		}
+
+		if ops.newPath != "" {
+			fmt.Fprintf(buf, "%s\n", ops.newPath)
+		}
+		for _, seg := range s.segments {
+			switch seg.op {
+			case 'M':
+				fmt.Fprintf(buf, "%s %s\n", coordinates(seg.points[0]), ops.moveTo)
+			case 'L':
+				fmt.Fprintf(buf, "%s %s\n", coordinates(seg.points[0]), ops.lineTo)
+			case 'C':
+				fmt.Fprintf(buf, "%s %s %s %s\n", coordinates(seg.points[0]), coordinates(seg.points[1]), coordinates(seg.points[2]), ops.curveTo)
+			case 'Z':
+				fmt.Fprintf(buf, "%s\n", ops.closePath)
+			}
+		}
+
+		if s.StrokeWidth > 0 {
+			fmt.Fprintf(buf, "%s\n", ops.stroke)
+		} else {
+			fmt.Fprintf(buf, "%s\n", ops.fill)
+		}
+	}
+}
+
+// EPS returns the drawing as an encapsulated PostScript file with the bounding box cropped to the drawing.
+func (d Drawing) EPS() []byte {
+	width, height := d.sizeInPoints()
+	buf := bytes.Buffer{}
+	buf.WriteString("%!PS-Adobe-3.0 EPSF-3.0\n")
+	fmt.Fprintf(&buf, "%%%%BoundingBox: 0 0 %d %d\n", int(width), int(height))
+	buf.WriteString("%%EndComments\n")
+	buf.WriteString("1 setlinecap 1 setlinejoin\n")
+	if !d.Transparent {
+		fmt.Fprintf(&buf, "1 1 1 setrgbcolor\nnewpath 0 0 moveto %d 0 lineto %d %d lineto 0 %d lineto closepath fill\n", int(width), int(width), int(height), int(height))
+	}
+	d.writePaths(&buf, epsOperators)
+	buf.WriteString("showpage\n%%EOF\n")
+
+	return buf.Bytes()
+}
+
+// PDF returns the drawing as a single page PDF document with the page cropped to the drawing.
+func (d Drawing) PDF() []byte {
+	width, height := d.sizeInPoints()
+	content := bytes.Buffer{}
+	content.WriteString("1 J 1 j\n")
+	if !d.Transparent {
+		fmt.Fprintf(&content, "1 1 1 rg\n0 0 %d %d re f\n", int(width), int(height))
+	}
+	d.writePaths(&content, pdfOperators)
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Contents 4 0 R /Resources << >> >>", int(width), int(height)),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteString("%PDF-1.4\n")
+	var offsets []int
+	for i, object := range objects {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,143 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+const (
+	// inkLuminance is the luminance, on a white background, below which a pixel is notation and not whitespace
+	inkLuminance = 192
+	// staffLineCoverage is the part of the notation width a row must cover to be a staff line
+	staffLineCoverage = 0.6
+	// minStaffLines is the number of lines of a single staff
+	minStaffLines = 5
+)
+
+// Analysis describes the notation on a rendered image. Bounds is the bounding box of the notation,
+// empty when the image is blank, and StaffLines is the number of horizontal lines spanning most of it.
+type Analysis struct {
+	Width       int
+	Height      int
+	Bounds      image.Rectangle
+	StaffLines  int
+	Transparent bool
+}
+
+func (a Analysis) Blank() bool {
+	return a.Bounds.Empty()
+}
+
+func isInk(c color.Color) bool {
+	r, g, b, a := c.RGBA()
+	// composite the premultiplied color on white
+	r += 0xFFFF - a
+	g += 0xFFFF - a
+	b += 0xFFFF - a
+	luminance := (299*r + 587*g + 114*b) / 1000 >> 8
+
+	return luminance < inkLuminance
+}
+
+// Analyze finds the notation on the image and counts its staff lines.
+func Analyze(img image.Image) Analysis {
+	bounds := img.Bounds()
+	analysis := Analysis{Width: bounds.Dx(), Height: bounds.Dy()}
+	if bounds.Empty() {
+		return analysis
+	}
+
+	_, _, _, a := img.At(bounds.Min.X, bounds.Min.Y).RGBA()
+	analysis.Transparent = a < 0x8000
+
+	rowInk := make([]int, bounds.Dy())
+	ink := image.Rectangle{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if !isInk(img.At(x, y)) {
+				continue
+			}
+			rowInk[y-bounds.Min.Y]++
+			ink = ink.Union(image.Rect(x, y, x+1, y+1))
+		}
+	}
+	analysis.Bounds = ink
+
+	// consecutive rows covering the notation are a single, thick line
+	inLine := false
+	for y := ink.Min.Y; y < ink.Max.Y; y++ {
+		line := float64(rowInk[y-bounds.Min.Y]) >= staffLineCoverage*float64(ink.Dx())
+		if line && !inLine {
+			analysis.StaffLines++
+		}
+		inLine = line
+	}
+
+	return analysis
+}
+
+func background(transparent bool) image.Image {
+	if transparent {
+		return image.Transparent
+	}
+	return image.White
+}
+
+// Trim crops the image to the notation bounds with margin pixels of background around it.
+func Trim(img image.Image, analysis Analysis, margin int) *image.RGBA {
+	width := analysis.Bounds.Dx() + 2*margin
+	height := analysis.Bounds.Dy() + 2*margin
+	trimmed := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(trimmed, trimmed.Bounds(), background(analysis.Transparent), image.Point{}, draw.Src)
+	draw.Draw(trimmed, image.Rect(margin, margin, width-margin, height-margin), img, analysis.Bounds.Min, draw.Src)
+
+	return trimmed
+}
+
+// PadHeight centers the image vertically on a background of the given height, taller images are returned as they are.
+func PadHeight(img *image.RGBA, height int, transparent bool) *image.RGBA {
+	bounds := img.Bounds()
+	if bounds.Dy() >= height {
+		return img
+	}
+
+	padded := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), height))
+	draw.Draw(padded, padded.Bounds(), background(transparent), image.Point{}, draw.Src)
+	top := (height - bounds.Dy()) / 2
+	draw.Draw(padded, image.Rect(0, top, bounds.Dx(), top+bounds.Dy()), img, bounds.Min, draw.Src)
+
+	return padded
+}
+
+// Downscale halves the image, averaging every 2x2 block of pixels.
+func Downscale(img *image.RGBA) *image.RGBA {
+	bounds := img.Bounds()
+	scaled := image.NewRGBA(image.Rect(0, 0, (bounds.Dx()+1)/2, (bounds.Dy()+1)/2))
+	for y := 0; y < scaled.Rect.Dy(); y++ {
+		for x := 0; x < scaled.Rect.Dx(); x++ {
+			var sum [4]int
+			count := 0
+			for dy := 0; dy < 2; dy++ {
+				for dx := 0; dx < 2; dx++ {
+					p := image.Pt(bounds.Min.X+2*x+dx, bounds.Min.Y+2*y+dy)
+					if !p.In(bounds) {
+						continue
+					}
+					offset := img.PixOffset(p.X, p.Y)
+					for i := 0; i < 4; i++ {
+						sum[i] += int(img.Pix[offset+i])
+					}
+					count++
+				}
+			}
+
+			offset := scaled.PixOffset(x, y)
+			for i := 0; i < 4; i++ {
+				scaled.Pix[offset+i] = uint8(sum[i] / count)
+			}
+		}
+	}
+
+	return scaled
+}
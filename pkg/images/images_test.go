@@ -0,0 +1,124 @@
+package images
+
+import (
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/engraver"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func engrave(t *testing.T, chords ...string) *image.RGBA {
+	source := fmt.Sprintf("upper = {\n  \\clef treble\n  %s\n}\n\nlower = {\n  \\clef bass\n  %s\n}\n",
+		strings.Join(chords, " "), strings.Repeat("s4 ", len(chords)))
+	score, err := engraver.ParseLilypond(source)
+	assert.NoError(t, err)
+
+	return engraver.Engrave(score).Image(engraver.DefaultPixelsPerSpace)
+}
+
+func blank(width int, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+	return img
+}
+
+func TestAnalyze(t *testing.T) {
+	analysis := Analyze(engrave(t, "<c' e' g'>4"))
+	assert.False(t, analysis.Blank())
+	assert.False(t, analysis.Transparent)
+	assert.Equal(t, 10, analysis.StaffLines)
+
+	analysis = Analyze(blank(20, 10))
+	assert.True(t, analysis.Blank())
+
+	transparent := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	transparent.Set(5, 5, color.Black)
+	analysis = Analyze(transparent)
+	assert.True(t, analysis.Transparent)
+	assert.Equal(t, image.Rect(5, 5, 6, 6), analysis.Bounds)
+}
+
+func TestTrimAndPad(t *testing.T) {
+	img := blank(40, 30)
+	for x := 10; x < 20; x++ {
+		img.Set(x, 12, color.Black)
+	}
+
+	analysis := Analyze(img)
+	assert.Equal(t, 1, analysis.StaffLines)
+
+	trimmed := Trim(img, analysis, 3)
+	assert.Equal(t, image.Rect(0, 0, 16, 7), trimmed.Bounds())
+	assert.Equal(t, color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}, trimmed.At(0, 0))
+	assert.Equal(t, color.RGBA{A: 0xFF}, trimmed.At(3, 3))
+
+	padded := PadHeight(trimmed, 11, false)
+	assert.Equal(t, image.Rect(0, 0, 16, 11), padded.Bounds())
+	assert.Equal(t, color.RGBA{A: 0xFF}, padded.At(3, 5))
+	assert.Equal(t, trimmed, PadHeight(trimmed, 5, false))
+
+	scaled := Downscale(padded)
+	assert.Equal(t, image.Rect(0, 0, 8, 6), scaled.Bounds())
+	// half of the 2x2 block is the black line
+	assert.Equal(t, color.RGBA{R: 0x7F, G: 0x7F, B: 0x7F, A: 0xFF}, scaled.At(2, 2))
+}
+
+func writeTestImage(t *testing.T, path string, img image.Image) {
+	assert.NoError(t, writeImage(path, img))
+}
+
+func TestProcess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "images-test-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	paths := []string{filepath.Join(dir, "a.png"), filepath.Join(dir, "b.png"), filepath.Join(dir, "c.png"), filepath.Join(dir, "d.png"), filepath.Join(dir, "e.png")}
+	writeTestImage(t, paths[0], engrave(t, "<c' e' g'>4"))
+	writeTestImage(t, paths[1], engrave(t, "<c'' e'' g'' c'''>4"))
+	writeTestImage(t, paths[2], blank(50, 50))
+	writeTestImage(t, paths[3], engrave(t, strings.Repeat("<c' e' g'>4 ", 12)))
+	assert.NoError(t, ioutil.WriteFile(paths[4], []byte("not a png"), 0660))
+
+	opts := DefaultOptions
+	opts.Retina = true
+	report, err := Process(paths, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, report.Images)
+	issues := map[string]Issue{}
+	for _, finding := range report.Findings {
+		issues[filepath.Base(finding.Path)] = finding.Issue
+	}
+	assert.Equal(t, map[string]Issue{"c.png": IssueBlank, "d.png": IssueWide, "e.png": IssueInvalid}, issues)
+
+	var heights []int
+	for _, path := range paths[:2] {
+		img, err := readImage(path)
+		assert.NoError(t, err)
+		retina, err := readImage(RetinaPath(path))
+		assert.NoError(t, err)
+		assert.Equal(t, (retina.Bounds().Dx()+1)/2, img.Bounds().Dx())
+		assert.Equal(t, retina.Bounds().Dy()/2, img.Bounds().Dy())
+
+		analysis := Analyze(retina)
+		assert.Equal(t, opts.Margin, analysis.Bounds.Min.X)
+		heights = append(heights, img.Bounds().Dy())
+	}
+	assert.Equal(t, heights[0], heights[1])
+
+	// processing again starts from the @2x variants and gives the same images
+	before, err := ioutil.ReadFile(paths[0])
+	assert.NoError(t, err)
+	_, err = Process(paths, opts)
+	assert.NoError(t, err)
+	after, err := ioutil.ReadFile(paths[0])
+	assert.NoError(t, err)
+	assert.Equal(t, before, after)
+}
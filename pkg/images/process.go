@@ -0,0 +1,192 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Options of a deck of images. Margin is the whitespace in pixels kept around the notation, Height pads all
+// images to the same height, 0 uses the height of the tallest one. Retina keeps the full resolution in name@2x.png
+// and halves name.png, so the images should be rendered with twice the usual dpi. Images wider than
+// WideFactor times the median width of the deck are reported.
+type Options struct {
+	Margin     int
+	Height     int
+	Retina     bool
+	WideFactor float64
+}
+
+var DefaultOptions = Options{
+	Margin:     10,
+	WideFactor: 2.5,
+}
+
+type Issue string
+
+const (
+	IssueInvalid      Issue = "invalid"
+	IssueBlank        Issue = "blank"
+	IssueWide         Issue = "abnormally wide"
+	IssueNoStaffLines Issue = "missing staff lines"
+	IssueTooTall      Issue = "taller than the normalized height"
+)
+
+// Finding is a suspicious image of a deck.
+type Finding struct {
+	Path   string
+	Issue  Issue
+	Detail string
+}
+
+// Report lists the suspicious images out of all Images of a deck.
+type Report struct {
+	Images   int
+	Findings []Finding
+}
+
+// Write writes the findings as tab separated lines of path, issue and detail.
+func (r Report) Write(w io.Writer) error {
+	for _, finding := range r.Findings {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", finding.Path, finding.Issue, finding.Detail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RetinaPath returns the path of the @2x variant of the image.
+func RetinaPath(path string) string {
+	return strings.TrimSuffix(path, ".png") + "@2x.png"
+}
+
+// sourcePath returns the full resolution image, the @2x variant of an already processed image
+func sourcePath(path string, opts Options) string {
+	if opts.Retina {
+		if _, err := os.Stat(RetinaPath(path)); err == nil {
+			return RetinaPath(path)
+		}
+	}
+	return path
+}
+
+func readImage(path string) (image.Image, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image %s: %v", path, err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %s: %v", path, err)
+	}
+
+	return img, nil
+}
+
+func writeImage(path string, img image.Image) error {
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, img); err != nil {
+		return fmt.Errorf("failed to encode image %s: %v", path, err)
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0660)
+}
+
+// Validate analyzes the images without changing them and reports the suspicious ones.
+func Validate(paths []string, opts Options) Report {
+	report, _ := validate(paths, opts)
+	return report
+}
+
+func validate(paths []string, opts Options) (Report, map[string]Analysis) {
+	report := Report{Images: len(paths)}
+	analyses := map[string]Analysis{}
+	var widths []int
+	for _, path := range paths {
+		img, err := readImage(sourcePath(path, opts))
+		if err != nil {
+			report.Findings = append(report.Findings, Finding{Path: path, Issue: IssueInvalid, Detail: err.Error()})
+			continue
+		}
+
+		analysis := Analyze(img)
+		analyses[path] = analysis
+		if analysis.Blank() {
+			report.Findings = append(report.Findings, Finding{Path: path, Issue: IssueBlank, Detail: fmt.Sprintf("%dx%d", analysis.Width, analysis.Height)})
+			continue
+		}
+		if analysis.StaffLines < minStaffLines {
+			report.Findings = append(report.Findings, Finding{Path: path, Issue: IssueNoStaffLines, Detail: fmt.Sprintf("found %d of at least %d", analysis.StaffLines, minStaffLines)})
+		}
+		widths = append(widths, analysis.Bounds.Dx())
+	}
+
+	if len(widths) > 0 && opts.WideFactor > 0 {
+		sort.Ints(widths)
+		median := widths[len(widths)/2]
+		for _, path := range paths {
+			analysis, ok := analyses[path]
+			if ok && !analysis.Blank() && float64(analysis.Bounds.Dx()) > opts.WideFactor*float64(median) {
+				report.Findings = append(report.Findings, Finding{Path: path, Issue: IssueWide, Detail: fmt.Sprintf("%d pixels, median %d", analysis.Bounds.Dx(), median)})
+			}
+		}
+	}
+
+	return report, analyses
+}
+
+// Process trims the images to the margin and pads them to a common height in place, writing the @2x variants
+// when enabled. Blank and invalid images are left as they are and reported.
+func Process(paths []string, opts Options) (Report, error) {
+	report, analyses := validate(paths, opts)
+
+	height := opts.Height
+	if height <= 0 {
+		for _, analysis := range analyses {
+			if !analysis.Blank() && analysis.Bounds.Dy()+2*opts.Margin > height {
+				height = analysis.Bounds.Dy() + 2*opts.Margin
+			}
+		}
+	}
+	if opts.Retina && height%2 != 0 {
+		height++
+	}
+
+	for _, path := range paths {
+		analysis, ok := analyses[path]
+		if !ok || analysis.Blank() {
+			continue
+		}
+
+		img, err := readImage(sourcePath(path, opts))
+		if err != nil {
+			return report, err
+		}
+
+		processed := Trim(img, analysis, opts.Margin)
+		if processed.Bounds().Dy() > height {
+			report.Findings = append(report.Findings, Finding{Path: path, Issue: IssueTooTall, Detail: fmt.Sprintf("%d pixels, normalized %d", processed.Bounds().Dy(), height)})
+		}
+		processed = PadHeight(processed, height, analysis.Transparent)
+
+		if opts.Retina {
+			if err := writeImage(RetinaPath(path), processed); err != nil {
+				return report, err
+			}
+			processed = Downscale(processed)
+		}
+
+		if err := writeImage(path, processed); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,79 @@
+package lilypond
+
+import (
+	"context"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/cache"
+	"log"
+)
+
+// TemplateVersion is part of the cache key, bump it when changing the templates in a way that
+// doesn't change the generated sources but should still invalidate cached images.
+const TemplateVersion = "1"
+
+// CachedRenderer renders only the sources missing in the cache. Every artifact is cached separately, keyed by
+// the source, TemplateVersion, the version of the wrapped renderer and the options affecting it.
+type CachedRenderer struct {
+	Renderer Renderer
+	Cache    *cache.Cache
+}
+
+func (r *CachedRenderer) Version() string {
+	return r.Renderer.Version()
+}
+
+func (r *CachedRenderer) key(source string, opts RenderOptions, format Format) string {
+	return cache.Key(TemplateVersion, r.Renderer.Version(), source, string(format), fmt.Sprintf("dpi=%d transparent=%t", opts.dpi(), opts.Transparent))
+}
+
+func (r *CachedRenderer) Render(ctx context.Context, source string, opts RenderOptions) (RenderResult, error) {
+	result := r.RenderMany(ctx, []string{source}, opts)[0]
+	return result.RenderResult, result.Err
+}
+
+// cached returns the result when all requested artifacts are in the cache
+func (r *CachedRenderer) cached(source string, opts RenderOptions) (RenderResult, bool) {
+	result := RenderResult{Artifacts: map[Format][]byte{}}
+	for _, format := range opts.formats() {
+		data, ok := r.Cache.Get(r.key(source, opts, format))
+		if !ok {
+			return RenderResult{}, false
+		}
+		result.Artifacts[format] = data
+	}
+
+	return result, true
+}
+
+func (r *CachedRenderer) RenderMany(ctx context.Context, sources []string, opts RenderOptions) []BatchResult {
+	results := make([]BatchResult, len(sources))
+	var missing []string
+	var missingIndices []int
+	for i, source := range sources {
+		if result, ok := r.cached(source, opts); ok {
+			results[i].RenderResult = result
+			continue
+		}
+		missing = append(missing, source)
+		missingIndices = append(missingIndices, i)
+	}
+
+	if len(missing) == 0 {
+		return results
+	}
+
+	for j, result := range RenderBatch(ctx, r.Renderer, missing, opts) {
+		i := missingIndices[j]
+		results[i] = result
+		if result.Err != nil {
+			continue
+		}
+		for format, data := range result.Artifacts {
+			if err := r.Cache.Put(r.key(missing[j], opts, format), data); err != nil {
+				log.Printf("failed to cache rendered %s: %v", format, err)
+			}
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,139 @@
+package lilypond
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Severity string
+
+const (
+	SeverityError            Severity = "error"
+	SeverityWarning          Severity = "warning"
+	SeverityFatalError       Severity = "fatal error"
+	SeverityProgrammingError Severity = "programming error"
+)
+
+// Diagnostic is a message lilypond logged about a source, Line and Column are 1-based and 0 when unknown.
+type Diagnostic struct {
+	File     string
+	Line     int
+	Column   int
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	location := d.File
+	if d.Line > 0 {
+		location += fmt.Sprintf(":%d", d.Line)
+	}
+	if d.Column > 0 {
+		location += fmt.Sprintf(":%d", d.Column)
+	}
+	if location == "" {
+		return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+	}
+
+	return fmt.Sprintf("%s: %s: %s", location, d.Severity, d.Message)
+}
+
+// e.g. /tmp/sources-1/2.ly:12:5: error: syntax error, unexpected '}'
+var diagnosticRegexp = regexp.MustCompile(`^(?:(.+?):(\d+):(?:(\d+):)? )?(error|warning|fatal error|programming error): (.*)$`)
+
+// ParseDiagnostics returns the errors and warnings in lilypond's output, skipping the progress messages
+// and the source excerpts printed under a diagnostic.
+func ParseDiagnostics(output string) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		match := diagnosticRegexp.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match == nil {
+			continue
+		}
+
+		diagnostic := Diagnostic{File: match[1], Severity: Severity(match[4]), Message: match[5]}
+		diagnostic.Line, _ = strconv.Atoi(match[2])
+		diagnostic.Column, _ = strconv.Atoi(match[3])
+		diagnostics = append(diagnostics, diagnostic)
+	}
+
+	return diagnostics
+}
+
+// RenderError is returned for a source lilypond failed on, with the diagnostics about its file.
+// Command is the command line of the failed run, it's left out of the error message as it lists the whole batch.
+type RenderError struct {
+	Command     string
+	Err         error
+	Diagnostics []Diagnostic
+}
+
+func (e *RenderError) Error() string {
+	if len(e.Diagnostics) == 0 {
+		return e.Err.Error()
+	}
+
+	var diagnostics []string
+	for _, diagnostic := range e.Diagnostics {
+		diagnostics = append(diagnostics, diagnostic.String())
+	}
+	return fmt.Sprintf("%v: %s", e.Err, strings.Join(diagnostics, "; "))
+}
+
+// DebugDir keeps the sources of failed renders, named by their hash, so every failing template is stored once.
+// Every failure adds lines to index.txt with the name of the card and the diagnostics, pointing to the kept source.
+// A nil DebugDir discards the failures.
+type DebugDir struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+const debugIndexFile = "index.txt"
+
+// Record keeps the source the card named name failed to render with err.
+func (d *DebugDir) Record(name string, source string, err error) error {
+	if d == nil {
+		return nil
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := os.MkdirAll(d.Path, 0770); err != nil {
+		return fmt.Errorf("failed to create debug dir %s: %v", d.Path, err)
+	}
+
+	sourcePath := filepath.Join(d.Path, fmt.Sprintf("%x.ly", md5.Sum([]byte(source))))
+	if err := ioutil.WriteFile(sourcePath, []byte(source), 0660); err != nil {
+		return fmt.Errorf("failed to write failed source: %v", err)
+	}
+
+	var lines []string
+	if renderErr, ok := err.(*RenderError); ok && len(renderErr.Diagnostics) > 0 {
+		for _, diagnostic := range renderErr.Diagnostics {
+			diagnostic.File = sourcePath
+			lines = append(lines, fmt.Sprintf("%s\t%s\n", name, diagnostic))
+		}
+	} else {
+		lines = append(lines, fmt.Sprintf("%s\t%s: %s\n", name, sourcePath, strings.ReplaceAll(err.Error(), "\n", " ")))
+	}
+
+	index, err := os.OpenFile(filepath.Join(d.Path, debugIndexFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0660)
+	if err != nil {
+		return fmt.Errorf("failed to open debug index: %v", err)
+	}
+	defer index.Close()
+
+	if _, err := index.WriteString(strings.Join(lines, "")); err != nil {
+		return fmt.Errorf("failed to write debug index: %v", err)
+	}
+
+	return nil
+}
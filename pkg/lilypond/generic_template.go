@@ -2,77 +2,244 @@ package lilypond
 
 import (
 	"fmt"
+	"github.com/lsierant/notes-gen/pkg/notes"
 	"strings"
 )
 
 type MultipleChords struct {
-	Scale  string
+	Key    Key
 	Chords []SingleChord
 }
+
+// SingleChord holds the notes of a chord on each staff of a piano score, an empty staff gets a spacer.
+// Lyric, Figures and Symbol are optional annotations, see Annotation for their format. Styles highlights
+// notes by their lilypond symbol, e.g. "c'".
 type SingleChord struct {
-	TrebleRaw   string
-	TrebleNotes []string
-	BassRaw     string
-	BassNotes   []string
+	Treble  []notes.Note
+	Bass    []notes.Note
+	Lyric   string
+	Figures string
+	Symbol  string
+	Styles  map[string]NoteStyle
+}
+
+func NewSingleChord(chord notes.Chord) SingleChord {
+	chordOnClefs := notes.ChordToChordOnClefs(chord)
+	return SingleChord{Treble: chordOnClefs.TrebleClefNotes, Bass: chordOnClefs.BassClefNotes}
+}
+
+func staffEvent(pitches []notes.Note, styles map[string]NoteStyle) Event {
+	var pitchStyles []NoteStyle
+	for _, pitch := range pitches {
+		pitchStyles = append(pitchStyles, styles[pitch.LilypondSymbol()])
+	}
+
+	switch len(pitches) {
+	case 0:
+		return Spacer{Duration: Quarter}
+	case 1:
+		return Note{Pitch: pitches[0], Duration: Quarter, Style: pitchStyles[0]}
+	default:
+		if len(styles) == 0 {
+			pitchStyles = nil
+		}
+		return Chord{Pitches: pitches, Duration: Quarter, Styles: pitchStyles}
+	}
+}
+
+// pianoScore returns a treble and a bass staff with a single voice each, like all generated cards use.
+func pianoScore(key Key, upper []Event, lower []Event) Score {
+	return Score{
+		Staves: []Staff{
+			{Name: "upper", Clef: ClefTreble, Key: &key, HideTimeSignature: true, Voices: []Voice{{Events: upper}}},
+			{Name: "lower", Clef: ClefBass, Key: &key, HideTimeSignature: true, Voices: []Voice{{Events: lower}}},
+		},
+		Piano: true,
+		Midi:  true,
+	}
+}
+
+// Score returns the chords played one after another as quarter notes, with an annotation line
+// for every kind of annotation set on any of the chords.
+func (c MultipleChords) Score() Score {
+	var upper, lower []Event
+	lines := map[AnnotationKind][]AnnotationItem{}
+	used := map[AnnotationKind]bool{}
+	for _, chord := range c.Chords {
+		upper = append(upper, staffEvent(chord.Treble, chord.Styles))
+		lower = append(lower, staffEvent(chord.Bass, chord.Styles))
+
+		for kind, text := range map[AnnotationKind]string{AnnotationChordNames: chord.Symbol, AnnotationLyrics: chord.Lyric, AnnotationFigures: chord.Figures} {
+			lines[kind] = append(lines[kind], AnnotationItem{Text: text, Duration: Quarter})
+			used[kind] = used[kind] || text != ""
+		}
+	}
+
+	score := pianoScore(c.Key, upper, lower)
+	for _, kind := range []AnnotationKind{AnnotationChordNames, AnnotationLyrics, AnnotationFigures} {
+		if used[kind] {
+			score.Annotations = append(score.Annotations, Annotation{Kind: kind, Items: lines[kind]})
+		}
+	}
+
+	return score
+}
+
+// ChordAnnotations selects the annotations added by NewAnnotatedSingleChord, the Highlights are drawn with HighlightStyle.
+type ChordAnnotations struct {
+	RomanNumerals  bool
+	Figures        bool
+	Names          bool
+	Highlights     []Highlight
+	HighlightStyle NoteStyle
+}
+
+// Highlight selects the notes of a chord drawn with the highlight style.
+type Highlight string
+
+const (
+	HighlightRoot        Highlight = "root"
+	HighlightThird       Highlight = "third"
+	HighlightFifth       Highlight = "fifth"
+	HighlightSeventh     Highlight = "seventh"
+	HighlightLeadingTone Highlight = "leadingTone"
+)
+
+var DefaultHighlightStyle = NoteStyle{Color: "#d62728"}
+
+var chordToneHighlights = map[Highlight]int{HighlightRoot: 1, HighlightThird: 3, HighlightFifth: 5, HighlightSeventh: 7}
+
+// ParseHighlights parses a comma separated list of "root", "third", "fifth", "seventh" and "leadingTone".
+func ParseHighlights(list string) ([]Highlight, error) {
+	var highlights []Highlight
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		highlight := Highlight(name)
+		if _, ok := chordToneHighlights[highlight]; !ok && highlight != HighlightLeadingTone {
+			return nil, fmt.Errorf("invalid highlight: %s, expected one of: root, third, fifth, seventh, leadingTone", name)
+		}
+		highlights = append(highlights, highlight)
+	}
+
+	return highlights, nil
+}
+
+func (h Highlight) matches(chord notes.Chord, n notes.Note) bool {
+	if h == HighlightLeadingTone {
+		return chord.Scale.IsLeadingTone(n)
+	}
+	return chord.ChordTone(n) == chordToneHighlights[h]
 }
 
-func (c SingleChord) Bass() string {
-	if c.BassRaw != "" {
-		return c.BassRaw
+// ParseChordAnnotations parses a comma separated list of "roman", "figures" and "names".
+func ParseChordAnnotations(list string) (ChordAnnotations, error) {
+	annotations := ChordAnnotations{}
+	for _, name := range strings.Split(list, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+		case "roman":
+			annotations.RomanNumerals = true
+		case "figures":
+			annotations.Figures = true
+		case "names":
+			annotations.Names = true
+		default:
+			return ChordAnnotations{}, fmt.Errorf("invalid annotation: %s, expected one of: roman, figures, names", name)
+		}
 	}
 
-	return fmt.Sprintf("<%s>4", strings.Join(c.BassNotes, " "))
+	return annotations, nil
 }
 
-func (c SingleChord) Treble() string {
-	if c.TrebleRaw != "" {
-		return c.TrebleRaw
+// NewAnnotatedSingleChord labels the chord with its roman numeral under the staves, the figured bass of its
+// inversion and its chord symbol above the staves, and highlights the selected notes.
+func NewAnnotatedSingleChord(chord notes.Chord, annotations ChordAnnotations) SingleChord {
+	singleChord := NewSingleChord(chord)
+	for _, n := range chord.Notes {
+		for _, highlight := range annotations.Highlights {
+			if !highlight.matches(chord, n) {
+				continue
+			}
+			if singleChord.Styles == nil {
+				singleChord.Styles = map[string]NoteStyle{}
+			}
+			singleChord.Styles[n.LilypondSymbol()] = annotations.HighlightStyle
+		}
+	}
+	if annotations.RomanNumerals {
+		singleChord.Lyric = chord.RomanNumeral()
+	}
+	if annotations.Figures {
+		singleChord.Figures = FiguredBass(chord)
+	}
+	if annotations.Names {
+		singleChord.Symbol = ChordModeSymbol(chord)
 	}
 
-	return fmt.Sprintf("<%s>4", strings.Join(c.TrebleNotes, " "))
+	return singleChord
 }
 
-var chordTemplate = `
-\version "2.14.1"
-\include "lilypond-book-preamble.ly" 
+var (
+	triadFigures   = []string{"", "6", "6 4"}
+	seventhFigures = []string{"7", "6 5", "4 3", "4 2"}
+)
+
+// FiguredBass returns the figures of the chord's inversion, empty for a triad in root position.
+func FiguredBass(chord notes.Chord) string {
+	inversion := chord.Inversion()
+	if chord.IsTriad() {
+		if inversion < len(triadFigures) {
+			return triadFigures[inversion]
+		}
+		return ""
+	}
 
-\paper{
-  indent=0\mm
-  line-width=120\mm
-  oddFooterMarkup=##f
-  oddHeaderMarkup=##f
-  bookTitleMarkup = ##f
-  scoreTitleMarkup = ##f
+	return seventhFigures[inversion]
 }
 
-upper = {
-  \clef treble
-  \once \override Staff.TimeSignature #'transparent = ##t
-  \key {{.Scale}}
+var chordModeModifiers = map[notes.ChordType]string{
+	notes.ChordTypeMajorTriad:            "",
+	notes.ChordTypeMinorTriad:            ":m",
+	notes.ChordTypeDiminishedTriad:       ":dim",
+	notes.ChordTypeAugmentedTriad:        ":aug",
+	notes.ChordTypeMinorSeventh:          ":m7",
+	notes.ChordTypeMajorSeventh:          ":maj7",
+	notes.ChordTypeDominantSeventh:       ":7",
+	notes.ChordTypeDiminishedSeventh:     ":dim7",
+	notes.ChordTypeHalfDiminishedSeventh: ":m7.5-",
+}
 
-{{range .Chords}}
-	{{ .Treble }}
-{{end}}
+// ChordModeSymbol returns the chord symbol of Chord.Name in lilypond's chordmode, e.g. "g:7".
+func ChordModeSymbol(chord notes.Chord) string {
+	return chord.RootNote.NameWithModifier() + chordModeModifiers[chord.Type]
 }
 
-lower = {
-    \once \override Staff.TimeSignature #'transparent = ##t
-	\key {{.Scale}}
+// NewIntervalChord returns the interval as a chord with the notes on the staves of their clefs.
+func NewIntervalChord(interval notes.Interval) (SingleChord, error) {
+	first := interval.FirstNote
+	second := interval.SecondNote
+
+	if first.BassClef && second.BassClef {
+		return SingleChord{Bass: []notes.Note{first, second}}, nil
+	} else if first.BassClef && second.TrebleClef {
+		return SingleChord{Treble: []notes.Note{second}, Bass: []notes.Note{first}}, nil
+	} else if first.TrebleClef && second.TrebleClef {
+		return SingleChord{Treble: []notes.Note{first, second}}, nil
+	}
 
-    \clef bass
-    
-{{range .Chords}}
-	{{ .Bass }}
-{{end}}
+	return SingleChord{}, fmt.Errorf("not supported interval: %+v", interval)
 }
 
-\score {
-  \new PianoStaff
-  <<
-    \new Staff = "upper" \upper
-    \new Staff = "lower" \lower
-  >>
-  \layout { }
-  \midi { }
+// IntervalScore returns the interval as a quarter note dyad with the notes on the staves of their clefs.
+func IntervalScore(interval notes.Interval) (Score, error) {
+	chord, err := NewIntervalChord(interval)
+	if err != nil {
+		return Score{}, err
+	}
+
+	return pianoScore(ScaleKey(interval.Scale), []Event{staffEvent(chord.Treble, nil)}, []Event{staffEvent(chord.Bass, nil)}), nil
 }
-`
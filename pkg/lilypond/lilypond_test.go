@@ -0,0 +1,472 @@
+package lilypond
+
+import (
+	"context"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/cache"
+	"github.com/lsierant/notes-gen/pkg/engraver"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFakeRenderer(t *testing.T) {
+	ctx := context.Background()
+	renderer := &FakeRenderer{}
+
+	chords := MultipleChords{Key: ScaleKey(notes.CMajorScale), Chords: []SingleChord{{Treble: parseNotes(t, "c'", "e'", "g'")}}}
+	first, err := RenderChordImage(ctx, renderer, DefaultTemplates, chords, DefaultRenderOptions)
+	assert.NoError(t, err)
+	second, err := RenderChordImage(ctx, renderer, DefaultTemplates, chords, DefaultRenderOptions)
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, "\x89PNG", string(first.PNG()[:4]))
+
+	c, _ := notes.ParseNote("c'")
+	g, _ := notes.ParseNote("g'")
+	interval, err := RenderIntervalImage(ctx, renderer, DefaultTemplates, notes.Interval{FirstNote: c, SecondNote: g, Scale: notes.CMajorScale}, RenderOptions{Formats: []Format{FormatPNG, FormatSVG}})
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.PNG(), interval.PNG())
+	assert.True(t, strings.HasPrefix(string(interval.Artifacts[FormatSVG]), "fake svg"))
+
+	if assert.Len(t, renderer.Sources, 3) {
+		assert.True(t, strings.Contains(renderer.Sources[0], "<c' e' g'>4"))
+		assert.True(t, strings.Contains(renderer.Sources[2], "<c' g'>4"))
+	}
+}
+
+// fakeLilypond copies every source to the outputs selected by the arguments unless it contains FAIL,
+// which is reported like a lilypond error. Like lilypond, the svg backend writes name-1.svg.
+var fakeLilypond = `#!/bin/sh
+out=""
+outputs=""
+status=0
+while [ $# -gt 0 ]; do
+  case "$1" in
+    -o) out="$2"; shift;;
+    --png) outputs="$outputs png";;
+    --pdf) outputs="$outputs pdf";;
+    -dbackend=svg) outputs="$outputs -1.svg";;
+    *.ly)
+      name=$(basename "$1" .ly)
+      if grep -q FAIL "$1"; then
+        echo "$1:1:1: error: syntax error"; status=1
+      else
+        cp "$1" "$out/$name.midi"
+        for output in $outputs; do
+          case "$output" in
+            -*) cp "$1" "$out/$name$output";;
+            *) cp "$1" "$out/$name.$output";;
+          esac
+        done
+      fi;;
+  esac
+  shift
+done
+exit $status
+`
+
+func TestLocalRendererBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lilypond-test-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	binary := filepath.Join(dir, "lilypond")
+	assert.NoError(t, ioutil.WriteFile(binary, []byte(fakeLilypond), 0770))
+
+	renderer := &LocalRenderer{WorkingDir: dir, Binary: binary}
+	results := RenderBatch(context.Background(), renderer, []string{"first", "FAIL", "third"}, DefaultRenderOptions)
+	if assert.Len(t, results, 3) {
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, "first", string(results[0].PNG()))
+		assert.Error(t, results[1].Err)
+		assert.True(t, strings.Contains(results[1].Err.Error(), "2.ly:1:1: error: syntax error"))
+		if renderErr, ok := results[1].Err.(*RenderError); assert.True(t, ok) && assert.Len(t, renderErr.Diagnostics, 1) {
+			assert.Equal(t, "2.ly", filepath.Base(renderErr.Diagnostics[0].File))
+			assert.Equal(t, SeverityError, renderErr.Diagnostics[0].Severity)
+			assert.False(t, strings.Contains(renderErr.Error(), "first"))
+		}
+		assert.NoError(t, results[2].Err)
+		assert.Equal(t, "third", string(results[2].PNG()))
+	}
+
+	result, err := renderer.Render(context.Background(), "single", RenderOptions{Formats: []Format{FormatPNG, FormatSVG, FormatPDF, FormatMIDI}})
+	assert.NoError(t, err)
+	assert.Len(t, result.Artifacts, 4)
+	for format, data := range result.Artifacts {
+		assert.Equal(t, "single", string(data), format)
+	}
+
+	_, err = renderer.Render(context.Background(), "single", RenderOptions{Formats: []Format{FormatEPS}})
+	assert.Error(t, err)
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	output := `GNU LilyPond 2.14.2
+Processing ` + "`/d/3.ly'" + `
+Parsing...
+/d/3.ly:12:5: error: syntax error, unexpected '}'
+  <c' e' g'>4 
+              }
+/d/3.ly:2: warning: no \version statement found
+fatal error: failed files: "/d/3.ly"
+`
+	diagnostics := ParseDiagnostics(output)
+	assert.Equal(t, []Diagnostic{
+		{File: "/d/3.ly", Line: 12, Column: 5, Severity: SeverityError, Message: "syntax error, unexpected '}'"},
+		{File: "/d/3.ly", Line: 2, Severity: SeverityWarning, Message: `no \version statement found`},
+		{Severity: SeverityFatalError, Message: `failed files: "/d/3.ly"`},
+	}, diagnostics)
+	assert.Equal(t, "/d/3.ly:12:5: error: syntax error, unexpected '}'", diagnostics[0].String())
+	assert.Equal(t, `fatal error: failed files: "/d/3.ly"`, diagnostics[2].String())
+}
+
+func TestDebugDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lilypond-test-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	debugDir := &DebugDir{Path: filepath.Join(dir, "errors")}
+	renderErr := &RenderError{Err: fmt.Errorf("lilypond failed: exit status 1"), Diagnostics: []Diagnostic{{File: "/d/1.ly", Line: 3, Column: 1, Severity: SeverityError, Message: "syntax error"}}}
+	assert.NoError(t, debugDir.Record("images/first.png", "bad source", renderErr))
+	assert.NoError(t, debugDir.Record("images/second.png", "bad source", renderErr))
+	assert.NoError(t, debugDir.Record("images/third.png", "other source", fmt.Errorf("unsupported\ncommand")))
+
+	files, err := ioutil.ReadDir(debugDir.Path)
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+
+	index, err := ioutil.ReadFile(filepath.Join(debugDir.Path, "index.txt"))
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(index)), "\n")
+	if assert.Len(t, lines, 3) {
+		sourcePath := strings.SplitN(strings.Split(lines[0], "\t")[1], ":", 2)[0]
+		source, err := ioutil.ReadFile(sourcePath)
+		assert.NoError(t, err)
+		assert.Equal(t, "bad source", string(source))
+		assert.Equal(t, "images/first.png\t"+sourcePath+":3:1: error: syntax error", lines[0])
+		assert.Equal(t, "images/second.png\t"+sourcePath+":3:1: error: syntax error", lines[1])
+		assert.True(t, strings.HasSuffix(lines[2], ".ly: unsupported command"), lines[2])
+	}
+
+	var disabled *DebugDir
+	assert.NoError(t, disabled.Record("images/first.png", "bad source", renderErr))
+}
+
+func TestRenderResultWriteFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lilypond-result-test-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	result := RenderResult{Artifacts: map[Format][]byte{FormatPNG: []byte("png"), FormatMIDI: []byte("midi")}}
+	assert.NoError(t, result.WriteFiles(filepath.Join(dir, "chord")))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "chord.png"))
+	assert.NoError(t, err)
+	assert.Equal(t, "png", string(data))
+	data, err = ioutil.ReadFile(filepath.Join(dir, "chord.midi"))
+	assert.NoError(t, err)
+	assert.Equal(t, "midi", string(data))
+}
+
+func TestParseFormats(t *testing.T) {
+	formats, err := ParseFormats("png, SVG,midi")
+	assert.NoError(t, err)
+	assert.Equal(t, []Format{FormatPNG, FormatSVG, FormatMIDI}, formats)
+
+	_, err = ParseFormats("gif")
+	assert.Error(t, err)
+	_, err = ParseFormats("")
+	assert.Error(t, err)
+}
+
+func TestCachedRenderer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lilypond-cache-test-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	renderCache, err := cache.Open(dir, 0)
+	assert.NoError(t, err)
+	fake := &FakeRenderer{}
+	renderer := &CachedRenderer{Renderer: fake, Cache: renderCache}
+
+	results := RenderBatch(context.Background(), renderer, []string{"a", "b"}, DefaultRenderOptions)
+	assert.Len(t, results, 2)
+	result, err := renderer.Render(context.Background(), "a", DefaultRenderOptions)
+	assert.NoError(t, err)
+	assert.Equal(t, results[0].PNG(), result.PNG())
+	assert.Equal(t, []string{"a", "b"}, fake.Sources)
+
+	// another format or resolution is rendered again
+	_, err = renderer.Render(context.Background(), "a", RenderOptions{Formats: []Format{FormatPNG, FormatSVG}})
+	assert.NoError(t, err)
+	_, err = renderer.Render(context.Background(), "a", RenderOptions{DPI: 600})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "a", "a"}, fake.Sources)
+
+	// a different renderer version doesn't reuse the images
+	_, err = (&CachedRenderer{Renderer: &NativeRenderer{}, Cache: renderCache}).Render(context.Background(), "a", DefaultRenderOptions)
+	assert.Error(t, err)
+}
+
+func TestTemplates(t *testing.T) {
+	c, _ := notes.ParseNote("c")
+	e, _ := notes.ParseNote("e'")
+	g, _ := notes.ParseNote("g'")
+
+	source, err := DefaultTemplates.IntervalSource(notes.Interval{FirstNote: c.OnNearestClef(), SecondNote: g.OnNearestClef(), Scale: notes.CMajorScale})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(source, `\version "2.14.1"`))
+	assert.True(t, strings.Contains(source, `line-width=120\mm`))
+	score, err := engraver.ParseLilypond(source)
+	assert.NoError(t, err)
+	if assert.Len(t, score.Columns, 1) {
+		assert.Len(t, score.Columns[0].Upper, 1)
+		assert.Len(t, score.Columns[0].Lower, 1)
+	}
+
+	dir, err := ioutil.TempDir("", "lilypond-templates-test-*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	paper := filepath.Join(dir, "paper.ly")
+	assert.NoError(t, ioutil.WriteFile(paper, []byte(`{{define "paper"}}\paper{ line-width={{.Layout.PaperWidth}} ragged-right=##t }{{end}}`), 0660))
+	layout := DefaultLayout
+	layout.LilypondVersion = "2.24.0"
+	layout.StaffSize = 18
+	layout.PaperWidth = `80\mm`
+	layout.Font = "Times"
+	templates, err := NewTemplates(layout, paper)
+	assert.NoError(t, err)
+
+	chords := MultipleChords{Key: ScaleKey(notes.CMajorScale), Chords: []SingleChord{{Treble: parseNotes(t, "e'", "g'"), Bass: parseNotes(t, "c")}, {}}}
+	source, err = templates.ChordSource(chords)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(source, `\version "2.24.0"`))
+	assert.True(t, strings.Contains(source, "#(set-global-staff-size 18)"))
+	assert.True(t, strings.Contains(source, `\paper{ line-width=80\mm ragged-right=##t }`))
+	assert.False(t, strings.Contains(source, `make-pango-font-tree "Times"`))
+	score, err = engraver.ParseLilypond(source)
+	assert.NoError(t, err)
+	assert.Len(t, score.Columns, 2)
+
+	document := filepath.Join(dir, "document.ly")
+	assert.NoError(t, ioutil.WriteFile(document, []byte(`{{template "version" .}} {{len .Chords}} {{(index .Score.Staves 0).Name}}`), 0660))
+	templates, err = NewTemplates(DefaultLayout, document)
+	assert.NoError(t, err)
+	source, err = templates.ChordSource(chords)
+	assert.NoError(t, err)
+	assert.Equal(t, `\version "2.14.1" 2 upper`, source)
+	_, err = templates.IntervalSource(notes.Interval{FirstNote: e.OnNearestClef(), SecondNote: g.OnNearestClef(), Scale: notes.CMajorScale})
+	assert.NoError(t, err)
+
+	invalid := filepath.Join(dir, "invalid.ly")
+	assert.NoError(t, ioutil.WriteFile(invalid, []byte(`{{define "music"}}{{.Missing}}{{end}}`), 0660))
+	_, err = NewTemplates(DefaultLayout, invalid)
+	assert.Error(t, err)
+
+	layout = DefaultLayout
+	layout.PaperWidth = "120"
+	_, err = NewTemplates(layout)
+	assert.Error(t, err)
+}
+
+func parseNotes(t *testing.T, names ...string) []notes.Note {
+	var result []notes.Note
+	for _, name := range names {
+		n, err := notes.ParseNote(name)
+		assert.NoError(t, err)
+		result = append(result, n)
+	}
+	return result
+}
+
+func TestScore(t *testing.T) {
+	chords := MultipleChords{Key: Key{Tonic: "fis", Mode: ModeMajor}, Chords: []SingleChord{{Treble: parseNotes(t, "ais'", "cis''"), Bass: parseNotes(t, "fis")}, {Bass: parseNotes(t, "cis", "fis")}}}
+	music, err := chords.Score().Lilypond()
+	assert.NoError(t, err)
+	assert.Equal(t, `upper = {
+  \clef treble
+  \once \override Staff.TimeSignature #'transparent = ##t
+  \key fis \major
+
+  <ais' cis''>4 s4
+}
+
+lower = {
+  \clef bass
+  \once \override Staff.TimeSignature #'transparent = ##t
+  \key fis \major
+
+  fis4 <cis fis>4
+}
+
+\score {
+  \new PianoStaff
+  <<
+    \new Staff = "upper" \upper
+    \new Staff = "lower" \lower
+  >>
+  \layout { }
+  \midi { }
+}
+`, music)
+
+	score := Score{Staves: []Staff{
+		{Name: `Viola "1"`, Clef: ClefAlto, Voices: []Voice{
+			{Events: []Event{Note{Pitch: parseNotes(t, "d'")[0], Duration: Duration{Length: 2, Dots: 1}}, Rest{Duration: Quarter}}},
+			{Events: []Event{Note{Pitch: parseNotes(t, "g")[0], Duration: Duration{Length: 1}}}},
+		}},
+		{Name: "Viola 2", Clef: ClefAlto},
+	}}
+	music, err = score.Lilypond()
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(music, "Viola = {\n  \\clef alto\n\n  <<\n    { d'2. r4 }\n    \\\\\n    { g1 }\n  >>\n}"))
+	assert.True(t, strings.Contains(music, `\new Staff = "Viola \"1\"" \Viola`))
+	assert.True(t, strings.Contains(music, `\new Staff = "Viola 2" \ViolaA`))
+	assert.False(t, strings.Contains(music, "PianoStaff"))
+	assert.False(t, strings.Contains(music, "midi"))
+
+	_, err = Score{Staves: []Staff{{Voices: []Voice{{Events: []Event{Spacer{Duration: Duration{Length: 3}}}}}}}}.Lilypond()
+	assert.Error(t, err)
+	_, err = Score{Staves: []Staff{{Key: &Key{Tonic: "h", Mode: ModeMajor}}}}.Lilypond()
+	assert.Error(t, err)
+	_, err = Score{Staves: []Staff{{Voices: []Voice{{Events: []Event{Chord{Duration: Quarter}}}}}}}.Lilypond()
+	assert.Error(t, err)
+	_, err = Score{}.Lilypond()
+	assert.Error(t, err)
+
+	assert.Equal(t, Key{Tonic: "fis", Mode: ModeMajor}, ScaleKey(notes.ScaleMap["f sharp major"]))
+	assert.Equal(t, Key{Tonic: "a", Mode: ModeMinor}, ScaleKey(notes.AMinorScale))
+}
+
+func TestChordAnnotations(t *testing.T) {
+	annotations, err := ParseChordAnnotations("roman, figures,names")
+	assert.NoError(t, err)
+	assert.Equal(t, ChordAnnotations{RomanNumerals: true, Figures: true, Names: true}, annotations)
+	_, err = ParseChordAnnotations("numbers")
+	assert.Error(t, err)
+
+	g := parseNotes(t, "g")[0]
+	dominant := notes.Chord{Scale: notes.CMajorScale, RootNote: g, Type: notes.ChordTypeDominantSeventh, Notes: parseNotes(t, "b", "d'", "f'", "g'")}
+	assert.Equal(t, "6 5", FiguredBass(dominant))
+	assert.Equal(t, "g:7", ChordModeSymbol(dominant))
+	triad := notes.Chord{Scale: notes.CMajorScale, RootNote: g, Type: notes.ChordTypeMajorTriad, Notes: parseNotes(t, "d'", "g'", "b'")}
+	assert.Equal(t, "6 4", FiguredBass(triad))
+
+	chords := MultipleChords{Key: ScaleKey(notes.CMajorScale), Chords: []SingleChord{
+		{Treble: parseNotes(t, "d'", "g'", "b'"), Lyric: "V", Symbol: "g", Figures: "6 4"},
+		{Treble: parseNotes(t, "c'", "e'", "g'"), Lyric: `"I"`},
+	}}
+	music, err := chords.Score().Lilypond()
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(music, `
+  <<
+    \new ChordNames \chordmode { g4 \skip 4 }
+    \new PianoStaff
+    <<
+      \new Staff = "upper" \upper
+      \new Staff = "lower" \lower
+    >>
+    \new Lyrics \lyricmode { "V"4 "\"I\""4 }
+    \new FiguredBass \figuremode { <6 4>4 \skip 4 }
+  >>
+  \layout { }`), music)
+
+	score, err := engraver.ParseLilypond(music)
+	assert.NoError(t, err)
+	assert.Len(t, score.Columns, 2)
+
+	chords.Chords[1].Symbol = "c:maj7/e"
+	music, err = chords.Score().Lilypond()
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(music, `\chordmode { g4 c4:maj7/e }`))
+
+	chords.Chords[1].Symbol = "C major"
+	_, err = chords.Score().Lilypond()
+	assert.Error(t, err)
+	chords.Chords[1].Symbol = ""
+	chords.Chords[1].Figures = "6#"
+	_, err = chords.Score().Lilypond()
+	assert.Error(t, err)
+}
+
+func TestWorksheet(t *testing.T) {
+	cMajor := ScaleKey(notes.CMajorScale)
+	gMajor := ScaleKey(notes.ScaleMap["g major"])
+	worksheet := Worksheet{Title: `Triads "1"`, PaperSize: "letter", ItemsPerRow: 2, ItemsPerPage: 4}
+	for i := 0; i < 5; i++ {
+		key := cMajor
+		if i == 4 {
+			key = gMajor
+		}
+		worksheet.Exercises = append(worksheet.Exercises, Exercise{Key: key, Chord: SingleChord{Treble: parseNotes(t, "c'", "e'", "g'")}, Answer: "C major"})
+	}
+
+	source, err := DefaultTemplates.WorksheetSource(worksheet, false)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(source, `#(set-default-paper-size "letter")`), source)
+	assert.True(t, strings.Contains(source, `title = "Triads \"1\""`), source)
+	assert.False(t, strings.Contains(source, "Answer key"), source)
+	assert.False(t, strings.Contains(source, "lilypond-book-preamble"), source)
+	assert.True(t, strings.Contains(source, `\cadenzaOn`), source)
+	assert.True(t, strings.Contains(source, `<c' e' g'>4 \bar "|" <c' e' g'>4 \bar "|" \break <c' e' g'>4 \bar "|" <c' e' g'>4 \bar "|" \pageBreak \key g \major <c' e' g'>4 \bar "|."`), source)
+	assert.True(t, strings.Contains(source, `\lyricmode { "1. __________"4 "2. __________"4 "3. __________"4 "4. __________"4 "5. __________"4 }`), source)
+	assert.False(t, strings.Contains(source, `\midi`), source)
+
+	source, err = DefaultTemplates.WorksheetSource(worksheet, true)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(source, `subtitle = "Answer key"`), source)
+	assert.True(t, strings.Contains(source, `"1. C major"4`), source)
+
+	worksheet.ItemsPerPage = 1
+	_, err = DefaultTemplates.WorksheetSource(worksheet, false)
+	assert.Error(t, err)
+	worksheet.ItemsPerPage = 4
+	worksheet.PaperSize = `a4" #(system "ls")`
+	_, err = DefaultTemplates.WorksheetSource(worksheet, false)
+	assert.Error(t, err)
+}
+
+func TestHighlights(t *testing.T) {
+	highlights, err := ParseHighlights("root, leadingTone")
+	assert.NoError(t, err)
+	assert.Equal(t, []Highlight{HighlightRoot, HighlightLeadingTone}, highlights)
+	_, err = ParseHighlights("bass")
+	assert.Error(t, err)
+
+	g := parseNotes(t, "g")[0]
+	dominant := notes.Chord{Scale: notes.CMajorScale, RootNote: g, Type: notes.ChordTypeDominantSeventh}
+	for _, n := range parseNotes(t, "f'", "d'", "b", "g") {
+		dominant.Notes = append(dominant.Notes, n.OnNearestClef())
+	}
+	style := NoteStyle{Color: "#ff0000", Head: NoteHeadDiamond, Parenthesized: true}
+	chord := NewAnnotatedSingleChord(dominant, ChordAnnotations{Highlights: highlights, HighlightStyle: style})
+	assert.Equal(t, map[string]NoteStyle{"g": style, "b": style}, chord.Styles)
+
+	chords := MultipleChords{Key: ScaleKey(notes.CMajorScale), Chords: []SingleChord{{
+		Treble: parseNotes(t, "c'", "e'", "g'"),
+		Bass:   parseNotes(t, "c"),
+		Styles: map[string]NoteStyle{"e'": {Color: "#ff8000"}, "c": {Head: NoteHeadCross}},
+	}}}
+	music, err := chords.Score().Lilypond()
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(music, `<c' \tweak #'color #(rgb-color 1.000 0.502 0.000) e' g'>4`), music)
+	assert.True(t, strings.Contains(music, `<\tweak #'style #'cross c>4`), music)
+
+	score, err := engraver.ParseLilypond(music)
+	assert.NoError(t, err)
+	if assert.Len(t, score.Columns, 1) {
+		assert.Len(t, score.Columns[0].Upper, 3)
+		assert.Equal(t, map[string]string{"e'": "#ff8000"}, score.Columns[0].Colors)
+	}
+
+	chords.Chords[0].Styles["e'"] = NoteStyle{Color: "red"}
+	_, err = chords.Score().Lilypond()
+	assert.Error(t, err)
+	assert.Error(t, NoteStyle{Head: "square"}.Validate())
+}
@@ -0,0 +1,380 @@
+package lilypond
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/engraver"
+	"github.com/lsierant/notes-gen/pkg/midi"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Renderer converts lilypond source to the artifacts selected in the options. Version identifies the backend and
+// everything that affects its output, artifacts from renderers with different versions are cached separately.
+type Renderer interface {
+	Render(ctx context.Context, source string, opts RenderOptions) (RenderResult, error)
+	Version() string
+}
+
+const (
+	DefaultDockerImage = "docker.io/airdock/lilypond"
+	DefaultDockerTag   = "latest"
+	DefaultBinary      = "lilypond"
+)
+
+// BatchResult is the result of one source of a batch or the error that source failed with.
+type BatchResult struct {
+	RenderResult
+	Err error
+}
+
+// BatchRenderer compiles many sources at once, the results are in the order of sources.
+type BatchRenderer interface {
+	Renderer
+	RenderMany(ctx context.Context, sources []string, opts RenderOptions) []BatchResult
+}
+
+// RenderBatch renders all sources in one go when the renderer supports it, otherwise one by one.
+func RenderBatch(ctx context.Context, renderer Renderer, sources []string, opts RenderOptions) []BatchResult {
+	if batchRenderer, ok := renderer.(BatchRenderer); ok && len(sources) > 0 {
+		return batchRenderer.RenderMany(ctx, sources, opts)
+	}
+
+	results := make([]BatchResult, len(sources))
+	for i, source := range sources {
+		results[i].RenderResult, results[i].Err = renderer.Render(ctx, source, opts)
+	}
+	return results
+}
+
+// lilypondRuns returns the arguments of every lilypond run needed for the formats. The SVG backend can't be
+// combined with the EPS one producing the other formats, so SVG takes a run of its own. MIDI is written by any run.
+func lilypondRuns(opts RenderOptions) [][]string {
+	var runs [][]string
+	if opts.has(FormatPNG) || opts.has(FormatPDF) || opts.has(FormatEPS) || (opts.has(FormatMIDI) && !opts.has(FormatSVG)) {
+		args := []string{"-dbackend=eps", "-dno-gs-load-fonts", "-dinclude-eps-fonts", fmt.Sprintf("-dresolution=%d", opts.dpi())}
+		if opts.has(FormatPNG) {
+			args = append(args, "--png")
+			if opts.Transparent {
+				args = append(args, "-dpixmap-format=pngalpha")
+			}
+		}
+		if opts.has(FormatPDF) {
+			args = append(args, "--pdf")
+		}
+		runs = append(runs, args)
+	}
+	if opts.has(FormatSVG) {
+		runs = append(runs, []string{"-dbackend=svg"})
+	}
+
+	return runs
+}
+
+// outputNames lists the files lilypond may write the format to for the source with the given base name
+func outputNames(format Format, baseName string) []string {
+	switch format {
+	case FormatMIDI:
+		return []string{baseName + ".midi", baseName + ".mid"}
+	case FormatEPS, FormatSVG:
+		return []string{fmt.Sprintf("%s.%s", baseName, format), fmt.Sprintf("%s-1.%s", baseName, format)}
+	default:
+		return []string{fmt.Sprintf("%s.%s", baseName, format)}
+	}
+}
+
+// DockerRenderer runs lilypond in a container, ExtraArgs are passed to docker run before the image.
+type DockerRenderer struct {
+	WorkingDir string
+	Image      string
+	Tag        string
+	ExtraArgs  []string
+}
+
+func (r *DockerRenderer) imageReference() string {
+	imageName, tag := r.Image, r.Tag
+	if imageName == "" {
+		imageName = DefaultDockerImage
+	}
+	if tag == "" {
+		tag = DefaultDockerTag
+	}
+
+	return fmt.Sprintf("%s:%s", imageName, tag)
+}
+
+func (r *DockerRenderer) Version() string {
+	return fmt.Sprintf("docker %s %s", r.imageReference(), strings.Join(r.ExtraArgs, " "))
+}
+
+func (r *DockerRenderer) Render(ctx context.Context, source string, opts RenderOptions) (RenderResult, error) {
+	result := r.RenderMany(ctx, []string{source}, opts)[0]
+	return result.RenderResult, result.Err
+}
+
+// RenderMany runs a single container per lilypond run compiling all sources.
+func (r *DockerRenderer) RenderMany(ctx context.Context, sources []string, opts RenderOptions) []BatchResult {
+	return renderBatchInTmpDir(ctx, r.WorkingDir, sources, opts, func(tmpDir string, fileNames []string, lilypondArgs []string) (string, []string) {
+		args := []string{"run", "-v", fmt.Sprintf("%s:/d", tmpDir)}
+		args = append(args, r.ExtraArgs...)
+		args = append(args, r.imageReference())
+		args = append(args, lilypondArgs...)
+		args = append(args, "-o", "/d/out")
+		for _, fileName := range fileNames {
+			args = append(args, "/d/"+fileName)
+		}
+		return "docker", args
+	})
+}
+
+// LocalRenderer runs a locally installed lilypond binary, ExtraArgs are passed to lilypond.
+type LocalRenderer struct {
+	WorkingDir  string
+	Binary      string
+	ExtraArgs   []string
+	versionOnce sync.Once
+	version     string
+}
+
+// Version includes the first line of lilypond --version, so upgrading lilypond invalidates cached images.
+func (r *LocalRenderer) Version() string {
+	r.versionOnce.Do(func() {
+		binaryVersion := "unknown"
+		if output, err := exec.Command(r.binary(), "--version").Output(); err == nil {
+			binaryVersion = strings.TrimSpace(strings.SplitN(string(output), "\n", 2)[0])
+		}
+		r.version = fmt.Sprintf("local %s %s %s", r.binary(), binaryVersion, strings.Join(r.ExtraArgs, " "))
+	})
+
+	return r.version
+}
+
+func (r *LocalRenderer) binary() string {
+	if r.Binary == "" {
+		return DefaultBinary
+	}
+	return r.Binary
+}
+
+func (r *LocalRenderer) Render(ctx context.Context, source string, opts RenderOptions) (RenderResult, error) {
+	result := r.RenderMany(ctx, []string{source}, opts)[0]
+	return result.RenderResult, result.Err
+}
+
+// RenderMany runs lilypond once per lilypond run for all sources.
+func (r *LocalRenderer) RenderMany(ctx context.Context, sources []string, opts RenderOptions) []BatchResult {
+	return renderBatchInTmpDir(ctx, r.WorkingDir, sources, opts, func(tmpDir string, fileNames []string, lilypondArgs []string) (string, []string) {
+		args := append([]string{}, lilypondArgs...)
+		args = append(args, r.ExtraArgs...)
+		args = append(args, "-o", fmt.Sprintf("%s/out", tmpDir))
+		for _, fileName := range fileNames {
+			args = append(args, fmt.Sprintf("%s/%s", tmpDir, fileName))
+		}
+		return r.binary(), args
+	})
+}
+
+func failAll(n int, err error) []BatchResult {
+	results := make([]BatchResult, n)
+	for i := range results {
+		results[i].Err = err
+	}
+	return results
+}
+
+// renderBatchInTmpDir writes the sources to 1.ly, 2.ly, ... in a new temp dir and runs the command returned by
+// commandFn once per lilypond run, it's expected to write the artifacts to out/1.png, out/1.svg, ...
+// Lilypond keeps compiling the other files when one of them fails, so every source gets its own result
+// with a RenderError holding the diagnostics about its file.
+func renderBatchInTmpDir(ctx context.Context, workingDir string, sources []string, opts RenderOptions, commandFn func(tmpDir string, fileNames []string, lilypondArgs []string) (string, []string)) []BatchResult {
+	tmpDir, err := ioutil.TempDir(workingDir, "sources-*")
+	if err != nil {
+		return failAll(len(sources), fmt.Errorf("failed to create temp dir: %v", err))
+	}
+
+	tmpDir, err = filepath.Abs(tmpDir)
+	if err != nil {
+		return failAll(len(sources), fmt.Errorf("failed to get absolute path of %s: %v", tmpDir, err))
+	}
+
+	outDir := fmt.Sprintf("%s/out", tmpDir)
+	err = os.Mkdir(outDir, 0770)
+	if err != nil {
+		return failAll(len(sources), fmt.Errorf("failed to create out dir %s: %v", outDir, err))
+	}
+
+	var fileNames []string
+	for i, source := range sources {
+		filename := fmt.Sprintf("%d.ly", i+1)
+		err = ioutil.WriteFile(fmt.Sprintf("%s/%s", tmpDir, filename), []byte(source), 0660)
+		if err != nil {
+			return failAll(len(sources), fmt.Errorf("failed to write source file: %s/%s: %v", tmpDir, filename, err))
+		}
+		fileNames = append(fileNames, filename)
+	}
+
+	var failedCommand string
+	var commandErr error
+	var output []byte
+	for _, lilypondArgs := range lilypondRuns(opts) {
+		commandName, args := commandFn(tmpDir, fileNames, lilypondArgs)
+		command := exec.CommandContext(ctx, commandName, args...)
+		runOutput, err := command.CombinedOutput()
+		fmt.Printf("running command: \n%s %s\n", commandName, strings.Join(args, " "))
+		fmt.Printf("%s", runOutput)
+		output = append(output, runOutput...)
+		if err != nil && commandErr == nil {
+			failedCommand = fmt.Sprintf("%s %s", commandName, strings.Join(args, " "))
+			commandErr = fmt.Errorf("lilypond failed: %v", err)
+		}
+	}
+
+	diagnostics := ParseDiagnostics(string(output))
+	results := make([]BatchResult, len(sources))
+	failed := false
+	for i, fileName := range fileNames {
+		results[i].Artifacts = map[Format][]byte{}
+		var missing []string
+		for _, format := range opts.formats() {
+			for _, name := range outputNames(format, fmt.Sprintf("%d", i+1)) {
+				if data, err := ioutil.ReadFile(fmt.Sprintf("%s/%s", outDir, name)); err == nil {
+					results[i].Artifacts[format] = data
+					break
+				}
+			}
+			if _, ok := results[i].Artifacts[format]; !ok {
+				missing = append(missing, string(format))
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		failed = true
+		renderErr := &RenderError{Command: failedCommand, Err: commandErr}
+		if commandErr == nil {
+			renderErr.Err = fmt.Errorf("lilypond didn't produce %s output of %s", strings.Join(missing, ", "), fileName)
+		}
+		for _, diagnostic := range diagnostics {
+			if filepath.Base(diagnostic.File) == fileName {
+				renderErr.Diagnostics = append(renderErr.Diagnostics, diagnostic)
+			}
+		}
+		results[i].Err = renderErr
+	}
+
+	if failed {
+		return results
+	}
+
+	err = os.RemoveAll(tmpDir)
+	if err != nil {
+		return failAll(len(sources), fmt.Errorf("failed to cleanup tmp dir %s: %v", tmpDir, err))
+	}
+	return results
+}
+
+// NativeRenderer draws the chord and interval templates with the built-in engraver, without lilypond.
+// Its MIDI plays every column as a quarter note, like the \midi block of the templates.
+type NativeRenderer struct{}
+
+func (r *NativeRenderer) Version() string {
+	return "native " + engraver.Version
+}
+
+func (r *NativeRenderer) Render(ctx context.Context, source string, opts RenderOptions) (RenderResult, error) {
+	score, err := engraver.ParseLilypond(source)
+	if err != nil {
+		return RenderResult{}, err
+	}
+
+	drawing := engraver.Engrave(score)
+	drawing.Transparent = opts.Transparent
+	pixelsPerSpace := engraver.PixelsPerSpace(opts.dpi())
+
+	result := RenderResult{Artifacts: map[Format][]byte{}}
+	for _, format := range opts.formats() {
+		switch format {
+		case FormatPNG:
+			data, err := drawing.PNG(pixelsPerSpace)
+			if err != nil {
+				return RenderResult{}, err
+			}
+			result.Artifacts[format] = data
+		case FormatSVG:
+			result.Artifacts[format] = drawing.SVG(pixelsPerSpace)
+		case FormatPDF:
+			result.Artifacts[format] = drawing.PDF()
+		case FormatEPS:
+			result.Artifacts[format] = drawing.EPS()
+		case FormatMIDI:
+			var sonorities []midi.Sonority
+			for _, column := range score.Columns {
+				columnNotes := append(append([]notes.Note{}, column.Lower...), column.Upper...)
+				sonorities = append(sonorities, midi.Sonority{Notes: columnNotes})
+			}
+
+			midiOpts := midi.DefaultOptions
+			midiOpts.Duration = 1
+			midiOpts.KeySignature = score.KeySignature
+			data, err := midi.NewFile(sonorities, midiOpts).Bytes()
+			if err != nil {
+				return RenderResult{}, fmt.Errorf("failed to encode midi: %v", err)
+			}
+			result.Artifacts[format] = data
+		}
+	}
+
+	return result, nil
+}
+
+// FakeRenderer returns small placeholders derived from the source, so the same source always gives the same
+// artifacts: a PNG filled with one color and a line of text for the other formats. Rendered sources are recorded in Sources.
+type FakeRenderer struct {
+	mutex   sync.Mutex
+	Sources []string
+}
+
+func (r *FakeRenderer) Version() string {
+	return "fake"
+}
+
+func (r *FakeRenderer) Render(ctx context.Context, source string, opts RenderOptions) (RenderResult, error) {
+	r.mutex.Lock()
+	r.Sources = append(r.Sources, source)
+	r.mutex.Unlock()
+
+	sum := md5.Sum([]byte(source))
+	result := RenderResult{Artifacts: map[Format][]byte{}}
+	for _, format := range opts.formats() {
+		if format != FormatPNG {
+			result.Artifacts[format] = []byte(fmt.Sprintf("fake %s %x\n", format, sum))
+			continue
+		}
+
+		img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 16; x++ {
+				img.Set(x, y, color.RGBA{R: sum[0], G: sum[1], B: sum[2], A: 0xFF})
+			}
+		}
+
+		buf := bytes.Buffer{}
+		if err := png.Encode(&buf, img); err != nil {
+			return RenderResult{}, fmt.Errorf("failed to encode placeholder png: %v", err)
+		}
+		result.Artifacts[format] = buf.Bytes()
+	}
+
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package lilypond
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Format is an artifact of a render, its value is the file extension.
+type Format string
+
+const (
+	FormatPNG  Format = "png"
+	FormatSVG  Format = "svg"
+	FormatPDF  Format = "pdf"
+	FormatEPS  Format = "eps"
+	FormatMIDI Format = "midi"
+)
+
+var allFormats = []Format{FormatPNG, FormatSVG, FormatPDF, FormatEPS, FormatMIDI}
+
+// ParseFormats parses a comma separated list of formats, e.g. "png,svg".
+func ParseFormats(list string) ([]Format, error) {
+	var formats []Format
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		found := false
+		for _, format := range allFormats {
+			if string(format) == name {
+				formats = append(formats, format)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid format: %s, expected one of: png, svg, pdf, eps, midi", name)
+		}
+	}
+
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no formats given")
+	}
+
+	return formats, nil
+}
+
+// RenderOptions selects the artifacts of a render. DPI applies to PNG images, Transparent drops the white background.
+type RenderOptions struct {
+	Formats     []Format
+	DPI         int
+	Transparent bool
+}
+
+var DefaultRenderOptions = RenderOptions{
+	Formats: []Format{FormatPNG},
+	DPI:     300,
+}
+
+func (o RenderOptions) dpi() int {
+	if o.DPI <= 0 {
+		return DefaultRenderOptions.DPI
+	}
+	return o.DPI
+}
+
+func (o RenderOptions) formats() []Format {
+	if len(o.Formats) == 0 {
+		return DefaultRenderOptions.Formats
+	}
+	return o.Formats
+}
+
+func (o RenderOptions) has(format Format) bool {
+	for _, f := range o.formats() {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderResult holds the rendered artifacts by format.
+type RenderResult struct {
+	Artifacts map[Format][]byte
+}
+
+func (r RenderResult) PNG() []byte {
+	return r.Artifacts[FormatPNG]
+}
+
+// WriteFiles writes every artifact to basePath with the extension of its format appended.
+func (r RenderResult) WriteFiles(basePath string) error {
+	for _, format := range allFormats {
+		data, ok := r.Artifacts[format]
+		if !ok {
+			continue
+		}
+
+		path := fmt.Sprintf("%s.%s", basePath, format)
+		if err := ioutil.WriteFile(path, data, os.FileMode(0660)); err != nil {
+			return fmt.Errorf("failed to write %s file: %v", format, err)
+		}
+	}
+
+	return nil
+}
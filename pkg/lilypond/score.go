@@ -0,0 +1,475 @@
+package lilypond
+
+import (
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Duration is a note value, Length 4 is a quarter note, 8 an eighth and so on.
+type Duration struct {
+	Length int
+	Dots   int
+}
+
+var Quarter = Duration{Length: 4}
+
+func (d Duration) lilypond() (string, error) {
+	switch d.Length {
+	case 1, 2, 4, 8, 16, 32, 64, 128:
+	default:
+		return "", fmt.Errorf("invalid duration length: %d", d.Length)
+	}
+	if d.Dots < 0 {
+		return "", fmt.Errorf("invalid number of dots: %d", d.Dots)
+	}
+
+	return fmt.Sprintf("%d%s", d.Length, strings.Repeat(".", d.Dots)), nil
+}
+
+type Clef string
+
+const (
+	ClefTreble Clef = "treble"
+	ClefBass   Clef = "bass"
+	ClefAlto   Clef = "alto"
+	ClefTenor  Clef = "tenor"
+)
+
+type Mode string
+
+const (
+	ModeMajor Mode = "major"
+	ModeMinor Mode = "minor"
+)
+
+// Key is a key signature, Tonic is a lilypond pitch name without octave, e.g. "fis".
+type Key struct {
+	Tonic string
+	Mode  Mode
+}
+
+var tonicRegexp = regexp.MustCompile(`^[a-g](is|es|s)?$`)
+
+// ScaleKey returns the key signature of the scale.
+func ScaleKey(scale notes.Scale) Key {
+	fields := strings.Fields(scale.LilypondSymbol)
+	key := Key{Tonic: scale.Note, Mode: ModeMajor}
+	if len(fields) == 2 {
+		key.Tonic = fields[0]
+		key.Mode = Mode(strings.TrimPrefix(fields[1], `\`))
+	}
+
+	return key
+}
+
+func (k Key) lilypond() (string, error) {
+	if !tonicRegexp.MatchString(k.Tonic) {
+		return "", fmt.Errorf("invalid key tonic: %q", k.Tonic)
+	}
+	if k.Mode != ModeMajor && k.Mode != ModeMinor {
+		return "", fmt.Errorf("invalid key mode: %q", k.Mode)
+	}
+
+	return fmt.Sprintf(`\key %s \%s`, k.Tonic, k.Mode), nil
+}
+
+// Event is an element of a voice: Note, Chord, Rest or Spacer, or one of Key, BarLine, LineBreak and PageBreak
+// which take no time.
+type Event interface {
+	lilypond() (string, error)
+}
+
+// Note is a single note, a styled note is written as a chord of one note as \tweak only applies to chord notes.
+type Note struct {
+	Pitch    notes.Note
+	Duration Duration
+	Style    NoteStyle
+}
+
+// Chord is a group of notes, Styles is optional and styles the pitch with the same index.
+type Chord struct {
+	Pitches  []notes.Note
+	Duration Duration
+	Styles   []NoteStyle
+}
+
+type Rest struct {
+	Duration Duration
+}
+
+// Spacer takes time without printing anything, e.g. on the empty staff of a piano score.
+type Spacer struct {
+	Duration Duration
+}
+
+type NoteHead string
+
+const (
+	NoteHeadDefault  NoteHead = ""
+	NoteHeadCross    NoteHead = "cross"
+	NoteHeadDiamond  NoteHead = "diamond"
+	NoteHeadTriangle NoteHead = "triangle"
+	NoteHeadSlash    NoteHead = "slash"
+	NoteHeadHarmonic NoteHead = "harmonic"
+)
+
+// NoteStyle highlights a single note. Color is a hex color like "#d62728", empty keeps it black.
+type NoteStyle struct {
+	Color         string
+	Head          NoteHead
+	Parenthesized bool
+}
+
+var colorRegexp = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func (s NoteStyle) Validate() error {
+	_, err := s.lilypond()
+	return err
+}
+
+// lilypond returns the tweaks written before the pitch in a chord
+func (s NoteStyle) lilypond() (string, error) {
+	var tweaks []string
+	if s.Color != "" {
+		if !colorRegexp.MatchString(s.Color) {
+			return "", fmt.Errorf("invalid color: %q, expected e.g. #d62728", s.Color)
+		}
+		var rgb []string
+		for i := 1; i < len(s.Color); i += 2 {
+			value, _ := strconv.ParseUint(s.Color[i:i+2], 16, 8)
+			rgb = append(rgb, strconv.FormatFloat(float64(value)/255, 'f', 3, 64))
+		}
+		tweaks = append(tweaks, fmt.Sprintf(`\tweak #'color #(rgb-color %s)`, strings.Join(rgb, " ")))
+	}
+
+	switch s.Head {
+	case NoteHeadDefault:
+	case NoteHeadCross, NoteHeadDiamond, NoteHeadTriangle, NoteHeadSlash, NoteHeadHarmonic:
+		tweaks = append(tweaks, fmt.Sprintf(`\tweak #'style #'%s`, s.Head))
+	default:
+		return "", fmt.Errorf("invalid note head: %q", s.Head)
+	}
+
+	if s.Parenthesized {
+		tweaks = append(tweaks, `\parenthesize`)
+	}
+
+	return strings.Join(tweaks, " "), nil
+}
+
+func (n Note) lilypond() (string, error) {
+	if n.Style != (NoteStyle{}) {
+		return Chord{Pitches: []notes.Note{n.Pitch}, Duration: n.Duration, Styles: []NoteStyle{n.Style}}.lilypond()
+	}
+
+	duration, err := n.Duration.lilypond()
+	if err != nil {
+		return "", err
+	}
+	return n.Pitch.LilypondSymbol() + duration, nil
+}
+
+func (c Chord) lilypond() (string, error) {
+	if len(c.Pitches) == 0 {
+		return "", fmt.Errorf("chord without pitches")
+	}
+	if len(c.Styles) > len(c.Pitches) {
+		return "", fmt.Errorf("chord with %d styles for %d pitches", len(c.Styles), len(c.Pitches))
+	}
+
+	duration, err := c.Duration.lilypond()
+	if err != nil {
+		return "", err
+	}
+
+	var pitches []string
+	for i, pitch := range c.Pitches {
+		pitchSymbol := pitch.LilypondSymbol()
+		if i < len(c.Styles) {
+			tweaks, err := c.Styles[i].lilypond()
+			if err != nil {
+				return "", err
+			}
+			if tweaks != "" {
+				pitchSymbol = tweaks + " " + pitchSymbol
+			}
+		}
+		pitches = append(pitches, pitchSymbol)
+	}
+	return fmt.Sprintf("<%s>%s", strings.Join(pitches, " "), duration), nil
+}
+
+func (r Rest) lilypond() (string, error) {
+	duration, err := r.Duration.lilypond()
+	if err != nil {
+		return "", err
+	}
+	return "r" + duration, nil
+}
+
+func (s Spacer) lilypond() (string, error) {
+	duration, err := s.Duration.lilypond()
+	if err != nil {
+		return "", err
+	}
+	return "s" + duration, nil
+}
+
+// BarLine is a bar line of the given style, e.g. "||", lines can only break at bar lines in a cadenza.
+type BarLine struct {
+	Style string
+}
+
+type LineBreak struct{}
+
+type PageBreak struct{}
+
+var barLineStyles = map[string]bool{"|": true, "||": true, "|.": true, ".|": true, ":|": true, "|:": true}
+
+func (b BarLine) lilypond() (string, error) {
+	if !barLineStyles[b.Style] {
+		return "", fmt.Errorf("invalid bar line: %q", b.Style)
+	}
+	return `\bar ` + quote(b.Style), nil
+}
+
+func (LineBreak) lilypond() (string, error) {
+	return `\break`, nil
+}
+
+func (PageBreak) lilypond() (string, error) {
+	return `\pageBreak`, nil
+}
+
+type Voice struct {
+	Events []Event
+}
+
+// Staff is serialized as a variable named after the staff. Key is optional, voices are played simultaneously.
+// Cadenza turns off the time signature, bar lines are placed only by BarLine events.
+type Staff struct {
+	Name              string
+	Clef              Clef
+	Key               *Key
+	HideTimeSignature bool
+	Cadenza           bool
+	Voices            []Voice
+}
+
+type AnnotationKind string
+
+const (
+	AnnotationLyrics     AnnotationKind = "lyrics"
+	AnnotationFigures    AnnotationKind = "figures"
+	AnnotationChordNames AnnotationKind = "chordnames"
+)
+
+// AnnotationItem labels one event, empty text leaves it unlabelled.
+type AnnotationItem struct {
+	Text     string
+	Duration Duration
+}
+
+// Annotation is a line printed with the staves, chord names above them, lyrics and figured bass below.
+// Lyrics are free text, figures are figured bass numbers like "6 4" and chord names use chordmode, e.g. "g:7".
+type Annotation struct {
+	Kind  AnnotationKind
+	Items []AnnotationItem
+}
+
+// Score is a group of staves, joined by a brace when Piano is set. Midi adds a \midi block.
+type Score struct {
+	Staves      []Staff
+	Annotations []Annotation
+	Piano       bool
+	Midi        bool
+}
+
+// Lilypond serializes the score to the staff variables and the \score block.
+func (s Score) Lilypond() (string, error) {
+	if len(s.Staves) == 0 {
+		return "", fmt.Errorf("score without staves")
+	}
+
+	buf := strings.Builder{}
+	variables := map[string]bool{}
+	var staffLines []string
+	for i, staff := range s.Staves {
+		variable := variableName(staff.Name, variables)
+		content, err := staff.lilypond()
+		if err != nil {
+			return "", fmt.Errorf("invalid staff %d: %v", i+1, err)
+		}
+
+		fmt.Fprintf(&buf, "%s = {\n%s}\n\n", variable, content)
+		staffLines = append(staffLines, fmt.Sprintf(`\new Staff = %s \%s`, quote(staff.Name), variable))
+	}
+
+	var above, below []string
+	for i, annotation := range s.Annotations {
+		line, err := annotation.lilypond()
+		if err != nil {
+			return "", fmt.Errorf("invalid annotation %d: %v", i+1, err)
+		}
+		if annotation.Kind == AnnotationChordNames {
+			above = append(above, line)
+		} else {
+			below = append(below, line)
+		}
+	}
+
+	indent := "  "
+	buf.WriteString("\\score {\n")
+	if len(above) > 0 || len(below) > 0 {
+		buf.WriteString("  <<\n")
+		indent = "    "
+		for _, line := range above {
+			fmt.Fprintf(&buf, "%s%s\n", indent, line)
+		}
+	}
+	if s.Piano {
+		fmt.Fprintf(&buf, "%s\\new PianoStaff\n", indent)
+	}
+	fmt.Fprintf(&buf, "%s<<\n", indent)
+	for _, line := range staffLines {
+		fmt.Fprintf(&buf, "%s  %s\n", indent, line)
+	}
+	fmt.Fprintf(&buf, "%s>>\n", indent)
+	if len(above) > 0 || len(below) > 0 {
+		for _, line := range below {
+			fmt.Fprintf(&buf, "%s%s\n", indent, line)
+		}
+		buf.WriteString("  >>\n")
+	}
+	buf.WriteString("  \\layout { }\n")
+	if s.Midi {
+		buf.WriteString("  \\midi { }\n")
+	}
+	buf.WriteString("}\n")
+
+	return buf.String(), nil
+}
+
+func (s Staff) lilypond() (string, error) {
+	buf := strings.Builder{}
+	switch s.Clef {
+	case "":
+	case ClefTreble, ClefBass, ClefAlto, ClefTenor:
+		fmt.Fprintf(&buf, "  \\clef %s\n", s.Clef)
+	default:
+		return "", fmt.Errorf("invalid clef: %q", s.Clef)
+	}
+	if s.HideTimeSignature {
+		buf.WriteString("  \\once \\override Staff.TimeSignature #'transparent = ##t\n")
+	}
+	if s.Key != nil {
+		key, err := s.Key.lilypond()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&buf, "  %s\n", key)
+	}
+	if s.Cadenza {
+		buf.WriteString("  \\cadenzaOn\n")
+	}
+	buf.WriteString("\n")
+
+	var voices []string
+	for _, voice := range s.Voices {
+		var events []string
+		for _, event := range voice.Events {
+			e, err := event.lilypond()
+			if err != nil {
+				return "", err
+			}
+			events = append(events, e)
+		}
+		voices = append(voices, strings.Join(events, " "))
+	}
+
+	switch len(voices) {
+	case 0:
+	case 1:
+		fmt.Fprintf(&buf, "  %s\n", voices[0])
+	default:
+		fmt.Fprintf(&buf, "  <<\n    { %s }\n  >>\n", strings.Join(voices, " }\n    \\\\\n    { "))
+	}
+
+	return buf.String(), nil
+}
+
+var (
+	figuresRegexp   = regexp.MustCompile(`^[0-9_+\-! ]+$`)
+	chordNameRegexp = regexp.MustCompile(`^([a-g](?:is|es|s)?)((?::[0-9a-z.+\-^]*)?(?:/[a-g](?:is|es|s)?)?)$`)
+)
+
+func (a Annotation) lilypond() (string, error) {
+	var mode string
+	switch a.Kind {
+	case AnnotationLyrics:
+		mode = `\new Lyrics \lyricmode`
+	case AnnotationFigures:
+		mode = `\new FiguredBass \figuremode`
+	case AnnotationChordNames:
+		mode = `\new ChordNames \chordmode`
+	default:
+		return "", fmt.Errorf("invalid annotation kind: %q", a.Kind)
+	}
+
+	var items []string
+	for _, item := range a.Items {
+		duration, err := item.Duration.lilypond()
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case item.Text == "":
+			items = append(items, `\skip `+duration)
+		case a.Kind == AnnotationLyrics:
+			items = append(items, quote(item.Text)+duration)
+		case a.Kind == AnnotationFigures:
+			if !figuresRegexp.MatchString(item.Text) {
+				return "", fmt.Errorf("invalid figures: %q", item.Text)
+			}
+			items = append(items, fmt.Sprintf("<%s>%s", strings.Join(strings.Fields(item.Text), " "), duration))
+		default:
+			match := chordNameRegexp.FindStringSubmatch(item.Text)
+			if match == nil {
+				return "", fmt.Errorf("invalid chord name: %q", item.Text)
+			}
+			items = append(items, match[1]+duration+match[2])
+		}
+	}
+
+	return fmt.Sprintf("%s { %s }", mode, strings.Join(items, " ")), nil
+}
+
+// variableName returns a lilypond identifier for the staff name, identifiers can only contain letters
+func variableName(name string, taken map[string]bool) string {
+	base := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return -1
+	}, name)
+	if base == "" {
+		base = "staff"
+	}
+
+	variable := base
+	for suffix := 'A'; taken[variable]; suffix++ {
+		variable = base + string(suffix)
+	}
+	taken[variable] = true
+
+	return variable
+}
+
+// quote returns s as a lilypond string
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
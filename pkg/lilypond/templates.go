@@ -0,0 +1,248 @@
+package lilypond
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// Layout holds the settings used by the default templates. StaffSize is in points, 0 keeps lilypond's 20pt,
+// PaperWidth is the line width of the paper block and Preamble is raw lilypond inserted before it.
+type Layout struct {
+	LilypondVersion string
+	StaffSize       float64
+	PaperWidth      string
+	Font            string
+	Preamble        string
+}
+
+var DefaultLayout = Layout{
+	LilypondVersion: "2.14.1",
+	PaperWidth:      `120\mm`,
+}
+
+var (
+	versionRegexp    = regexp.MustCompile(`^\d+\.\d+(\.\d+)?$`)
+	paperWidthRegexp = regexp.MustCompile(`^\d+(\.\d+)?\\(mm|cm|in|pt)$`)
+)
+
+func (l Layout) Validate() error {
+	if !versionRegexp.MatchString(l.LilypondVersion) {
+		return fmt.Errorf("invalid lilypond version: %q, expected e.g. 2.14.1", l.LilypondVersion)
+	}
+	if l.StaffSize < 0 {
+		return fmt.Errorf("invalid staff size: %v", l.StaffSize)
+	}
+	if !paperWidthRegexp.MatchString(l.PaperWidth) {
+		return fmt.Errorf(`invalid paper width: %q, expected e.g. 120\mm`, l.PaperWidth)
+	}
+	if strings.ContainsAny(l.Font, `"\`) {
+		return fmt.Errorf("invalid font: %q", l.Font)
+	}
+
+	return nil
+}
+
+// TemplateData is the model of the templates: the score and its serialized music, with the chords, the interval
+// or the worksheet the score was built from for templates laying them out on their own.
+type TemplateData struct {
+	Layout    Layout
+	Score     Score
+	Music     string
+	Chords    []SingleChord
+	Interval  *notes.Interval
+	Worksheet *Worksheet
+	AnswerKey bool
+}
+
+// defaultTemplates are the named blocks of a source, every block can be overridden by a template file
+// defining it. The music block is the serialized score and the document block puts them together.
+// Worksheets are full pages instead of cropped images, they use the worksheet block as their document.
+var defaultTemplates = `
+{{define "version"}}\version "{{.Layout.LilypondVersion}}"{{end}}
+
+{{define "preamble"}}\include "lilypond-book-preamble.ly"
+{{- if .Layout.StaffSize}}
+#(set-global-staff-size {{.Layout.StaffSize}})
+{{- end}}
+{{- if .Layout.Preamble}}
+{{.Layout.Preamble}}
+{{- end}}{{end}}
+
+{{define "paper"}}\paper{
+  indent=0\mm
+  line-width={{.Layout.PaperWidth}}
+  oddFooterMarkup=##f
+  oddHeaderMarkup=##f
+  bookTitleMarkup = ##f
+  scoreTitleMarkup = ##f
+{{- if .Layout.Font}}
+  #(define fonts (make-pango-font-tree "{{.Layout.Font}}" "sans-serif" "monospace" (/ staff-height pt 20)))
+{{- end}}
+}{{end}}
+
+{{define "music"}}{{.Music}}{{end}}
+
+{{define "document"}}{{template "version" .}}
+{{template "preamble" .}}
+
+{{template "paper" .}}
+
+{{template "music" .}}{{end}}
+
+{{define "worksheet"}}{{template "version" .}}
+#(set-default-paper-size {{quote .Worksheet.PaperSize}})
+{{- if .Layout.StaffSize}}
+#(set-global-staff-size {{.Layout.StaffSize}})
+{{- end}}
+{{- if .Layout.Preamble}}
+{{.Layout.Preamble}}
+{{- end}}
+
+\header {
+  title = {{quote .Worksheet.Title}}
+{{- if .AnswerKey}}
+  subtitle = "Answer key"
+{{- end}}
+  tagline = ##f
+}
+
+\paper {
+  indent = 0\mm
+  ragged-last = ##t
+{{- if .Layout.Font}}
+  #(define fonts (make-pango-font-tree "{{.Layout.Font}}" "sans-serif" "monospace" (/ staff-height pt 20)))
+{{- end}}
+}
+
+{{template "music" .}}{{end}}
+`
+
+// Templates generate the lilypond sources of chords and intervals.
+type Templates struct {
+	Layout   Layout
+	template *template.Template
+	document string
+}
+
+var DefaultTemplates = mustNewTemplates(DefaultLayout)
+
+func mustNewTemplates(layout Layout) *Templates {
+	templates, err := NewTemplates(layout)
+	if err != nil {
+		panic(err)
+	}
+	return templates
+}
+
+// NewTemplates parses the default templates and then the files in order. A file overrides the blocks it
+// defines, e.g. {{define "paper"}}...{{end}}, and replaces the whole document when it has content outside
+// of definitions. The templates are validated by rendering a sample chord and interval.
+func NewTemplates(layout Layout, files ...string) (*Templates, error) {
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+
+	tpl, err := template.New("default").Funcs(template.FuncMap{"quote": quote}).Parse(defaultTemplates)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing default templates: %v", err)
+	}
+
+	templates := &Templates{Layout: layout, template: tpl, document: "document"}
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template file %s: %v", file, err)
+		}
+
+		name := filepath.Base(file)
+		fileTemplate, err := tpl.New(name).Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing template file %s: %v", file, err)
+		}
+
+		if fileTemplate.Tree != nil && !parse.IsEmptyTree(fileTemplate.Tree.Root) {
+			templates.document = name
+		}
+	}
+
+	if err := templates.validate(); err != nil {
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+func (t *Templates) validate() error {
+	c, _ := notes.ParseNote("c'")
+	g, _ := notes.ParseNote("g'")
+	chord := SingleChord{Treble: []notes.Note{c, g}}
+	if _, err := t.ChordSource(MultipleChords{Key: ScaleKey(notes.CMajorScale), Chords: []SingleChord{chord}}); err != nil {
+		return fmt.Errorf("invalid templates: %v", err)
+	}
+
+	if _, err := t.IntervalSource(notes.Interval{FirstNote: c.OnNearestClef(), SecondNote: g.OnNearestClef(), Scale: notes.CMajorScale}); err != nil {
+		return fmt.Errorf("invalid templates: %v", err)
+	}
+
+	worksheet := DefaultWorksheet
+	worksheet.Exercises = []Exercise{{Key: ScaleKey(notes.CMajorScale), Chord: chord, Answer: "C major"}}
+	if _, err := t.WorksheetSource(worksheet, true); err != nil {
+		return fmt.Errorf("invalid templates: %v", err)
+	}
+
+	return nil
+}
+
+// render executes the document template, the chosen document block or the worksheet one
+func (t *Templates) render(document string, data TemplateData) (string, error) {
+	music, err := data.Score.Lilypond()
+	if err != nil {
+		return "", err
+	}
+
+	data.Layout = t.Layout
+	data.Music = music
+	buf := bytes.Buffer{}
+	if err := t.template.ExecuteTemplate(&buf, document, data); err != nil {
+		return "", fmt.Errorf("error rendering %s template: %v", document, err)
+	}
+
+	return buf.String(), nil
+}
+
+// ScoreSource returns the lilypond source of any score, e.g. with other clefs or several voices.
+func (t *Templates) ScoreSource(score Score) (string, error) {
+	return t.render(t.document, TemplateData{Score: score})
+}
+
+// ChordSource returns the lilypond source of the chords played one after another.
+func (t *Templates) ChordSource(chord MultipleChords) (string, error) {
+	return t.render(t.document, TemplateData{Score: chord.Score(), Chords: chord.Chords})
+}
+
+// IntervalSource returns the lilypond source of the interval, with the notes on the staves of their clefs.
+func (t *Templates) IntervalSource(interval notes.Interval) (string, error) {
+	score, err := IntervalScore(interval)
+	if err != nil {
+		return "", err
+	}
+
+	return t.render(t.document, TemplateData{Score: score, Interval: &interval})
+}
+
+// WorksheetSource returns the lilypond source of the worksheet pages, or of its answer key.
+func (t *Templates) WorksheetSource(worksheet Worksheet, answerKey bool) (string, error) {
+	score, err := worksheet.Score(answerKey)
+	if err != nil {
+		return "", err
+	}
+
+	return t.render("worksheet", TemplateData{Score: score, Worksheet: &worksheet, AnswerKey: answerKey})
+}
@@ -0,0 +1,101 @@
+package lilypond
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Exercise is one item of a worksheet, Answer is printed on its answer line in the answer key.
+type Exercise struct {
+	Key    Key
+	Chord  SingleChord
+	Answer string
+}
+
+// Worksheet lays out numbered exercises with ItemsPerRow of them on every line and ItemsPerPage on every page.
+// PaperSize is a lilypond paper size, e.g. "a4" or "letter".
+type Worksheet struct {
+	Title        string
+	PaperSize    string
+	ItemsPerRow  int
+	ItemsPerPage int
+	Exercises    []Exercise
+}
+
+var DefaultWorksheet = Worksheet{
+	Title:        "Worksheet",
+	PaperSize:    "a4",
+	ItemsPerRow:  4,
+	ItemsPerPage: 24,
+}
+
+const answerLine = "__________"
+
+var paperSizeRegexp = regexp.MustCompile(`^[a-z0-9]+$`)
+
+func (w Worksheet) Validate() error {
+	if !paperSizeRegexp.MatchString(w.PaperSize) {
+		return fmt.Errorf("invalid paper size: %q, expected e.g. a4 or letter", w.PaperSize)
+	}
+	if w.ItemsPerRow <= 0 {
+		return fmt.Errorf("invalid number of items per row: %d", w.ItemsPerRow)
+	}
+	if w.ItemsPerPage < w.ItemsPerRow {
+		return fmt.Errorf("invalid number of items per page: %d, expected at least %d items of a row", w.ItemsPerPage, w.ItemsPerRow)
+	}
+	if len(w.Exercises) == 0 {
+		return fmt.Errorf("worksheet without exercises")
+	}
+
+	return nil
+}
+
+// Score returns the exercises separated by bar lines, with a numbered answer line under each of them.
+// The answer key has the answers written on the lines.
+func (w Worksheet) Score(answerKey bool) (Score, error) {
+	if err := w.Validate(); err != nil {
+		return Score{}, err
+	}
+
+	var upper, lower []Event
+	answers := Annotation{Kind: AnnotationLyrics}
+	for i, exercise := range w.Exercises {
+		if i > 0 && exercise.Key != w.Exercises[i-1].Key {
+			upper = append(upper, exercise.Key)
+			lower = append(lower, exercise.Key)
+		}
+
+		barLine := BarLine{Style: "|"}
+		if i == len(w.Exercises)-1 {
+			barLine.Style = "|."
+		}
+		upper = append(upper, staffEvent(exercise.Chord.Treble, exercise.Chord.Styles), barLine)
+		lower = append(lower, staffEvent(exercise.Chord.Bass, exercise.Chord.Styles), barLine)
+
+		text := answerLine
+		if answerKey {
+			text = exercise.Answer
+		}
+		answers.Items = append(answers.Items, AnnotationItem{Text: fmt.Sprintf("%d. %s", i+1, text), Duration: Quarter})
+
+		if i == len(w.Exercises)-1 {
+			break
+		}
+		if (i+1)%w.ItemsPerPage == 0 {
+			upper = append(upper, PageBreak{})
+			lower = append(lower, PageBreak{})
+		} else if (i+1)%w.ItemsPerPage%w.ItemsPerRow == 0 {
+			upper = append(upper, LineBreak{})
+			lower = append(lower, LineBreak{})
+		}
+	}
+
+	score := pianoScore(w.Exercises[0].Key, upper, lower)
+	for i := range score.Staves {
+		score.Staves[i].Cadenza = true
+	}
+	score.Midi = false
+	score.Annotations = []Annotation{answers}
+
+	return score, nil
+}
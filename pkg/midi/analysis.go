@@ -0,0 +1,260 @@
+package midi
+
+import (
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"sort"
+	"strconv"
+)
+
+// drumChannel is General MIDI channel 10, its notes are percussion sounds without pitch
+const drumChannel = 9
+
+// NoteSpan is a note sounding from Start to End tick.
+type NoteSpan struct {
+	Start    int
+	End      int
+	Key      int
+	Velocity int
+	Channel  int
+	Track    int
+}
+
+// KeyChange is a key signature meta event, Sharps is negative for flats.
+type KeyChange struct {
+	Tick   int
+	Sharps int
+	Minor  bool
+}
+
+type timeSignatureChange struct {
+	tick        int
+	numerator   int
+	denominator int
+}
+
+// Position locates a sonority in the file. Measure and Beat are 1-based, beats are counted
+// in the denominator of the time signature.
+type Position struct {
+	Tick    int
+	Measure int
+	Beat    float64
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("m. %d, beat %s", p.Measure, strconv.FormatFloat(p.Beat, 'f', -1, 64))
+}
+
+// TimedSonority is a group of MIDI notes sounding together and the key in effect.
+type TimedSonority struct {
+	Position
+	Keys  []int
+	Scale notes.Scale
+}
+
+// Notes spells every key separately, see notes.SpellMIDI.
+func (s TimedSonority) Notes() []notes.Note {
+	var result []notes.Note
+	for _, key := range s.Keys {
+		result = append(result, notes.SpellMIDI(key, s.Scale))
+	}
+
+	return result
+}
+
+// Chord spells the keys as a chord in the key in effect, see notes.SpellChord.
+func (s TimedSonority) Chord() (notes.Chord, bool) {
+	return notes.SpellChord(s.Keys, s.Scale)
+}
+
+// NoteSpans pairs note on and note off events of all tracks. Notes without a note off last until the end of their track.
+func (f File) NoteSpans() []NoteSpan {
+	var spans []NoteSpan
+	for trackIdx, track := range f.Tracks {
+		type channelKey struct {
+			channel int
+			key     int
+		}
+		sounding := map[channelKey][]int{}
+		lastTick := 0
+
+		for _, e := range track.sortedEvents() {
+			lastTick = e.Tick
+			status := e.Data[0] & 0xF0
+			if e.Data[0] == statusMeta || e.Data[0] == statusSysEx || e.Data[0] == statusSysExEscape {
+				continue
+			}
+			if status != statusNoteOn && status != statusNoteOff {
+				continue
+			}
+
+			key := channelKey{channel: int(e.Data[0] & 0x0F), key: int(e.Data[1])}
+			if status == statusNoteOn && e.Data[2] > 0 {
+				spans = append(spans, NoteSpan{Start: e.Tick, End: -1, Key: key.key, Velocity: int(e.Data[2]), Channel: key.channel, Track: trackIdx})
+				sounding[key] = append(sounding[key], len(spans)-1)
+				continue
+			}
+
+			// overlapping notes on the same key are released in the order they started
+			if started := sounding[key]; len(started) > 0 {
+				spans[started[0]].End = e.Tick
+				sounding[key] = started[1:]
+			}
+		}
+
+		for _, started := range sounding {
+			for _, idx := range started {
+				spans[idx].End = lastTick
+			}
+		}
+	}
+
+	sort.SliceStable(spans, func(i int, j int) bool {
+		if spans[i].Start != spans[j].Start {
+			return spans[i].Start < spans[j].Start
+		}
+		return spans[i].Key < spans[j].Key
+	})
+
+	return spans
+}
+
+// KeySignatures returns key signature meta events of all tracks ordered by tick.
+func (f File) KeySignatures() []KeyChange {
+	var changes []KeyChange
+	for _, track := range f.Tracks {
+		for _, e := range track.Events {
+			if len(e.Data) == 5 && e.Data[0] == statusMeta && e.Data[1] == metaKeySignature {
+				changes = append(changes, KeyChange{Tick: e.Tick, Sharps: int(int8(e.Data[3])), Minor: e.Data[4] == 1})
+			}
+		}
+	}
+
+	sort.SliceStable(changes, func(i int, j int) bool {
+		return changes[i].Tick < changes[j].Tick
+	})
+
+	return changes
+}
+
+// timeSignatures returns the time signature changes ordered by tick, starting with the default 4/4. A zero
+// numerator or a denominator too large for a beat to last at least a tick is an error.
+func (f File) timeSignatures() ([]timeSignatureChange, error) {
+	changes := []timeSignatureChange{{tick: 0, numerator: 4, denominator: 4}}
+	for _, track := range f.Tracks {
+		for _, e := range track.Events {
+			if len(e.Data) >= 5 && e.Data[0] == statusMeta && e.Data[1] == metaTimeSignature {
+				if e.Data[3] == 0 || e.Data[4] >= 31 || f.division()*4>>e.Data[4] == 0 {
+					return nil, fmt.Errorf("invalid time signature at tick %d: %d/2^%d", e.Tick, e.Data[3], e.Data[4])
+				}
+				changes = append(changes, timeSignatureChange{tick: e.Tick, numerator: int(e.Data[3]), denominator: 1 << uint(e.Data[4])})
+			}
+		}
+	}
+
+	sort.SliceStable(changes, func(i int, j int) bool {
+		return changes[i].tick < changes[j].tick
+	})
+
+	return changes, nil
+}
+
+func (f File) division() int {
+	if f.Division == 0 {
+		return TicksPerQuarter
+	}
+
+	return f.Division
+}
+
+// position converts a tick to measure and beat, time signature changes are expected at bar lines.
+func (f File) position(tick int, timeSignatures []timeSignatureChange) Position {
+	measure := 1
+	for i, ts := range timeSignatures {
+		beatTicks := f.division() * 4 / ts.denominator
+		measureTicks := beatTicks * ts.numerator
+		if i+1 < len(timeSignatures) && timeSignatures[i+1].tick <= tick {
+			measure += (timeSignatures[i+1].tick - ts.tick + measureTicks - 1) / measureTicks
+			continue
+		}
+
+		offset := tick - ts.tick
+		return Position{
+			Tick:    tick,
+			Measure: measure + offset/measureTicks,
+			Beat:    1 + float64(offset%measureTicks)/float64(beatTicks),
+		}
+	}
+
+	return Position{Tick: tick, Measure: measure, Beat: 1}
+}
+
+// Sonorities groups notes into sonorities at every onset. Notes starting within tolerance ticks from the first
+// onset of a group are simultaneous, and notes held from earlier onsets are part of the sonority too.
+// Percussion on channel 10 is ignored.
+func (f File) Sonorities(tolerance int) ([]TimedSonority, error) {
+	var spans []NoteSpan
+	for _, span := range f.NoteSpans() {
+		if span.Channel != drumChannel && span.End > span.Start {
+			spans = append(spans, span)
+		}
+	}
+
+	keyChanges := f.KeySignatures()
+	timeSignatures, err := f.timeSignatures()
+	if err != nil {
+		return nil, err
+	}
+	scaleAt := func(tick int) (notes.Scale, error) {
+		scale := notes.CMajorScale
+		for _, change := range keyChanges {
+			if change.Tick > tick {
+				break
+			}
+			s, err := notes.ScaleForKeySignature(change.Sharps, change.Minor)
+			if err != nil {
+				return notes.Scale{}, fmt.Errorf("invalid key signature at tick %d: %v", change.Tick, err)
+			}
+			scale = s
+		}
+		return scale, nil
+	}
+
+	var sonorities []TimedSonority
+	var held []NoteSpan
+	for i := 0; i < len(spans); {
+		onset := spans[i].Start
+		j := i
+		for j < len(spans) && spans[j].Start-onset <= tolerance {
+			j++
+		}
+
+		var stillHeld []NoteSpan
+		for _, span := range held {
+			if span.End > onset {
+				stillHeld = append(stillHeld, span)
+			}
+		}
+		held = append(stillHeld, spans[i:j]...)
+
+		seen := map[int]bool{}
+		var keys []int
+		for _, span := range held {
+			if !seen[span.Key] {
+				seen[span.Key] = true
+				keys = append(keys, span.Key)
+			}
+		}
+		sort.Ints(keys)
+
+		scale, err := scaleAt(onset)
+		if err != nil {
+			return nil, err
+		}
+
+		sonorities = append(sonorities, TimedSonority{Position: f.position(onset, timeSignatures), Keys: keys, Scale: scale})
+		i = j
+	}
+
+	return sonorities, nil
+}
@@ -0,0 +1,180 @@
+package midi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	statusSysEx       = 0xF0
+	statusSysExEscape = 0xF7
+)
+
+// Decode reads a Standard MIDI File. Events keep their absolute ticks and running status is expanded,
+// so every event has the same layout as the ones written by Track.
+func Decode(r io.Reader) (File, error) {
+	br := bufio.NewReader(r)
+
+	var header struct {
+		ID       [4]byte
+		Length   uint32
+		Format   uint16
+		Tracks   uint16
+		Division uint16
+	}
+	if err := binary.Read(br, binary.BigEndian, &header); err != nil {
+		return File{}, fmt.Errorf("failed to read MIDI header: %v", err)
+	}
+	if string(header.ID[:]) != "MThd" {
+		return File{}, fmt.Errorf("not a MIDI file, unexpected chunk: %q", header.ID[:])
+	}
+	if header.Division&0x8000 != 0 {
+		return File{}, fmt.Errorf("SMPTE time division isn't supported")
+	}
+	if _, err := br.Discard(int(header.Length) - 6); err != nil {
+		return File{}, fmt.Errorf("failed to read MIDI header: %v", err)
+	}
+
+	file := File{Format: int(header.Format), Division: int(header.Division)}
+	for len(file.Tracks) < int(header.Tracks) {
+		var chunk struct {
+			ID     [4]byte
+			Length uint32
+		}
+		if err := binary.Read(br, binary.BigEndian, &chunk); err != nil {
+			return File{}, fmt.Errorf("failed to read MIDI track %d: %v", len(file.Tracks), err)
+		}
+
+		// the buffer grows with the data read, a bogus length doesn't allocate it up front
+		buf := bytes.Buffer{}
+		n, err := buf.ReadFrom(io.LimitReader(br, int64(chunk.Length)))
+		if err != nil {
+			return File{}, fmt.Errorf("failed to read MIDI track %d: %v", len(file.Tracks), err)
+		}
+		if n < int64(chunk.Length) {
+			return File{}, fmt.Errorf("failed to read MIDI track %d: chunk length exceeds file size", len(file.Tracks))
+		}
+		data := buf.Bytes()
+
+		// unknown chunks must be skipped
+		if string(chunk.ID[:]) != "MTrk" {
+			continue
+		}
+
+		track, err := decodeTrack(data)
+		if err != nil {
+			return File{}, fmt.Errorf("failed to decode MIDI track %d: %v", len(file.Tracks), err)
+		}
+		file.Tracks = append(file.Tracks, track)
+	}
+
+	return file, nil
+}
+
+func ReadFile(path string) (File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return File{}, fmt.Errorf("failed to open MIDI file %s: %v", path, err)
+	}
+	defer f.Close()
+
+	file, err := Decode(f)
+	if err != nil {
+		return File{}, fmt.Errorf("failed to read MIDI file %s: %v", path, err)
+	}
+
+	return file, nil
+}
+
+func decodeTrack(data []byte) (Track, error) {
+	track := Track{}
+	tick, pos := 0, 0
+	runningStatus := byte(0)
+	for pos < len(data) {
+		delta, n, err := decodeVarLen(data[pos:])
+		if err != nil {
+			return Track{}, err
+		}
+		pos += n
+		tick += delta
+
+		if pos >= len(data) {
+			return Track{}, fmt.Errorf("unexpected end of track at tick %d", tick)
+		}
+
+		status := data[pos]
+		switch {
+		case status == statusMeta:
+			if pos+2 > len(data) {
+				return Track{}, fmt.Errorf("truncated meta event at tick %d", tick)
+			}
+			length, n, err := decodeVarLen(data[pos+2:])
+			if err != nil {
+				return Track{}, err
+			}
+			end := pos + 2 + n + length
+			if end > len(data) {
+				return Track{}, fmt.Errorf("truncated meta event at tick %d", tick)
+			}
+			metaType := data[pos+1]
+			track.add(tick, data[pos:end]...)
+			pos = end
+			if metaType == metaEndOfTrack {
+				return track, nil
+			}
+		case status == statusSysEx || status == statusSysExEscape:
+			length, n, err := decodeVarLen(data[pos+1:])
+			if err != nil {
+				return Track{}, err
+			}
+			end := pos + 1 + n + length
+			if end > len(data) {
+				return Track{}, fmt.Errorf("truncated sysex event at tick %d", tick)
+			}
+			track.add(tick, data[pos:end]...)
+			pos = end
+		default:
+			if status&0x80 != 0 {
+				runningStatus = status
+				pos++
+			} else if runningStatus == 0 {
+				return Track{}, fmt.Errorf("data byte without status at tick %d", tick)
+			}
+
+			length := channelMessageLength(runningStatus)
+			if pos+length > len(data) {
+				return Track{}, fmt.Errorf("truncated channel event at tick %d", tick)
+			}
+			track.add(tick, append([]byte{runningStatus}, data[pos:pos+length]...)...)
+			pos += length
+		}
+	}
+
+	return track, nil
+}
+
+// channelMessageLength returns the number of data bytes following the status byte of a channel message.
+func channelMessageLength(status byte) int {
+	switch status & 0xF0 {
+	case statusProgramChange, 0xD0:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func decodeVarLen(data []byte) (int, int, error) {
+	value := 0
+	for i := 0; i < len(data) && i < 4; i++ {
+		value = value<<7 | int(data[i]&0x7F)
+		if data[i]&0x80 == 0 {
+			return value, i + 1, nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("invalid variable length quantity")
+}
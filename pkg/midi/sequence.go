@@ -0,0 +1,164 @@
+package midi
+
+import (
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+type PlaybackStyle int
+
+const (
+	PlaybackStyleBlock PlaybackStyle = iota
+	PlaybackStyleArpeggioUp
+	PlaybackStyleArpeggioDown
+)
+
+var playbackStyleNames = map[string]PlaybackStyle{
+	"block": PlaybackStyleBlock,
+	"up":    PlaybackStyleArpeggioUp,
+	"down":  PlaybackStyleArpeggioDown,
+}
+
+func ParsePlaybackStyle(name string) (PlaybackStyle, error) {
+	style, ok := playbackStyleNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("invalid playback style: %s, expected one of: block, up, down", name)
+	}
+
+	return style, nil
+}
+
+// Options configure how notes are played. Durations are in quarter notes.
+type Options struct {
+	Tempo        int
+	Velocity     int
+	Program      int
+	Channel      int
+	Style        PlaybackStyle
+	Duration     float64
+	ArpeggioStep float64
+	KeySignature int
+	MinorKey     bool
+	TrackName    string
+}
+
+var DefaultOptions = Options{
+	Tempo:        90,
+	Velocity:     90,
+	Program:      0,
+	Channel:      0,
+	Style:        PlaybackStyleBlock,
+	Duration:     4,
+	ArpeggioStep: 1,
+}
+
+// Sonority is a group of notes played together, or broken according to the playback style.
+type Sonority struct {
+	Notes []notes.Note
+}
+
+func IntervalSonority(interval notes.Interval) Sonority {
+	return Sonority{Notes: []notes.Note{interval.FirstNote, interval.SecondNote}}
+}
+
+func ChordSonority(chord notes.Chord) Sonority {
+	return Sonority{Notes: chord.Notes}
+}
+
+func (o Options) ForScale(scale notes.Scale) Options {
+	o.KeySignature = scale.KeySignature()
+	o.MinorKey = scale.Mode == notes.ScaleModeMinorHarmonic
+	return o
+}
+
+func (o Options) ticks(quarters float64) int {
+	return int(quarters * TicksPerQuarter)
+}
+
+// NewFile builds a single track MIDI file playing the sonorities one after another.
+func NewFile(sonorities []Sonority, opts Options) File {
+	track := Track{}
+	if opts.TrackName != "" {
+		track.TrackName(0, opts.TrackName)
+	}
+	track.Tempo(0, opts.Tempo)
+	track.TimeSignature(0, 4, 4)
+	track.KeySignature(0, opts.KeySignature, opts.MinorKey)
+	track.ProgramChange(0, opts.Channel, opts.Program)
+
+	tick := 0
+	for _, sonority := range sonorities {
+		keys := sortedKeys(sonority, opts.Style)
+		end := tick + opts.ticks(opts.Duration)
+		if opts.Style != PlaybackStyleBlock && len(keys) > 1 {
+			end += opts.ticks(opts.ArpeggioStep) * (len(keys) - 1)
+		}
+
+		for i, key := range keys {
+			start := tick
+			if opts.Style != PlaybackStyleBlock {
+				start += i * opts.ticks(opts.ArpeggioStep)
+			}
+			track.NoteOn(start, opts.Channel, key, opts.Velocity)
+			track.NoteOff(end, opts.Channel, key)
+		}
+
+		tick = end
+	}
+
+	return File{Format: 0, Division: TicksPerQuarter, Tracks: []Track{track}}
+}
+
+func sortedKeys(sonority Sonority, style PlaybackStyle) []int {
+	var keys []int
+	for _, n := range sonority.Notes {
+		keys = append(keys, n.MIDI())
+	}
+
+	if style == PlaybackStyleArpeggioDown {
+		sort.Sort(sort.Reverse(sort.IntSlice(keys)))
+	} else {
+		sort.Ints(keys)
+	}
+
+	return keys
+}
+
+func WriteInterval(w io.Writer, interval notes.Interval, opts Options) error {
+	return NewFile([]Sonority{IntervalSonority(interval)}, opts.ForScale(interval.Scale)).Encode(w)
+}
+
+func WriteChord(w io.Writer, chord notes.Chord, opts Options) error {
+	return NewFile([]Sonority{ChordSonority(chord)}, opts.ForScale(chord.Scale)).Encode(w)
+}
+
+func WriteProgression(w io.Writer, chords []notes.Chord, opts Options) error {
+	var sonorities []Sonority
+	for i := 0; i < len(chords); i++ {
+		sonorities = append(sonorities, ChordSonority(chords[i]))
+	}
+
+	if len(chords) > 0 {
+		opts = opts.ForScale(chords[0].Scale)
+	}
+
+	return NewFile(sonorities, opts).Encode(w)
+}
+
+func WriteFile(path string, sonorities []Sonority, opts Options) error {
+	data, err := NewFile(sonorities, opts).Bytes()
+	if err != nil {
+		return fmt.Errorf("failed to encode midi file %s: %v", path, err)
+	}
+
+	if err := ioutil.WriteFile(path, data, os.FileMode(0660)); err != nil {
+		return fmt.Errorf("failed to write midi file %s: %v", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package midi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const TicksPerQuarter = 480
+
+const (
+	statusNoteOff       = 0x80
+	statusNoteOn        = 0x90
+	statusProgramChange = 0xC0
+	statusMeta          = 0xFF
+
+	metaTrackName     = 0x03
+	metaEndOfTrack    = 0x2F
+	metaTempo         = 0x51
+	metaTimeSignature = 0x58
+	metaKeySignature  = 0x59
+)
+
+// Event is a single MIDI or meta event at an absolute tick. Data holds the status byte followed by its payload.
+type Event struct {
+	Tick int
+	Data []byte
+}
+
+type Track struct {
+	Events []Event
+}
+
+// File is a Standard MIDI File, Division is the number of ticks per quarter note.
+type File struct {
+	Format   int
+	Division int
+	Tracks   []Track
+}
+
+func (t *Track) add(tick int, data ...byte) {
+	t.Events = append(t.Events, Event{Tick: tick, Data: data})
+}
+
+func (t *Track) addMeta(tick int, metaType byte, payload []byte) {
+	data := []byte{statusMeta, metaType}
+	data = append(data, encodeVarLen(len(payload))...)
+	t.add(tick, append(data, payload...)...)
+}
+
+func (t *Track) NoteOn(tick int, channel int, key int, velocity int) {
+	t.add(tick, byte(statusNoteOn|channel&0x0F), byte(key&0x7F), byte(velocity&0x7F))
+}
+
+func (t *Track) NoteOff(tick int, channel int, key int) {
+	t.add(tick, byte(statusNoteOff|channel&0x0F), byte(key&0x7F), 0)
+}
+
+func (t *Track) ProgramChange(tick int, channel int, program int) {
+	t.add(tick, byte(statusProgramChange|channel&0x0F), byte(program&0x7F))
+}
+
+func (t *Track) TrackName(tick int, name string) {
+	t.addMeta(tick, metaTrackName, []byte(name))
+}
+
+func (t *Track) Tempo(tick int, bpm int) {
+	microsPerQuarter := 60000000 / bpm
+	t.addMeta(tick, metaTempo, []byte{byte(microsPerQuarter >> 16), byte(microsPerQuarter >> 8), byte(microsPerQuarter)})
+}
+
+func (t *Track) TimeSignature(tick int, numerator int, denominator int) {
+	denominatorPower := 0
+	for d := denominator; d > 1; d /= 2 {
+		denominatorPower++
+	}
+	t.addMeta(tick, metaTimeSignature, []byte{byte(numerator), byte(denominatorPower), 24, 8})
+}
+
+// KeySignature adds a key signature meta event, sharps is negative for flats.
+func (t *Track) KeySignature(tick int, sharps int, minor bool) {
+	mode := byte(0)
+	if minor {
+		mode = 1
+	}
+	t.addMeta(tick, metaKeySignature, []byte{byte(int8(sharps)), mode})
+}
+
+func (t *Track) sortedEvents() []Event {
+	events := make([]Event, len(t.Events))
+	copy(events, t.Events)
+	sort.SliceStable(events, func(i int, j int) bool {
+		if events[i].Tick != events[j].Tick {
+			return events[i].Tick < events[j].Tick
+		}
+		// note offs go first, so a repeated note isn't cut by its own previous note off
+		return eventOrder(events[i]) < eventOrder(events[j])
+	})
+
+	return events
+}
+
+func eventOrder(e Event) int {
+	switch {
+	case e.Data[0] == statusMeta:
+		return 0
+	case e.Data[0]&0xF0 == statusNoteOff, e.Data[0]&0xF0 == statusNoteOn && e.Data[2] == 0:
+		return 1
+	case e.Data[0]&0xF0 == statusNoteOn:
+		return 3
+	default:
+		return 2
+	}
+}
+
+func (f File) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	division := f.Division
+	if division == 0 {
+		division = TicksPerQuarter
+	}
+
+	header := []interface{}{[]byte("MThd"), uint32(6), uint16(f.Format), uint16(len(f.Tracks)), uint16(division)}
+	for _, v := range header {
+		if err := binary.Write(bw, binary.BigEndian, v); err != nil {
+			return fmt.Errorf("failed to write MIDI header: %v", err)
+		}
+	}
+
+	for i := 0; i < len(f.Tracks); i++ {
+		trackData := encodeTrack(f.Tracks[i])
+		if _, err := bw.WriteString("MTrk"); err != nil {
+			return fmt.Errorf("failed to write MIDI track %d: %v", i, err)
+		}
+		if err := binary.Write(bw, binary.BigEndian, uint32(len(trackData))); err != nil {
+			return fmt.Errorf("failed to write MIDI track %d: %v", i, err)
+		}
+		if _, err := bw.Write(trackData); err != nil {
+			return fmt.Errorf("failed to write MIDI track %d: %v", i, err)
+		}
+	}
+
+	return bw.Flush()
+}
+
+func (f File) Bytes() ([]byte, error) {
+	buf := bytes.Buffer{}
+	if err := f.Encode(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func encodeTrack(track Track) []byte {
+	buf := bytes.Buffer{}
+	lastTick := 0
+	hasEndOfTrack := false
+	for _, e := range track.sortedEvents() {
+		if e.Data[0] == statusMeta && e.Data[1] == metaEndOfTrack {
+			hasEndOfTrack = true
+		}
+		buf.Write(encodeVarLen(e.Tick - lastTick))
+		buf.Write(e.Data)
+		lastTick = e.Tick
+	}
+
+	if !hasEndOfTrack {
+		buf.Write([]byte{0, statusMeta, metaEndOfTrack, 0})
+	}
+
+	return buf.Bytes()
+}
+
+func encodeVarLen(value int) []byte {
+	result := []byte{byte(value & 0x7F)}
+	for value >>= 7; value > 0; value >>= 7 {
+		result = append([]byte{byte(value&0x7F | 0x80)}, result...)
+	}
+
+	return result
+}
@@ -0,0 +1,140 @@
+package midi
+
+import (
+	"bytes"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEncodeVarLen(t *testing.T) {
+	assert.Equal(t, []byte{0x00}, encodeVarLen(0))
+	assert.Equal(t, []byte{0x7F}, encodeVarLen(127))
+	assert.Equal(t, []byte{0x81, 0x00}, encodeVarLen(128))
+	assert.Equal(t, []byte{0xFF, 0x7F}, encodeVarLen(16383))
+	assert.Equal(t, []byte{0x81, 0x80, 0x00}, encodeVarLen(16384))
+}
+
+func TestWriteInterval(t *testing.T) {
+	c4, _ := notes.ParseNote("C4")
+	e4, _ := notes.ParseNote("E4")
+	buf := bytes.Buffer{}
+	assert.NoError(t, WriteInterval(&buf, notes.Interval{FirstNote: c4, SecondNote: e4, Scale: notes.DMajorScale}, DefaultOptions))
+
+	data := buf.Bytes()
+	assert.Equal(t, []byte("MThd"), data[0:4])
+	assert.Equal(t, []byte{0, 0, 0, 6, 0, 0, 0, 1, 0x01, 0xE0}, data[4:14])
+	assert.Equal(t, []byte("MTrk"), data[14:18])
+	assert.True(t, bytes.Contains(data, []byte{0xFF, 0x59, 0x02, 0x02, 0x00}), "key signature with 2 sharps")
+	assert.True(t, bytes.Contains(data, []byte{0x90, 60, 90, 0x00, 0x90, 64, 90}), "both notes start together")
+	assert.True(t, bytes.HasSuffix(data, []byte{0x00, 0xFF, 0x2F, 0x00}))
+}
+
+func TestArpeggio(t *testing.T) {
+	c4, _ := notes.ParseNote("C4")
+	e4, _ := notes.ParseNote("E4")
+	g4, _ := notes.ParseNote("G4")
+
+	opts := DefaultOptions
+	opts.Style = PlaybackStyleArpeggioDown
+	file := NewFile([]Sonority{{Notes: []notes.Note{e4, c4, g4}}}, opts)
+
+	var noteOns []Event
+	for _, e := range file.Tracks[0].sortedEvents() {
+		if e.Data[0] == statusNoteOn {
+			noteOns = append(noteOns, e)
+		}
+	}
+
+	assert.Len(t, noteOns, 3)
+	assert.Equal(t, byte(67), noteOns[0].Data[1])
+	assert.Equal(t, byte(60), noteOns[2].Data[1])
+	assert.Equal(t, 2*TicksPerQuarter, noteOns[2].Tick)
+}
+
+func TestDecodeAndSonorities(t *testing.T) {
+	track := Track{}
+	track.TimeSignature(0, 3, 4)
+	track.KeySignature(0, -1, true)
+	// d minor: i in the first measure, then V7 on beat 1 of the second measure and a melody note on beat 2
+	for _, key := range []int{50, 53, 57} {
+		track.NoteOn(0, 0, key, 80)
+		track.NoteOff(3*TicksPerQuarter, 0, key)
+	}
+	for _, key := range []int{45, 55, 61, 64} {
+		track.NoteOn(3*TicksPerQuarter, 0, key, 80)
+		track.NoteOff(6*TicksPerQuarter, 0, key)
+	}
+	track.NoteOn(4*TicksPerQuarter, 0, 69, 80)
+	track.NoteOff(5*TicksPerQuarter, 0, 69)
+	track.NoteOn(0, drumChannel, 36, 80)
+	track.NoteOff(1, drumChannel, 36)
+
+	data, err := File{Tracks: []Track{track}}.Bytes()
+	assert.NoError(t, err)
+
+	file, err := Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Len(t, file.Tracks, 1)
+	assert.Equal(t, TicksPerQuarter, file.Division)
+	assert.Equal(t, []KeyChange{{Tick: 0, Sharps: -1, Minor: true}}, file.KeySignatures())
+	assert.Len(t, file.NoteSpans(), 9)
+
+	sonorities, err := file.Sonorities(TicksPerQuarter / 8)
+	assert.NoError(t, err)
+	assert.Len(t, sonorities, 3)
+	assert.Equal(t, "d minor", sonorities[0].Scale.Name)
+
+	chord, ok := sonorities[0].Chord()
+	assert.True(t, ok)
+	assert.Equal(t, "i", chord.RomanNumeral())
+
+	assert.Equal(t, "m. 2, beat 1", sonorities[1].Position.String())
+	chord, ok = sonorities[1].Chord()
+	assert.True(t, ok)
+	assert.Equal(t, "V7", chord.RomanNumeral())
+	assert.Equal(t, "C♯4", sonorities[1].Notes()[2].ScientificName())
+
+	assert.Equal(t, "m. 2, beat 2", sonorities[2].Position.String())
+	assert.Equal(t, []int{45, 55, 61, 64, 69}, sonorities[2].Keys)
+}
+
+func TestDecodeRunningStatus(t *testing.T) {
+	data := []byte{'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 0, 0, 1, 0, 96,
+		'M', 'T', 'r', 'k', 0, 0, 0, 11,
+		0x00, 0x90, 60, 100,
+		0x60, 60, 0,
+		0x00, 0xFF, 0x2F, 0x00}
+	file, err := Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, []NoteSpan{{Start: 0, End: 96, Key: 60, Velocity: 100}}, file.NoteSpans())
+}
+
+func TestInvalidTimeSignature(t *testing.T) {
+	for _, signature := range [][]byte{{0, 2}, {4, 9}, {4, 200}} {
+		data := []byte{'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 0, 0, 1, 0, 96,
+			'M', 'T', 'r', 'k', 0, 0, 0, 20,
+			0x00, 0xFF, 0x58, 0x04, signature[0], signature[1], 24, 8,
+			0x00, 0x90, 60, 100,
+			0x60, 0x80, 60, 0,
+			0x00, 0xFF, 0x2F, 0x00}
+		file, err := Decode(bytes.NewReader(data))
+		assert.NoError(t, err)
+		_, err = file.Sonorities(0)
+		assert.Error(t, err, "%v", signature)
+	}
+}
+
+func TestDecodeBogusChunkLength(t *testing.T) {
+	data := []byte{'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 0, 0, 1, 0, 96,
+		'M', 'T', 'r', 'k', 0xFF, 0xFF, 0xFF, 0xF0,
+		0x00, 0xFF, 0x2F, 0x00}
+	_, err := Decode(bytes.NewReader(data))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "chunk length exceeds file size")
+	}
+
+	data[4], data[5], data[6], data[7] = 0xFF, 0xFF, 0xFF, 0xF0
+	_, err = Decode(bytes.NewReader(data))
+	assert.Error(t, err)
+}
@@ -0,0 +1,107 @@
+package musicxml
+
+import "encoding/xml"
+
+const (
+	Version = "4.0"
+	Header  = xml.Header + `<!DOCTYPE score-partwise PUBLIC "-//Recordare//DTD MusicXML 4.0 Partwise//EN" "http://www.musicxml.org/dtds/partwise.dtd">` + "\n"
+)
+
+type Score struct {
+	XMLName  xml.Name `xml:"score-partwise"`
+	Version  string   `xml:"version,attr,omitempty"`
+	Work     *Work    `xml:"work,omitempty"`
+	PartList PartList `xml:"part-list"`
+	Parts    []Part   `xml:"part"`
+}
+
+type Work struct {
+	Title string `xml:"work-title"`
+}
+
+type PartList struct {
+	ScoreParts []ScorePart `xml:"score-part"`
+}
+
+type ScorePart struct {
+	ID   string `xml:"id,attr"`
+	Name string `xml:"part-name"`
+}
+
+type Part struct {
+	ID       string    `xml:"id,attr"`
+	Measures []Measure `xml:"measure"`
+}
+
+// Measure keeps attributes, notes, backups and forwards in one list, because their order matters.
+type Measure struct {
+	Number   string           `xml:"number,attr"`
+	Elements []MeasureElement `xml:",any"`
+}
+
+// MeasureElement is an <attributes>, <note>, <backup> or <forward> element, only fields valid for XMLName are set.
+// Other elements are read with just the name and are ignored.
+type MeasureElement struct {
+	XMLName xml.Name
+
+	Divisions int    `xml:"divisions,omitempty"`
+	Keys      []Key  `xml:"key,omitempty"`
+	Times     []Time `xml:"time,omitempty"`
+	Staves    int    `xml:"staves,omitempty"`
+	Clefs     []Clef `xml:"clef,omitempty"`
+
+	Grace       *Empty     `xml:"grace,omitempty"`
+	Chord       *Empty     `xml:"chord,omitempty"`
+	Pitch       *Pitch     `xml:"pitch,omitempty"`
+	Rest        *Rest      `xml:"rest,omitempty"`
+	Duration    int        `xml:"duration,omitempty"`
+	Ties        []Tie      `xml:"tie,omitempty"`
+	Voice       string     `xml:"voice,omitempty"`
+	Type        string     `xml:"type,omitempty"`
+	Accidental  string     `xml:"accidental,omitempty"`
+	Staff       int        `xml:"staff,omitempty"`
+	Notations   *Notations `xml:"notations,omitempty"`
+	Lyrics      []Lyric    `xml:"lyric,omitempty"`
+	PrintObject string     `xml:"print-object,attr,omitempty"`
+}
+
+type Empty struct{}
+
+type Key struct {
+	Fifths int    `xml:"fifths"`
+	Mode   string `xml:"mode,omitempty"`
+}
+
+type Time struct {
+	PrintObject string `xml:"print-object,attr,omitempty"`
+	Beats       string `xml:"beats"`
+	BeatType    string `xml:"beat-type"`
+}
+
+type Clef struct {
+	Number int    `xml:"number,attr,omitempty"`
+	Sign   string `xml:"sign"`
+	Line   int    `xml:"line,omitempty"`
+}
+
+type Pitch struct {
+	Step   string  `xml:"step"`
+	Alter  float64 `xml:"alter,omitempty"`
+	Octave int     `xml:"octave"`
+}
+
+type Rest struct {
+	Measure string `xml:"measure,attr,omitempty"`
+}
+
+type Tie struct {
+	Type string `xml:"type,attr"`
+}
+
+type Notations struct {
+	Tied []Tie `xml:"tied,omitempty"`
+}
+
+type Lyric struct {
+	Text string `xml:"text"`
+}
@@ -0,0 +1,137 @@
+package musicxml
+
+import (
+	"bytes"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestWriteChord(t *testing.T) {
+	scale := notes.AMinorScale
+	scaleNotes := notes.ApplyScale(notes.AllNotes, scale)
+	var e, gis, b notes.Note
+	for _, n := range scaleNotes {
+		switch {
+		case n.BaseName == "e" && n.BaseNoteIndex == 16:
+			e = n
+			e.TrebleClef = false
+		case n.BaseName == "g" && n.BaseNoteIndex == 31:
+			gis = n
+			gis.BassClef = false
+		case n.BaseName == "b" && n.BaseNoteIndex == 35:
+			b = n
+		}
+	}
+
+	buf := bytes.Buffer{}
+	chord := notes.Chord{Scale: scale, Notes: []notes.Note{b, gis, e}, RootNote: e, Type: notes.ChordTypeMajorTriad}
+	assert.NoError(t, Write(&buf, ChordsScore([]notes.Chord{chord}, Options{Title: "E maj", PartName: "Piano", GrandStaff: true})))
+
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, `<?xml version="1.0" encoding="UTF-8"?>`))
+	assert.Contains(t, out, `<score-partwise version="4.0">`)
+	assert.Contains(t, out, `<work-title>E maj</work-title>`)
+	assert.Contains(t, out, "<fifths>0</fifths>")
+	assert.Contains(t, out, "<mode>minor</mode>")
+	assert.Contains(t, out, "<staves>2</staves>")
+	assert.Contains(t, out, `<clef number="2">`)
+	assert.Equal(t, 1, strings.Count(out, "<accidental>sharp</accidental>"))
+	assert.Equal(t, 1, strings.Count(out, "<chord></chord>"))
+	assert.Equal(t, 1, strings.Count(out, "<backup>"))
+
+	gisIdx := strings.Index(out, "<step>G</step>")
+	eIdx := strings.Index(out, "<step>E</step>")
+	backupIdx := strings.Index(out, "<backup>")
+	assert.True(t, gisIdx < backupIdx && backupIdx < eIdx, "treble notes go before the backup, bass notes after")
+}
+
+const testScore = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE score-partwise PUBLIC "-//Recordare//DTD MusicXML 4.0 Partwise//EN" "http://www.musicxml.org/dtds/partwise.dtd">
+<score-partwise version="4.0">
+  <part-list>
+    <score-part id="P1"><part-name>Soprano</part-name></score-part>
+    <score-part id="P2"><part-name>Bass</part-name></score-part>
+  </part-list>
+  <part id="P1">
+    <measure number="1">
+      <attributes><divisions>2</divisions><key><fifths>1</fifths></key><time><beats>3</beats><beat-type>4</beat-type></time></attributes>
+      <note><pitch><step>B</step><octave>4</octave></pitch><duration>2</duration><voice>1</voice></note>
+      <note><pitch><step>A</step><octave>4</octave></pitch><duration>2</duration><voice>1</voice><tie type="start"/></note>
+      <note><pitch><step>A</step><octave>4</octave></pitch><duration>2</duration><voice>1</voice><tie type="stop"/></note>
+    </measure>
+    <measure number="2">
+      <note><grace/><pitch><step>C</step><octave>5</octave></pitch><voice>1</voice></note>
+      <note><pitch><step>F</step><alter>1</alter><octave>4</octave></pitch><duration>6</duration><voice>1</voice></note>
+    </measure>
+  </part>
+  <part id="P2">
+    <measure number="1">
+      <attributes><divisions>1</divisions><key><fifths>1</fifths></key></attributes>
+      <note><pitch><step>G</step><octave>3</octave></pitch><duration>1</duration></note>
+      <note><chord/><pitch><step>D</step><octave>4</octave></pitch><duration>1</duration></note>
+      <note><rest/><duration>2</duration></note>
+    </measure>
+    <measure number="2">
+      <note><pitch><step>D</step><octave>3</octave></pitch><duration>3</duration></note>
+      <note><chord/><pitch><step>C</step><octave>4</octave></pitch><duration>3</duration></note>
+      <note><chord/><pitch><step>A</step><octave>3</octave></pitch><duration>3</duration></note>
+    </measure>
+  </part>
+</score-partwise>`
+
+func TestReadSonoritiesAndMelodicIntervals(t *testing.T) {
+	score, err := Read(strings.NewReader(testScore))
+	assert.NoError(t, err)
+
+	events, err := Events(score)
+	assert.NoError(t, err)
+	assert.Len(t, events, 8)
+
+	sonorities := Sonorities(events)
+	assert.Len(t, sonorities, 3)
+	assert.Equal(t, "m. 1, beat 1", sonorities[0].Position.String())
+	assert.Equal(t, "g major", sonorities[0].Scale.Name)
+
+	chord, ok := sonorities[0].Chord()
+	assert.True(t, ok)
+	assert.Equal(t, "G maj", chord.Name())
+
+	assert.Equal(t, "m. 1, beat 2", sonorities[1].Position.String())
+	assert.Len(t, sonorities[1].Notes, 1)
+
+	chord, ok = sonorities[2].Chord()
+	assert.True(t, ok)
+	assert.Equal(t, "m. 2, beat 1", sonorities[2].Position.String())
+	assert.Equal(t, notes.ChordTypeDominantSeventh, chord.Type)
+	assert.Equal(t, "d", chord.RootNote.BaseName)
+
+	intervals := MelodicIntervals(events)
+	assert.Len(t, intervals, 3)
+	assert.Equal(t, "Major second", intervals[0].Interval.Name())
+	assert.False(t, intervals[0].Ascending)
+	assert.Equal(t, "Minor third", intervals[1].Interval.Name())
+	assert.Equal(t, "m. 1, beat 2", intervals[1].Position.String())
+	assert.Equal(t, "Major second", intervals[2].Interval.Name())
+	assert.Equal(t, "P2", intervals[2].Part)
+}
+
+func TestWriteAndRead(t *testing.T) {
+	c, _ := notes.ParseNote("C4")
+	e, _ := notes.ParseNote("E4")
+	g, _ := notes.ParseNote("G3")
+
+	buf := bytes.Buffer{}
+	assert.NoError(t, Write(&buf, NewScore(notes.CMajorScale, [][]notes.Note{{c, e, g}, {g, e}}, DefaultOptions)))
+
+	score, err := Read(&buf)
+	assert.NoError(t, err)
+	events, err := Events(score)
+	assert.NoError(t, err)
+
+	sonorities := Sonorities(events)
+	assert.Len(t, sonorities, 2)
+	assert.Len(t, sonorities[0].Notes, 3)
+	assert.Equal(t, "2", sonorities[1].Measure)
+}
@@ -0,0 +1,287 @@
+package musicxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ticksPerQuarter is the common time base of all parts, onsets are compared in these ticks.
+const ticksPerQuarter = 960
+
+func Read(r io.Reader) (Score, error) {
+	score := Score{}
+	decoder := xml.NewDecoder(r)
+	// MusicXML files reference the DTD, which we don't need to validate against
+	decoder.Strict = false
+	if err := decoder.Decode(&score); err != nil {
+		return Score{}, fmt.Errorf("failed to decode musicxml: %v", err)
+	}
+
+	if score.XMLName.Local != "score-partwise" {
+		return Score{}, fmt.Errorf("unsupported musicxml root element: %s, only score-partwise is supported", score.XMLName.Local)
+	}
+
+	return score, nil
+}
+
+func ReadFile(path string) (Score, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Score{}, fmt.Errorf("failed to open musicxml file %s: %v", path, err)
+	}
+	defer f.Close()
+
+	score, err := Read(f)
+	if err != nil {
+		return Score{}, fmt.Errorf("failed to read musicxml file %s: %v", path, err)
+	}
+
+	return score, nil
+}
+
+// Position locates an item in the score. Beat is 1-based and counted in beats of the time signature.
+type Position struct {
+	Measure      string
+	MeasureIndex int
+	Beat         float64
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("m. %s, beat %s", p.Measure, strconv.FormatFloat(p.Beat, 'f', -1, 64))
+}
+
+// Event is a note attack in the score with the key in effect.
+type Event struct {
+	Position
+	Part  string
+	Voice string
+	Onset int
+	Note  notes.Note
+	Scale notes.Scale
+}
+
+// Sonority is a group of notes attacked at the same time in any part.
+type Sonority struct {
+	Position
+	Notes []notes.Note
+	Scale notes.Scale
+}
+
+func (s Sonority) Chord() (notes.Chord, bool) {
+	return notes.AnalyzeChord(s.Notes, s.Scale)
+}
+
+// MelodicInterval is an interval between consecutive notes of a voice. Interval.FirstNote is always the lower note.
+type MelodicInterval struct {
+	Position
+	Part      string
+	Voice     string
+	Interval  notes.Interval
+	Ascending bool
+}
+
+// Events returns note attacks of all parts ordered by onset. Rests, grace notes and tied continuations are skipped.
+func Events(score Score) ([]Event, error) {
+	var events []Event
+	for _, part := range score.Parts {
+		partEvents, err := partEvents(part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read part %s: %v", part.ID, err)
+		}
+		events = append(events, partEvents...)
+	}
+
+	sort.SliceStable(events, func(i int, j int) bool {
+		return events[i].Onset < events[j].Onset
+	})
+
+	return events, nil
+}
+
+func partEvents(part Part) ([]Event, error) {
+	var events []Event
+	divisions := 1
+	beats, beatType := 4, 4
+	scale := notes.CMajorScale
+	measureStart := 0
+
+	for measureIdx, measure := range part.Measures {
+		position, measureEnd, lastOnset := 0, 0, 0
+		toTicks := func(duration int) int {
+			return duration * ticksPerQuarter / divisions
+		}
+
+		for _, element := range measure.Elements {
+			switch element.XMLName.Local {
+			case "attributes":
+				if element.Divisions > 0 {
+					divisions = element.Divisions
+				}
+				if len(element.Keys) > 0 {
+					s, err := notes.ScaleForKeySignature(element.Keys[0].Fifths, element.Keys[0].Mode == "minor")
+					if err != nil {
+						return nil, err
+					}
+					scale = s
+				}
+				if len(element.Times) > 0 {
+					beats, _ = strconv.Atoi(strings.Split(element.Times[0].Beats, "+")[0])
+					beatType, _ = strconv.Atoi(element.Times[0].BeatType)
+					if beats <= 0 || beatType <= 0 {
+						beats, beatType = 4, 4
+					}
+				}
+			case "backup":
+				position -= toTicks(element.Duration)
+			case "forward":
+				position += toTicks(element.Duration)
+			case "note":
+				if element.Grace != nil {
+					continue
+				}
+
+				onset := position
+				if element.Chord != nil {
+					onset = lastOnset
+				} else {
+					position += toTicks(element.Duration)
+				}
+				lastOnset = onset
+
+				if element.Pitch == nil || isTieContinuation(element) {
+					break
+				}
+
+				n, err := pitchToNote(*element.Pitch)
+				if err != nil {
+					return nil, fmt.Errorf("measure %s: %v", measure.Number, err)
+				}
+
+				voice := element.Voice
+				if voice == "" {
+					voice = "1"
+				}
+
+				events = append(events, Event{
+					Position: Position{
+						Measure:      measure.Number,
+						MeasureIndex: measureIdx,
+						Beat:         1 + float64(onset)/ticksPerQuarter*float64(beatType)/4,
+					},
+					Part:  part.ID,
+					Voice: voice,
+					Onset: measureStart + onset,
+					Note:  n,
+					Scale: scale,
+				})
+			}
+
+			if position > measureEnd {
+				measureEnd = position
+			}
+		}
+
+		measureStart += measureEnd
+	}
+
+	return events, nil
+}
+
+// isTieContinuation is true for notes tied to the previous one, they aren't new attacks.
+func isTieContinuation(element MeasureElement) bool {
+	for _, tie := range element.Ties {
+		if tie.Type == "stop" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func pitchToNote(pitch Pitch) (notes.Note, error) {
+	step := strings.ToLower(pitch.Step)
+	if len(step) != 1 || !strings.Contains("cdefgab", step) {
+		return notes.Note{}, fmt.Errorf("invalid pitch step: %s", pitch.Step)
+	}
+
+	alter := math.Round(pitch.Alter)
+	if alter < -1 || alter > 1 {
+		return notes.Note{}, fmt.Errorf("unsupported alteration %v of %s%d", pitch.Alter, pitch.Step, pitch.Octave)
+	}
+
+	return notes.NewNote(step, notes.NoteModifier(alter), pitch.Octave), nil
+}
+
+// Sonorities groups note attacks at the same onset in all parts. Repeated pitches are kept once.
+func Sonorities(events []Event) []Sonority {
+	var sonorities []Sonority
+	for i := 0; i < len(events); {
+		sonority := Sonority{Position: events[i].Position, Scale: events[i].Scale}
+		seen := map[string]bool{}
+		j := i
+		for ; j < len(events) && events[j].Onset == events[i].Onset; j++ {
+			if key := events[j].Note.ScientificName(); !seen[key] {
+				seen[key] = true
+				sonority.Notes = append(sonority.Notes, events[j].Note)
+			}
+		}
+		sonorities = append(sonorities, sonority)
+		i = j
+	}
+
+	return sonorities
+}
+
+// MelodicIntervals returns intervals between consecutive attacks of every voice.
+// Only the highest note of chords played in a voice is taken into account.
+func MelodicIntervals(events []Event) []MelodicInterval {
+	type voiceKey struct {
+		part  string
+		voice string
+	}
+
+	var melodies []voiceKey
+	lines := map[voiceKey][]Event{}
+	for _, event := range events {
+		key := voiceKey{part: event.Part, voice: event.Voice}
+		line, ok := lines[key]
+		if !ok {
+			melodies = append(melodies, key)
+		}
+		if len(line) > 0 && line[len(line)-1].Onset == event.Onset {
+			if event.Note.ToneIndex() > line[len(line)-1].Note.ToneIndex() {
+				line[len(line)-1] = event
+			}
+			continue
+		}
+		lines[key] = append(line, event)
+	}
+
+	var intervals []MelodicInterval
+	for _, key := range melodies {
+		line := lines[key]
+		for i := 1; i < len(line); i++ {
+			first, second := line[i-1].Note, line[i].Note
+			ascending := second.ToneIndex() >= first.ToneIndex()
+			if !ascending {
+				first, second = second, first
+			}
+			intervals = append(intervals, MelodicInterval{
+				Position:  line[i-1].Position,
+				Part:      key.part,
+				Voice:     key.voice,
+				Interval:  notes.Interval{FirstNote: first, SecondNote: second, Scale: line[i-1].Scale},
+				Ascending: ascending,
+			})
+		}
+	}
+
+	return intervals
+}
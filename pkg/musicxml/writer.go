@@ -0,0 +1,212 @@
+package musicxml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// every sonority is written as a whole note in its own measure
+const (
+	divisions       = 1
+	measureDuration = 4 * divisions
+)
+
+type Options struct {
+	Title      string
+	PartName   string
+	GrandStaff bool
+}
+
+var DefaultOptions = Options{
+	PartName:   "Piano",
+	GrandStaff: true,
+}
+
+var modifierAccidentals = map[notes.NoteModifier]string{
+	notes.NoteModifierNone:  "natural",
+	notes.NoteModifierSharp: "sharp",
+	notes.NoteModifierFlat:  "flat",
+}
+
+// NewScore builds a single part score with every sonority in its own measure.
+func NewScore(scale notes.Scale, sonorities [][]notes.Note, opts Options) Score {
+	score := Score{
+		Version:  Version,
+		PartList: PartList{ScoreParts: []ScorePart{{ID: "P1", Name: opts.PartName}}},
+	}
+	if opts.Title != "" {
+		score.Work = &Work{Title: opts.Title}
+	}
+
+	keySignature := scale.KeySignature()
+	mode := "major"
+	if scale.Mode == notes.ScaleModeMinorHarmonic {
+		mode = "minor"
+	}
+
+	attributes := MeasureElement{
+		XMLName:   xml.Name{Local: "attributes"},
+		Divisions: divisions,
+		Keys:      []Key{{Fifths: keySignature, Mode: mode}},
+		Times:     []Time{{PrintObject: "no", Beats: "4", BeatType: "4"}},
+	}
+
+	singleStaffClef := Clef{Sign: "G", Line: 2}
+	if opts.GrandStaff {
+		attributes.Staves = 2
+		attributes.Clefs = []Clef{{Number: 1, Sign: "G", Line: 2}, {Number: 2, Sign: "F", Line: 4}}
+	} else {
+		if lowerHalf(sonorities) {
+			singleStaffClef = Clef{Sign: "F", Line: 4}
+		}
+		attributes.Clefs = []Clef{singleStaffClef}
+	}
+
+	part := Part{ID: "P1"}
+	for i, sonority := range sonorities {
+		measure := Measure{Number: strconv.Itoa(i + 1)}
+		if i == 0 {
+			measure.Elements = append(measure.Elements, attributes)
+		}
+
+		if opts.GrandStaff {
+			var treble, bass []notes.Note
+			for _, n := range sonority {
+				if staffOf(n) == 1 {
+					treble = append(treble, n)
+				} else {
+					bass = append(bass, n)
+				}
+			}
+			measure.Elements = append(measure.Elements, staffElements(treble, 1, keySignature)...)
+			measure.Elements = append(measure.Elements, MeasureElement{XMLName: xml.Name{Local: "backup"}, Duration: measureDuration})
+			measure.Elements = append(measure.Elements, staffElements(bass, 2, keySignature)...)
+		} else {
+			measure.Elements = append(measure.Elements, staffElements(sonority, 0, keySignature)...)
+		}
+
+		part.Measures = append(part.Measures, measure)
+	}
+	score.Parts = []Part{part}
+
+	return score
+}
+
+// staffOf returns 1 for the treble staff and 2 for the bass staff. Notes allowed on both clefs go to the closer one.
+func staffOf(n notes.Note) int {
+	if n.TrebleClef && !n.BassClef {
+		return 1
+	}
+	if n.BassClef && !n.TrebleClef {
+		return 2
+	}
+	if n.BaseNoteIndex >= notes.MiddleCIndex {
+		return 1
+	}
+	return 2
+}
+
+func lowerHalf(sonorities [][]notes.Note) bool {
+	sum, count := 0, 0
+	for _, sonority := range sonorities {
+		for _, n := range sonority {
+			sum += n.BaseNoteIndex
+			count++
+		}
+	}
+
+	return count > 0 && sum/count < notes.MiddleCIndex
+}
+
+// staffElements returns the notes of one staff, staff 0 means a single staff part.
+func staffElements(staffNotes []notes.Note, staff int, keySignature int) []MeasureElement {
+	voice := "1"
+	if staff > 1 {
+		voice = strconv.Itoa(staff)
+	}
+
+	if len(staffNotes) == 0 {
+		return []MeasureElement{{XMLName: xml.Name{Local: "forward"}, Duration: measureDuration, Voice: voice, Staff: staff}}
+	}
+
+	sorted := make([]notes.Note, len(staffNotes))
+	copy(sorted, staffNotes)
+	sort.SliceStable(sorted, func(i int, j int) bool {
+		return sorted[i].MIDI() < sorted[j].MIDI()
+	})
+
+	var elements []MeasureElement
+	for i, n := range sorted {
+		element := MeasureElement{
+			XMLName:  xml.Name{Local: "note"},
+			Pitch:    &Pitch{Step: strings.ToUpper(n.BaseName), Alter: float64(n.Modifier), Octave: n.Octave()},
+			Duration: measureDuration,
+			Voice:    voice,
+			Type:     "whole",
+			Staff:    staff,
+		}
+		if i > 0 {
+			element.Chord = &Empty{}
+		}
+		if n.Modifier != notes.KeySignatureModifier(keySignature, n.BaseName) {
+			element.Accidental = modifierAccidentals[n.Modifier]
+		}
+		elements = append(elements, element)
+	}
+
+	return elements
+}
+
+func IntervalScore(interval notes.Interval, opts Options) Score {
+	return NewScore(interval.Scale, [][]notes.Note{{interval.FirstNote, interval.SecondNote}}, opts)
+}
+
+func ChordsScore(chords []notes.Chord, opts Options) Score {
+	var sonorities [][]notes.Note
+	for i := 0; i < len(chords); i++ {
+		sonorities = append(sonorities, chords[i].Notes)
+	}
+
+	scale := notes.CMajorScale
+	if len(chords) > 0 {
+		scale = chords[0].Scale
+	}
+
+	return NewScore(scale, sonorities, opts)
+}
+
+func Write(w io.Writer, score Score) error {
+	if _, err := io.WriteString(w, Header); err != nil {
+		return fmt.Errorf("failed to write musicxml header: %v", err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(score); err != nil {
+		return fmt.Errorf("failed to encode musicxml: %v", err)
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func WriteFile(path string, score Score) error {
+	buf := bytes.Buffer{}
+	if err := Write(&buf, score); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), os.FileMode(0660)); err != nil {
+		return fmt.Errorf("failed to write musicxml file %s: %v", path, err)
+	}
+
+	return nil
+}
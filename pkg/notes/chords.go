@@ -2,7 +2,9 @@ package notes
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"unicode"
 )
 
 type Chord struct {
@@ -37,15 +39,57 @@ func (c Chord) Name() string {
 	return name
 }
 
+// RomanNumeral returns the numeral of the scale degree. Chords that don't belong to the scale, e.g. secondary
+// dominants or borrowed chords, get the numeral from their type, prefixed with ♭ or ♯ when the root is altered.
 func (c Chord) RomanNumeral() string {
-	if c.isTriad() {
-		return c.Scale.Degree(c.RootNote.BaseName).RomanNumeralTriad
+	degree := c.Scale.Degree(c.RootNote.BaseName)
+	diatonicRoot := c.RootNote.Modifier == c.Scale.ModifierOf(c.RootNote.BaseName)
+	if diatonicRoot && c.IsTriad() && c.Type == degree.TriadType {
+		return degree.RomanNumeralTriad
 	}
 
-	return c.Scale.Degree(c.RootNote.BaseName).RomanNumeralSeventh
+	if diatonicRoot && !c.IsTriad() && c.Type == degree.SeventhType {
+		return degree.RomanNumeralSeventh
+	}
+
+	return c.chromaticRomanNumeral()
+}
+
+var romanNumerals = []string{"I", "II", "III", "IV", "V", "VI", "VII"}
+
+func (c Chord) chromaticRomanNumeral() string {
+	numeral := romanNumerals[degreeOfNoteInScale(c.RootNote.BaseName, c.Scale)]
+
+	prefix := ""
+	if alteration := c.RootNote.Modifier - c.Scale.ModifierOf(c.RootNote.BaseName); alteration < 0 {
+		prefix = "♭"
+	} else if alteration > 0 {
+		prefix = "♯"
+	}
+
+	switch c.Type {
+	case ChordTypeMajorTriad:
+		return prefix + numeral
+	case ChordTypeMinorTriad:
+		return prefix + strings.ToLower(numeral)
+	case ChordTypeDiminishedTriad:
+		return prefix + strings.ToLower(numeral) + "°"
+	case ChordTypeAugmentedTriad:
+		return prefix + numeral + "+"
+	case ChordTypeMinorSeventh:
+		return prefix + strings.ToLower(numeral) + "7"
+	case ChordTypeMajorSeventh:
+		return prefix + numeral + "maj7"
+	case ChordTypeDominantSeventh:
+		return prefix + numeral + "7"
+	case ChordTypeDiminishedSeventh:
+		return prefix + strings.ToLower(numeral) + "°7"
+	default:
+		return prefix + strings.ToLower(numeral) + "⦰7"
+	}
 }
 
-func (c Chord) isTriad() bool {
+func (c Chord) IsTriad() bool {
 	switch c.Type {
 	case ChordTypeMajorTriad, ChordTypeMinorTriad, ChordTypeDiminishedTriad, ChordTypeAugmentedTriad:
 		return true
@@ -54,6 +98,44 @@ func (c Chord) isTriad() bool {
 	return false
 }
 
+// Inversion returns 0 for a chord in root position, 1 when the third is in the bass, 2 for the fifth and 3 for the seventh.
+func (c Chord) Inversion() int {
+	if len(c.Notes) == 0 {
+		return 0
+	}
+
+	bass := c.Notes[0]
+	for _, n := range c.Notes[1:] {
+		if n.MIDI() < bass.MIDI() {
+			bass = n
+		}
+	}
+
+	return letterSteps(c.RootNote, bass) / 2
+}
+
+var inversionNames = []string{"root position", "first inversion", "second inversion", "third inversion"}
+
+// InversionName returns the inversion in words, e.g. "first inversion".
+func (c Chord) InversionName() string {
+	inversion := c.Inversion()
+	if inversion < 0 || inversion >= len(inversionNames) {
+		return fmt.Sprintf("inversion %d", inversion)
+	}
+	return inversionNames[inversion]
+}
+
+// ChordTone returns 1 when the note is the root of the chord, 3 for the third, 5 for the fifth and 7 for the seventh.
+func (c Chord) ChordTone(n Note) int {
+	return letterSteps(c.RootNote, n) + 1
+}
+
+// letterSteps returns the number of letter names from the root up to the note, within an octave
+func letterSteps(root Note, n Note) int {
+	letters := "cdefgab"
+	return (strings.Index(letters, n.BaseName) - strings.Index(letters, root.BaseName) + 7) % 7
+}
+
 type ChordOnClefs struct {
 	TrebleClefNotes []Note
 	BassClefNotes   []Note
@@ -73,6 +155,21 @@ const (
 	ChordTypeHalfDiminishedSeventh
 )
 
+// Description returns the lower-cased words of the type, e.g. "half diminished seventh".
+func (t ChordType) Description() string {
+	name := strings.TrimPrefix(t.String(), "ChordType")
+	var words []string
+	start := 0
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) {
+			words = append(words, name[start:i])
+			start = i
+		}
+	}
+	words = append(words, name[start:])
+	return strings.ToLower(strings.Join(words, " "))
+}
+
 type ChordQuality int
 
 const (
@@ -288,3 +385,77 @@ func noteNamesOfAllSeventhsWithoutFifth() []string {
 		"bda",
 	}
 }
+
+var chordTemplates = []struct {
+	intervals []int
+	chordType ChordType
+}{
+	{[]int{0, 4, 7}, ChordTypeMajorTriad},
+	{[]int{0, 3, 7}, ChordTypeMinorTriad},
+	{[]int{0, 3, 6}, ChordTypeDiminishedTriad},
+	{[]int{0, 4, 8}, ChordTypeAugmentedTriad},
+	{[]int{0, 4, 7, 11}, ChordTypeMajorSeventh},
+	{[]int{0, 4, 7, 10}, ChordTypeDominantSeventh},
+	{[]int{0, 3, 7, 10}, ChordTypeMinorSeventh},
+	{[]int{0, 3, 6, 10}, ChordTypeHalfDiminishedSeventh},
+	{[]int{0, 3, 6, 9}, ChordTypeDiminishedSeventh},
+	{[]int{0, 4, 11}, ChordTypeMajorSeventh},
+	{[]int{0, 4, 10}, ChordTypeDominantSeventh},
+	{[]int{0, 3, 10}, ChordTypeMinorSeventh},
+}
+
+// AnalyzeChord finds the root and type of a triad or seventh chord (also without the fifth).
+// Doubled notes are allowed. When several roots fit, as in augmented or diminished seventh chords,
+// the one with notes spelled as a stack of thirds wins, then the lowest one.
+func AnalyzeChord(chordNotes []Note, scale Scale) (Chord, bool) {
+	sorted := make([]Note, len(chordNotes))
+	copy(sorted, chordNotes)
+	sort.SliceStable(sorted, func(i int, j int) bool {
+		return sorted[i].ToneIndex() > sorted[j].ToneIndex()
+	})
+
+	found := false
+	var result Chord
+	for i := len(sorted) - 1; i >= 0; i-- {
+		root := sorted[i]
+		intervalSet := map[int]bool{}
+		for _, n := range sorted {
+			intervalSet[((n.ToneIndex()-root.ToneIndex())%12+12)%12] = true
+		}
+
+		for _, template := range chordTemplates {
+			if len(template.intervals) != len(intervalSet) {
+				continue
+			}
+			matches := true
+			for _, interval := range template.intervals {
+				if !intervalSet[interval] {
+					matches = false
+				}
+			}
+			if !matches {
+				continue
+			}
+
+			chord := Chord{Scale: scale, Notes: sorted, RootNote: root, Type: template.chordType}
+			if !found || (!stackedInThirds(result) && stackedInThirds(chord)) {
+				result = chord
+				found = true
+			}
+		}
+	}
+
+	return result, found
+}
+
+func stackedInThirds(chord Chord) bool {
+	cScale := "cdefgab"
+	rootIdx := strings.Index(cScale, chord.RootNote.BaseName)
+	for _, n := range chord.Notes {
+		if letterDistance := (strings.Index(cScale, n.BaseName) - rootIdx + 7) % 7; letterDistance%2 == 1 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -10,3 +10,85 @@ func TestChordName(t *testing.T) {
 	assert.Equal(t, "D min", Chord{RootNote: AllNotes[1], Type: ChordTypeMinorTriad}.Name())
 	assert.Equal(t, "D maj7", Chord{RootNote: AllNotes[1], Type: ChordTypeDominantSeventh}.Name())
 }
+
+func parseNotes(t *testing.T, names ...string) []Note {
+	var result []Note
+	for _, name := range names {
+		n, err := ParseNote(name)
+		assert.NoError(t, err)
+		result = append(result, n)
+	}
+	return result
+}
+
+func TestAnalyzeChord(t *testing.T) {
+	chord, ok := AnalyzeChord(parseNotes(t, "E3", "C4", "G4", "C5"), CMajorScale)
+	assert.True(t, ok)
+	assert.Equal(t, "C maj", chord.Name())
+	assert.Equal(t, "C5", chord.Notes[0].ScientificName())
+
+	chord, ok = AnalyzeChord(parseNotes(t, "G2", "F4", "B3", "D4"), CMajorScale)
+	assert.True(t, ok)
+	assert.Equal(t, ChordTypeDominantSeventh, chord.Type)
+	assert.Equal(t, "g", chord.RootNote.BaseName)
+
+	chord, ok = AnalyzeChord(parseNotes(t, "Bb3", "D4", "F#4"), CMajorScale)
+	assert.True(t, ok)
+	assert.Equal(t, "B♭ aug", chord.Name())
+
+	_, ok = AnalyzeChord(parseNotes(t, "C4", "D4", "E4"), CMajorScale)
+	assert.False(t, ok)
+}
+
+func TestChordInversion(t *testing.T) {
+	root := parseNotes(t, "c")[0]
+	assert.Equal(t, 0, Chord{RootNote: root, Notes: parseNotes(t, "g'", "e'", "c'")}.Inversion())
+	assert.Equal(t, 1, Chord{RootNote: root, Notes: parseNotes(t, "c''", "g'", "e'")}.Inversion())
+	assert.Equal(t, 2, Chord{RootNote: root, Notes: parseNotes(t, "e''", "c''", "g'")}.Inversion())
+
+	g := parseNotes(t, "g")[0]
+	assert.Equal(t, 3, Chord{RootNote: g, Notes: parseNotes(t, "f", "g", "b", "d'")}.Inversion())
+	assert.Equal(t, "third inversion", Chord{RootNote: g, Notes: parseNotes(t, "f", "g", "b", "d'")}.InversionName())
+	assert.Equal(t, "root position", Chord{RootNote: root, Notes: parseNotes(t, "g'", "e'", "c'")}.InversionName())
+}
+
+func TestChordTone(t *testing.T) {
+	g := parseNotes(t, "g")[0]
+	chord := Chord{RootNote: g, Scale: CMajorScale, Notes: parseNotes(t, "f'", "d'", "b", "g")}
+	var tones []int
+	var leadingTones []bool
+	for _, n := range chord.Notes {
+		tones = append(tones, chord.ChordTone(n))
+		leadingTones = append(leadingTones, chord.Scale.IsLeadingTone(n))
+	}
+	assert.Equal(t, []int{7, 5, 3, 1}, tones)
+	assert.Equal(t, []bool{false, false, true, false}, leadingTones)
+}
+
+func TestChordTypeDescription(t *testing.T) {
+	assert.Equal(t, "major triad", ChordTypeMajorTriad.Description())
+	assert.Equal(t, "half diminished seventh", ChordTypeHalfDiminishedSeventh.Description())
+}
+
+func TestContentID(t *testing.T) {
+	c := parseNotes(t, "c'", "e'", "g'")
+	chord := Chord{Scale: CMajorScale, RootNote: c[0], Type: ChordTypeMajorTriad, Notes: c}
+	reordered := chord
+	reordered.Notes = []Note{c[2], c[0], c[1]}
+	assert.Equal(t, chord.ID(), reordered.ID())
+	assert.Len(t, chord.ID(), 32)
+
+	otherScale := chord
+	otherScale.Scale = ScaleMap["g major"]
+	assert.NotEqual(t, chord.ID(), otherScale.ID())
+
+	bassC := c[0]
+	bassC.TrebleClef, bassC.BassClef = false, true
+	onBass := chord
+	onBass.Notes = append([]Note{bassC}, c[1:]...)
+	assert.NotEqual(t, chord.ID(), onBass.ID())
+
+	interval := Interval{FirstNote: c[0], SecondNote: c[1], Scale: CMajorScale}
+	assert.Equal(t, interval.ID(), Interval{FirstNote: c[1], SecondNote: c[0], Scale: CMajorScale}.ID())
+	assert.NotEqual(t, interval.ID(), Chord{Scale: CMajorScale, Notes: c[:2]}.ID())
+}
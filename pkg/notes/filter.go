@@ -0,0 +1,127 @@
+package notes
+
+import (
+	"fmt"
+	"strings"
+)
+
+type IntervalClefLayout int
+
+const (
+	IntervalClefLayoutTreble IntervalClefLayout = iota
+	IntervalClefLayoutBass
+	IntervalClefLayoutCrossStaff
+)
+
+var intervalClefLayoutNames = map[string]IntervalClefLayout{
+	"treble": IntervalClefLayoutTreble,
+	"bass":   IntervalClefLayoutBass,
+	"cross":  IntervalClefLayoutCrossStaff,
+}
+
+func ParseIntervalClefLayout(name string) (IntervalClefLayout, error) {
+	layout, ok := intervalClefLayoutNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("invalid clef layout: %s, expected one of: treble, bass, cross", name)
+	}
+
+	return layout, nil
+}
+
+func (i Interval) ClefLayout() IntervalClefLayout {
+	if i.FirstNote.BassClef && i.SecondNote.BassClef {
+		return IntervalClefLayoutBass
+	}
+
+	if i.FirstNote.TrebleClef && i.SecondNote.TrebleClef {
+		return IntervalClefLayoutTreble
+	}
+
+	return IntervalClefLayoutCrossStaff
+}
+
+// Quality returns the lower-cased first word of the interval name, e.g. "minor" for "Minor third".
+func (i Interval) Quality() string {
+	return strings.ToLower(strings.SplitN(i.Name(), " ", 2)[0])
+}
+
+// Size returns the lower-cased size part of the interval name, e.g. "third" for "Minor third".
+func (i Interval) Size() string {
+	parts := strings.SplitN(i.Name(), " ", 2)
+	return strings.ToLower(parts[len(parts)-1])
+}
+
+// IntervalFilter selects intervals for a deck. Empty fields don't filter anything, MaxDistance and Limit are ignored when 0.
+type IntervalFilter struct {
+	Qualities   []string
+	Sizes       []string
+	MinDistance int
+	MaxDistance int
+	Layouts     []IntervalClefLayout
+	Limit       int
+}
+
+func (f IntervalFilter) Matches(interval Interval) bool {
+	if len(f.Qualities) > 0 && !containsFolded(f.Qualities, interval.Quality()) {
+		return false
+	}
+
+	if len(f.Sizes) > 0 && !containsFolded(f.Sizes, interval.Size()) {
+		return false
+	}
+
+	if interval.Distance() < f.MinDistance {
+		return false
+	}
+
+	if f.MaxDistance > 0 && interval.Distance() > f.MaxDistance {
+		return false
+	}
+
+	if len(f.Layouts) > 0 {
+		found := false
+		for _, layout := range f.Layouts {
+			if layout == interval.ClefLayout() {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Apply returns matching intervals. When Limit is set, the result is sampled evenly
+// from all matches so that every scale and size is still represented.
+func (f IntervalFilter) Apply(intervals []Interval) []Interval {
+	var filtered []Interval
+	for i := 0; i < len(intervals); i++ {
+		if f.Matches(intervals[i]) {
+			filtered = append(filtered, intervals[i])
+		}
+	}
+
+	if f.Limit <= 0 || len(filtered) <= f.Limit {
+		return filtered
+	}
+
+	limited := make([]Interval, 0, f.Limit)
+	for i := 0; i < f.Limit; i++ {
+		limited = append(limited, filtered[i*len(filtered)/f.Limit])
+	}
+
+	return limited
+}
+
+func containsFolded(list []string, value string) bool {
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if strings.EqualFold(s, value) || strings.EqualFold(strings.TrimSuffix(s, "s"), value) {
+			return true
+		}
+	}
+
+	return false
+}
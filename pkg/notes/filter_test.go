@@ -0,0 +1,42 @@
+package notes
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIntervalQualityAndSize(t *testing.T) {
+	c := note(0, "c", false, true)
+	e := note(4, "e", false, true)
+	f := note(5, "f", false, true)
+	b := note(11, "b", false, true)
+
+	assert.Equal(t, "major", Interval{FirstNote: c, SecondNote: e}.Quality())
+	assert.Equal(t, "third", Interval{FirstNote: c, SecondNote: e}.Size())
+	assert.Equal(t, "augmented", Interval{FirstNote: f, SecondNote: b}.Quality())
+	assert.Equal(t, "fourth", Interval{FirstNote: f, SecondNote: b}.Size())
+}
+
+func TestIntervalFilter(t *testing.T) {
+	intervals := GenerateIntervals(ApplyScale(AllNotes, CMajorScale), 0, len(AllNotes), 12)
+
+	thirds := IntervalFilter{Sizes: []string{"thirds"}, Layouts: []IntervalClefLayout{IntervalClefLayoutTreble}}.Apply(intervals)
+	assert.NotEmpty(t, thirds)
+	for _, interval := range thirds {
+		assert.Equal(t, "third", interval.Size())
+		assert.Equal(t, IntervalClefLayoutTreble, interval.ClefLayout())
+	}
+
+	ranged := IntervalFilter{MinDistance: 3, MaxDistance: 4}.Apply(intervals)
+	assert.NotEmpty(t, ranged)
+	for _, interval := range ranged {
+		assert.True(t, interval.Distance() >= 3 && interval.Distance() <= 4)
+	}
+
+	limited := IntervalFilter{Limit: 10}.Apply(intervals)
+	assert.Len(t, limited, 10)
+	assert.Equal(t, intervals[0], limited[0])
+
+	_, err := ParseIntervalClefLayout("alto")
+	assert.Error(t, err)
+}
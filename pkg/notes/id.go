@@ -0,0 +1,44 @@
+package notes
+
+import (
+	"crypto/md5"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// contentID hashes the kind, the scale and the spelled notes with their staves, lowest first. Unlike file names
+// and card texts it only changes with the music, so regenerated cards keep their ids.
+func contentID(kind string, scale Scale, notes []Note) string {
+	sorted := append([]Note(nil), notes...)
+	sort.SliceStable(sorted, func(i int, j int) bool {
+		if sorted[i].ToneIndex() != sorted[j].ToneIndex() {
+			return sorted[i].ToneIndex() < sorted[j].ToneIndex()
+		}
+		return sorted[i].BaseNoteIndex < sorted[j].BaseNoteIndex
+	})
+
+	parts := []string{kind, scale.Name}
+	for _, n := range sorted {
+		staff := ""
+		if n.TrebleClef {
+			staff += "t"
+		}
+		if n.BassClef {
+			staff += "b"
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d:%d:%s", n.BaseName, n.BaseNoteIndex, n.Modifier, staff))
+	}
+
+	return fmt.Sprintf("%x", md5.Sum([]byte(strings.Join(parts, ";"))))
+}
+
+// ID identifies the chord by its scale and notes, independent of how its cards present it.
+func (c Chord) ID() string {
+	return contentID("chord", c.Scale, c.Notes)
+}
+
+// ID identifies the interval by its scale and notes, independent of how its cards present it.
+func (i Interval) ID() string {
+	return contentID("interval", i.Scale, []Note{i.FirstNote, i.SecondNote})
+}
@@ -106,13 +106,19 @@ func (n Note) Symbol() string {
 }
 
 func octaveModifier(note Note) string {
-	modifiers := []string{",", "", "'", "''", "'''"}
-	return modifiers[note.BaseNoteIndex/12]
+	return octaveMarks(note, ",", "'")
 }
 
 func octaveModifierForFileName(note Note) string {
-	modifiers := []string{"l", "", "u", "uu", "uuu"}
-	return modifiers[note.BaseNoteIndex/12]
+	return octaveMarks(note, "l", "u")
+}
+
+func octaveMarks(note Note, down string, up string) string {
+	if octave := floorDiv(note.BaseNoteIndex, 12) - 1; octave < 0 {
+		return strings.Repeat(down, -octave)
+	} else {
+		return strings.Repeat(up, octave)
+	}
 }
 
 type Interval struct {
@@ -138,6 +144,21 @@ var simpleIntervals = map[int]string{
 }
 
 func (i Interval) Name() string {
+	name, ok := i.name()
+	if !ok {
+		panic(fmt.Errorf("interval not supported: %d, %+v", i.Distance(), i))
+	}
+
+	return name
+}
+
+// Supported returns false for intervals Name can't describe, e.g. compound intervals.
+func (i Interval) Supported() bool {
+	_, ok := i.name()
+	return ok
+}
+
+func (i Interval) name() (string, bool) {
 	switch diff := i.Distance(); {
 
 	case diff == 6:
@@ -146,16 +167,16 @@ func (i Interval) Name() string {
 		secondIndex := strings.Index(cScale[firstIndex+1:], i.SecondNote.BaseName) + firstIndex + 1
 
 		if secondIndex-firstIndex == 3 {
-			return "Augmented fourth"
+			return "Augmented fourth", true
 		} else if secondIndex-firstIndex == 4 {
-			return "Diminished fifth"
+			return "Diminished fifth", true
 		} else {
-			panic(fmt.Errorf("invalid scale diff: %d, %d, %+v", firstIndex, secondIndex, i))
+			return "", false
 		}
 	case diff >= 0 && diff <= 12:
-		return simpleIntervals[diff]
+		return simpleIntervals[diff], true
 	default:
-		panic(fmt.Errorf("interval not supported: %d", diff))
+		return "", false
 	}
 }
 
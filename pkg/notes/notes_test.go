@@ -15,3 +15,22 @@ func TestNameWithSharpFlatModifier(t *testing.T) {
 	n.Modifier = NoteModifierSharp
 	assert.Equal(t, "C♯", n.NameWithSharpFlatModifier())
 }
+
+func TestIntervalSupported(t *testing.T) {
+	c, _ := ParseNote("C4")
+	fis, _ := ParseNote("F#4")
+	ges, _ := ParseNote("Gb4")
+	d, _ := ParseNote("D4")
+	d5, _ := ParseNote("D5")
+
+	for _, interval := range []Interval{{FirstNote: c, SecondNote: fis}, {FirstNote: c, SecondNote: ges}, {FirstNote: d, SecondNote: c}} {
+		assert.True(t, interval.Supported())
+		assert.NotPanics(t, func() { interval.Name() })
+	}
+	assert.Equal(t, "Augmented fourth", Interval{FirstNote: c, SecondNote: fis}.Name())
+	assert.Equal(t, "Diminished fifth", Interval{FirstNote: c, SecondNote: ges}.Name())
+
+	compound := Interval{FirstNote: c, SecondNote: d5}
+	assert.False(t, compound.Supported())
+	assert.Panics(t, func() { compound.Name() })
+}
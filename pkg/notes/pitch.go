@@ -0,0 +1,213 @@
+package notes
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// BaseNoteIndex 0 is engraved as LilyPond "c," (C2 in scientific pitch notation), so middle C (c') is 24.
+const (
+	midiNumberOfBaseNoteIndexZero = 36
+	octaveOfBaseNoteIndexZero     = 2
+	MiddleCIndex                  = 24
+)
+
+// lowestTrebleClefIndex and highestBassClefIndex are the clef ranges used by AllNotes.
+const (
+	lowestTrebleClefIndex = 16
+	highestBassClefIndex  = 33
+)
+
+var letterOffsets = map[string]int{"c": 0, "d": 2, "e": 4, "f": 5, "g": 7, "a": 9, "b": 11}
+
+type TuningSystem int
+
+const (
+	TuningSystemEqual TuningSystem = iota
+	TuningSystemJust
+	TuningSystemPythagorean
+)
+
+var justRatios = []float64{1, 16.0 / 15, 9.0 / 8, 6.0 / 5, 5.0 / 4, 4.0 / 3, 45.0 / 32, 3.0 / 2, 8.0 / 5, 5.0 / 3, 9.0 / 5, 15.0 / 8}
+var pythagoreanRatios = []float64{1, 256.0 / 243, 9.0 / 8, 32.0 / 27, 81.0 / 64, 4.0 / 3, 729.0 / 512, 3.0 / 2, 128.0 / 81, 27.0 / 16, 16.0 / 9, 243.0 / 128}
+
+// Tuning describes how notes are converted to frequencies. Tonic is the pitch class (0 = C)
+// the just and pythagorean ratios are calculated from; it's ignored in equal temperament.
+type Tuning struct {
+	A4     float64
+	System TuningSystem
+	Tonic  int
+}
+
+var DefaultTuning = Tuning{A4: 440, System: TuningSystemEqual}
+
+func floorDiv(a int, b int) int {
+	if a < 0 && a%b != 0 {
+		return a/b - 1
+	}
+	return a / b
+}
+
+func floorMod(a int, b int) int {
+	return a - floorDiv(a, b)*b
+}
+
+// MIDI returns the MIDI note number, e.g. 60 for middle C.
+func (n Note) MIDI() int {
+	return n.ToneIndex() + midiNumberOfBaseNoteIndexZero
+}
+
+// Octave returns the octave number in scientific pitch notation. It follows the letter name, so B♯3 is in octave 3.
+func (n Note) Octave() int {
+	return floorDiv(n.BaseNoteIndex, 12) + octaveOfBaseNoteIndexZero
+}
+
+func (n Note) Frequency(tuning Tuning) float64 {
+	a4 := tuning.A4
+	if a4 == 0 {
+		a4 = DefaultTuning.A4
+	}
+
+	equalTempered := func(midi int) float64 {
+		return a4 * math.Pow(2, float64(midi-69)/12)
+	}
+
+	var ratios []float64
+	switch tuning.System {
+	case TuningSystemJust:
+		ratios = justRatios
+	case TuningSystemPythagorean:
+		ratios = pythagoreanRatios
+	default:
+		return equalTempered(n.MIDI())
+	}
+
+	tonic := floorMod(tuning.Tonic, 12)
+	steps := n.MIDI() - tonic
+	tonicMIDI := floorDiv(steps, 12)*12 + tonic
+	return equalTempered(tonicMIDI) * ratios[floorMod(steps, 12)]
+}
+
+func accidentalSymbol(modifier NoteModifier) string {
+	switch modifier {
+	case NoteModifierSharp:
+		return "♯"
+	case NoteModifierFlat:
+		return "♭"
+	default:
+		return ""
+	}
+}
+
+// ScientificName returns the name in scientific pitch notation, e.g. "C♯4".
+func (n Note) ScientificName() string {
+	return fmt.Sprintf("%s%d", n.NameWithSharpFlatModifier(), n.Octave())
+}
+
+// HelmholtzName returns the name in Helmholtz pitch notation, e.g. "c♯'" for C♯4 or "G," for G1.
+func (n Note) HelmholtzName() string {
+	octave := n.Octave()
+	if octave >= 3 {
+		return n.BaseName + accidentalSymbol(n.Modifier) + strings.Repeat("'", octave-3)
+	}
+
+	return strings.ToUpper(n.BaseName) + accidentalSymbol(n.Modifier) + strings.Repeat(",", 2-octave)
+}
+
+func noteWithClefs(baseNoteIndex int, name string, modifier NoteModifier) Note {
+	n := note(baseNoteIndex, name, baseNoteIndex >= lowestTrebleClefIndex, baseNoteIndex <= highestBassClefIndex)
+	n.Modifier = modifier
+	return n
+}
+
+// NoteFromMIDI spells a MIDI note number, black keys are spelled with flats when preferFlats is set.
+func NoteFromMIDI(midi int, preferFlats bool) Note {
+	sharpNames := []string{"c", "c", "d", "d", "e", "f", "f", "g", "g", "a", "a", "b"}
+	flatNames := []string{"c", "d", "d", "e", "e", "f", "g", "g", "a", "a", "b", "b"}
+
+	toneIndex := midi - midiNumberOfBaseNoteIndexZero
+	pitchClass := floorMod(toneIndex, 12)
+	name := sharpNames[pitchClass]
+	if preferFlats {
+		name = flatNames[pitchClass]
+	}
+
+	baseNoteIndex := floorDiv(toneIndex, 12)*12 + letterOffsets[name]
+	return noteWithClefs(baseNoteIndex, name, NoteModifier(toneIndex-baseNoteIndex))
+}
+
+// ParseNote parses a note in scientific ("C#4", "Bb2", "E♭5") or Helmholtz ("c'", "C,", "fis'") pitch notation.
+func ParseNote(s string) (Note, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Note{}, fmt.Errorf("empty note name")
+	}
+
+	letter := strings.ToLower(s[:1])
+	if _, ok := letterOffsets[letter]; !ok {
+		return Note{}, fmt.Errorf("invalid note name: %s", s)
+	}
+	upperCase := s[0] >= 'A' && s[0] <= 'Z'
+
+	rest := s[1:]
+	modifier := NoteModifierNone
+	for _, accidental := range []struct {
+		prefix   string
+		modifier NoteModifier
+	}{
+		{"#", NoteModifierSharp},
+		{"♯", NoteModifierSharp},
+		{"is", NoteModifierSharp},
+		{"b", NoteModifierFlat},
+		{"♭", NoteModifierFlat},
+		{"es", NoteModifierFlat},
+		{"s", NoteModifierFlat},
+	} {
+		if strings.HasPrefix(rest, accidental.prefix) {
+			modifier = accidental.modifier
+			rest = rest[len(accidental.prefix):]
+			break
+		}
+	}
+
+	var octave int
+	switch {
+	case rest == "":
+		octave = 3
+		if upperCase {
+			octave = 2
+		}
+	case strings.Trim(rest, "'") == "":
+		octave = 3 + len(rest)
+		if upperCase {
+			return Note{}, fmt.Errorf("invalid Helmholtz note name: %s", s)
+		}
+	case strings.Trim(rest, ",") == "":
+		octave = 2 - len(rest)
+		if !upperCase {
+			octave = 3 - len(rest)
+		}
+	default:
+		var err error
+		octave, err = strconv.Atoi(rest)
+		if err != nil {
+			return Note{}, fmt.Errorf("invalid octave in note name: %s", s)
+		}
+	}
+
+	return noteWithClefs((octave-octaveOfBaseNoteIndexZero)*12+letterOffsets[letter], letter, modifier), nil
+}
+
+// NewNote returns a note with the given base name ("c" to "b") and octave in scientific pitch notation.
+func NewNote(baseName string, modifier NoteModifier, octave int) Note {
+	return noteWithClefs((octave-octaveOfBaseNoteIndexZero)*12+letterOffsets[baseName], baseName, modifier)
+}
+
+// OnNearestClef returns the note placed only on the clef closer to it, notes from middle C up go to the treble clef.
+func (n Note) OnNearestClef() Note {
+	n.TrebleClef = n.BaseNoteIndex >= MiddleCIndex
+	n.BassClef = !n.TrebleClef
+	return n
+}
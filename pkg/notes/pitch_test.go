@@ -0,0 +1,83 @@
+package notes
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMIDIAndNames(t *testing.T) {
+	middleC := note(MiddleCIndex, "c", true, true)
+	assert.Equal(t, 60, middleC.MIDI())
+	assert.Equal(t, "C4", middleC.ScientificName())
+	assert.Equal(t, "c'", middleC.HelmholtzName())
+	assert.Equal(t, "c'", middleC.LilypondSymbol())
+
+	lowG := AllNotes[0]
+	assert.Equal(t, 31, lowG.MIDI())
+	assert.Equal(t, "G1", lowG.ScientificName())
+	assert.Equal(t, "G,", lowG.HelmholtzName())
+	assert.Equal(t, "g,,", lowG.LilypondSymbol())
+
+	bSharp := note(11, "b", false, true)
+	bSharp.Modifier = NoteModifierSharp
+	assert.Equal(t, 48, bSharp.MIDI())
+	assert.Equal(t, "B♯2", bSharp.ScientificName())
+}
+
+func TestNoteFromMIDI(t *testing.T) {
+	assert.Equal(t, "C♯4", NoteFromMIDI(61, false).ScientificName())
+	assert.Equal(t, "D♭4", NoteFromMIDI(61, true).ScientificName())
+	assert.Equal(t, "B1", NoteFromMIDI(35, true).ScientificName())
+	for midi := 20; midi < 100; midi++ {
+		assert.Equal(t, midi, NoteFromMIDI(midi, false).MIDI())
+		assert.Equal(t, midi, NoteFromMIDI(midi, true).MIDI())
+	}
+}
+
+func TestFrequency(t *testing.T) {
+	a4, err := ParseNote("A4")
+	assert.NoError(t, err)
+	assert.InDelta(t, 440, a4.Frequency(DefaultTuning), 0.001)
+	assert.InDelta(t, 442, a4.Frequency(Tuning{A4: 442}), 0.001)
+
+	c4, _ := ParseNote("C4")
+	assert.InDelta(t, 261.626, c4.Frequency(DefaultTuning), 0.001)
+
+	e4, _ := ParseNote("E4")
+	just := Tuning{A4: 440, System: TuningSystemJust, Tonic: 0}
+	assert.InDelta(t, c4.Frequency(just)*5/4, e4.Frequency(just), 0.001)
+
+	g3, _ := ParseNote("G3")
+	pythagorean := Tuning{A4: 440, System: TuningSystemPythagorean, Tonic: 0}
+	assert.InDelta(t, c4.Frequency(pythagorean)*3/4, g3.Frequency(pythagorean), 0.001)
+}
+
+func TestParseNote(t *testing.T) {
+	for input, expected := range map[string]string{
+		"C#4":  "C♯4",
+		"Bb2":  "B♭2",
+		"E♭5":  "E♭5",
+		"c''":  "C5",
+		"c":    "C3",
+		"C":    "C2",
+		"C,":   "C1",
+		"g,":   "G2",
+		"fis'": "F♯4",
+		"es":   "E♭3",
+		"b-1":  "B-1",
+	} {
+		n, err := ParseNote(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, n.ScientificName(), input)
+	}
+
+	for _, input := range []string{"", "h4", "C'", "c4x"} {
+		_, err := ParseNote(input)
+		assert.Error(t, err, input)
+	}
+
+	n, _ := ParseNote("fis'")
+	assert.Equal(t, "fis'", n.LilypondSymbol())
+	assert.True(t, n.TrebleClef)
+	assert.True(t, n.BassClef)
+}
@@ -25,11 +25,18 @@ func (s Scale) Degree(note string) ScaleDegree {
 	switch s.Mode {
 	case ScaleModeMajor:
 		return MajorScaleDegrees[degreeOfNoteInScale(note, s)]
+	case ScaleModeMinorHarmonic:
+		return MinorHarmonicScaleDegrees[degreeOfNoteInScale(note, s)]
 	default:
 		panic(fmt.Errorf("unsupported scale mode: %v", s.Mode))
 	}
 }
 
+// IsLeadingTone returns whether the note is the seventh degree of the scale.
+func (s Scale) IsLeadingTone(n Note) bool {
+	return degreeOfNoteInScale(n.BaseName, s) == 6
+}
+
 func degreeOfNoteInScale(note string, scale Scale) int {
 	notesInCScale := "cdefgabcdefgab"
 	idx := strings.Index(notesInCScale, scale.Note)
@@ -106,6 +113,60 @@ var MajorScaleDegrees = []ScaleDegree{
 	},
 }
 
+// MinorHarmonicScaleDegrees use the closest ChordType for the minor-major seventh on i
+// and the augmented major seventh on III+, as there are no separate types for them.
+var MinorHarmonicScaleDegrees = []ScaleDegree{
+	{
+		Quality:             ChordQualityMinor,
+		RomanNumeralTriad:   "i",
+		RomanNumeralSeventh: "i7",
+		TriadType:           ChordTypeMinorTriad,
+		SeventhType:         ChordTypeMinorSeventh,
+	},
+	{
+		Quality:             ChordQualityDiminished,
+		RomanNumeralTriad:   "ii°",
+		RomanNumeralSeventh: "ii⦰7",
+		TriadType:           ChordTypeDiminishedTriad,
+		SeventhType:         ChordTypeHalfDiminishedSeventh,
+	},
+	{
+		Quality:             ChordQualityMajor,
+		RomanNumeralTriad:   "III+",
+		RomanNumeralSeventh: "III+7",
+		TriadType:           ChordTypeAugmentedTriad,
+		SeventhType:         ChordTypeMajorSeventh,
+	},
+	{
+		Quality:             ChordQualityMinor,
+		RomanNumeralTriad:   "iv",
+		RomanNumeralSeventh: "iv7",
+		TriadType:           ChordTypeMinorTriad,
+		SeventhType:         ChordTypeMinorSeventh,
+	},
+	{
+		Quality:             ChordQualityMajor,
+		RomanNumeralTriad:   "V",
+		RomanNumeralSeventh: "V7",
+		TriadType:           ChordTypeMajorTriad,
+		SeventhType:         ChordTypeDominantSeventh,
+	},
+	{
+		Quality:             ChordQualityMajor,
+		RomanNumeralTriad:   "VI",
+		RomanNumeralSeventh: "VI7",
+		TriadType:           ChordTypeMajorTriad,
+		SeventhType:         ChordTypeMajorSeventh,
+	},
+	{
+		Quality:             ChordQualityDiminished,
+		RomanNumeralTriad:   "vii°",
+		RomanNumeralSeventh: "vii°7",
+		TriadType:           ChordTypeDiminishedTriad,
+		SeventhType:         ChordTypeDiminishedSeventh,
+	},
+}
+
 var (
 	CMajorScale = Scale{
 		Note:           "c",
@@ -356,3 +417,96 @@ func ApplyScale(notes []Note, scale Scale) []Note {
 
 	return notesInScale
 }
+
+// KeySignature returns the number of sharps (positive) or flats (negative) in the key signature.
+// The raised leading tone of harmonic minor scales isn't part of the signature.
+func (s Scale) KeySignature() int {
+	accidentals := len(s.NotesModified)
+	if s.Mode == ScaleModeMinorHarmonic {
+		accidentals--
+	}
+
+	return accidentals * int(s.Modifier)
+}
+
+var sharpsOrder = "fcgdaeb"
+
+// KeySignatureModifier returns the modifier the key signature with the given number of sharps
+// (positive) or flats (negative) applies to notes with the given base name.
+func KeySignatureModifier(keySignature int, baseName string) NoteModifier {
+	idx := strings.Index(sharpsOrder, baseName)
+	if idx == -1 {
+		return NoteModifierNone
+	}
+
+	if keySignature > 0 && idx < keySignature {
+		return NoteModifierSharp
+	}
+
+	if keySignature < 0 && len(sharpsOrder)-1-idx < -keySignature {
+		return NoteModifierFlat
+	}
+
+	return NoteModifierNone
+}
+
+var majorKeyTonics = []string{"c flat", "g flat", "d flat", "a flat", "e flat", "b flat", "f", "c", "g", "d", "a", "e", "b", "f sharp", "c sharp"}
+var minorKeyTonics = []string{"a flat", "e flat", "b flat", "f", "c", "g", "d", "a", "e", "b", "f sharp", "c sharp", "g sharp", "d sharp", "a sharp"}
+
+// ScaleForKeySignature returns the scale with the given key signature from ScaleMap. Keys missing there
+// (e.g. flat minor keys) are built from the key signature; the leading tone of such minor scales is listed
+// in NotesModified only to keep KeySignature consistent, so they must not be used with ApplyScale.
+func ScaleForKeySignature(keySignature int, minor bool) (Scale, error) {
+	if keySignature < -7 || keySignature > 7 {
+		return Scale{}, fmt.Errorf("invalid key signature: %d", keySignature)
+	}
+
+	mode := ScaleModeMajor
+	if minor {
+		mode = ScaleModeMinorHarmonic
+	}
+
+	for _, scale := range ScaleMap {
+		if scale.Mode == mode && scale.KeySignature() == keySignature {
+			return scale, nil
+		}
+	}
+
+	tonic := majorKeyTonics[keySignature+7]
+	if minor {
+		tonic = minorKeyTonics[keySignature+7]
+	}
+
+	scale := Scale{Note: tonic[:1], Mode: mode, Modifier: NoteModifierSharp}
+	if keySignature < 0 {
+		scale.Modifier = NoteModifierFlat
+	}
+
+	for i := 0; i < keySignature; i++ {
+		scale.NotesModified = append(scale.NotesModified, sharpsOrder[i:i+1])
+	}
+	for i := 0; i < -keySignature; i++ {
+		scale.NotesModified = append(scale.NotesModified, sharpsOrder[len(sharpsOrder)-1-i:len(sharpsOrder)-i])
+	}
+
+	lilypondTonic := noteNameWithModifier(tonic[:1], NoteModifierNone)
+	if strings.HasSuffix(tonic, "sharp") {
+		lilypondTonic = noteNameWithModifier(tonic[:1], NoteModifierSharp)
+	} else if strings.HasSuffix(tonic, "flat") {
+		lilypondTonic = noteNameWithModifier(tonic[:1], NoteModifierFlat)
+	}
+
+	if minor {
+		scale.Name = tonic + " minor"
+		scale.LilypondSymbol = lilypondTonic + ` \minor`
+		notesInCScale := "cdefgabcdefgab"
+		leadingToneIdx := strings.Index(notesInCScale, scale.Note) + 6
+		leadingTone := notesInCScale[leadingToneIdx : leadingToneIdx+1]
+		scale.NotesModified = append(scale.NotesModified, leadingTone)
+	} else {
+		scale.Name = tonic + " major"
+		scale.LilypondSymbol = lilypondTonic + ` \major`
+	}
+
+	return scale, nil
+}
@@ -26,3 +26,39 @@ func TestDegreeOfNoteInScale(t *testing.T) {
 	assert.Equal(t, 6, degreeOfNoteInScale("g", AMajorScale))
 
 }
+
+func TestKeySignature(t *testing.T) {
+	assert.Equal(t, 0, CMajorScale.KeySignature())
+	assert.Equal(t, 0, AMinorScale.KeySignature())
+	assert.Equal(t, 4, CSharpMinorScale.KeySignature())
+	assert.Equal(t, 6, FSharpMajor.KeySignature())
+	assert.Equal(t, -3, EFlatMajorScale.KeySignature())
+}
+
+func TestKeySignatureModifier(t *testing.T) {
+	assert.Equal(t, NoteModifierSharp, KeySignatureModifier(DMajorScale.KeySignature(), "c"))
+	assert.Equal(t, NoteModifierNone, KeySignatureModifier(DMajorScale.KeySignature(), "g"))
+	assert.Equal(t, NoteModifierFlat, KeySignatureModifier(EFlatMajorScale.KeySignature(), "a"))
+	assert.Equal(t, NoteModifierNone, KeySignatureModifier(EFlatMajorScale.KeySignature(), "d"))
+	assert.Equal(t, NoteModifierNone, KeySignatureModifier(AMinorScale.KeySignature(), "g"))
+}
+
+func TestScaleForKeySignature(t *testing.T) {
+	scale, err := ScaleForKeySignature(2, false)
+	assert.NoError(t, err)
+	assert.Equal(t, DMajorScale.Name, scale.Name)
+
+	scale, err = ScaleForKeySignature(-1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "d minor", scale.Name)
+	assert.Equal(t, `d \minor`, scale.LilypondSymbol)
+	assert.Equal(t, -1, scale.KeySignature())
+
+	scale, err = ScaleForKeySignature(-3, true)
+	assert.NoError(t, err)
+	assert.Equal(t, `c \minor`, scale.LilypondSymbol)
+	assert.Equal(t, -3, scale.KeySignature())
+
+	_, err = ScaleForKeySignature(8, false)
+	assert.Error(t, err)
+}
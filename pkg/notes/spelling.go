@@ -0,0 +1,148 @@
+package notes
+
+import (
+	"strings"
+)
+
+// maxRespelledPitchClasses limits the search for chord spellings, every pitch class doubles the number of tries.
+const maxRespelledPitchClasses = 6
+
+// ModifierOf returns the modifier of the note with the given base name in the scale.
+// In harmonic minor scales the leading tone is raised from the key signature, also in flat keys.
+func (s Scale) ModifierOf(baseName string) NoteModifier {
+	modifier := KeySignatureModifier(s.KeySignature(), baseName)
+	if s.Mode == ScaleModeMinorHarmonic && degreeOfNoteInScale(baseName, s) == 6 {
+		modifier++
+	}
+
+	return modifier
+}
+
+// SpellMIDI spells a MIDI note number in the scale. Scale notes keep their spelling, chromatic notes
+// are spelled as naturals when possible, otherwise with sharps in sharp keys and flats in flat keys.
+func SpellMIDI(midi int, scale Scale) Note {
+	return spellingCandidates(midi, scale)[0]
+}
+
+// spellingCandidates returns all spellings of a MIDI note number with at most one accidental, the preferred one first.
+func spellingCandidates(midi int, scale Scale) []Note {
+	toneIndex := midi - midiNumberOfBaseNoteIndexZero
+	var inScale, natural, preferred, other []Note
+	for _, name := range "cdefgab" {
+		baseName := string(name)
+		for _, modifier := range []NoteModifier{NoteModifierNone, NoteModifierSharp, NoteModifierFlat} {
+			baseNoteIndex := toneIndex - int(modifier)
+			if floorMod(baseNoteIndex, 12) != letterOffsets[baseName] {
+				continue
+			}
+
+			n := noteWithClefs(baseNoteIndex, baseName, modifier)
+			switch {
+			case modifier == scale.ModifierOf(baseName):
+				inScale = append(inScale, n)
+			case modifier == NoteModifierNone:
+				natural = append(natural, n)
+			case modifier == chromaticModifier(scale, baseName):
+				preferred = append(preferred, n)
+			default:
+				other = append(other, n)
+			}
+		}
+	}
+
+	var candidates []Note
+	for _, group := range [][]Note{inScale, natural, preferred, other} {
+		candidates = append(candidates, group...)
+	}
+
+	return candidates
+}
+
+// chromaticModifier is the accidental used for chromatic notes in the scale. Keys without
+// accidentals use the common spellings of C major: C♯, E♭, F♯, G♯ and B♭.
+func chromaticModifier(scale Scale, baseName string) NoteModifier {
+	switch keySignature := scale.KeySignature(); {
+	case keySignature > 0:
+		return NoteModifierSharp
+	case keySignature < 0:
+		return NoteModifierFlat
+	case strings.Contains("cfg", baseName):
+		return NoteModifierSharp
+	default:
+		return NoteModifierFlat
+	}
+}
+
+// SpellChord spells MIDI note numbers as a chord in the scale. Spellings stacked in thirds win
+// over the preferred spelling of single notes, so e.g. the dominant of the dominant keeps its raised fourth degree.
+func SpellChord(midis []int, scale Scale) (Chord, bool) {
+	var pitchClasses []int
+	candidates := map[int][]Note{}
+	for _, midi := range midis {
+		pitchClass := floorMod(midi, 12)
+		if _, ok := candidates[pitchClass]; !ok {
+			pitchClasses = append(pitchClasses, pitchClass)
+			candidates[pitchClass] = spellingCandidates(pitchClass+midiNumberOfBaseNoteIndexZero, scale)
+		}
+	}
+
+	spell := func(choice map[int]Note) []Note {
+		var spelled []Note
+		for _, midi := range midis {
+			n := choice[floorMod(midi, 12)]
+			baseNoteIndex := midi - midiNumberOfBaseNoteIndexZero - int(n.Modifier)
+			spelled = append(spelled, noteWithClefs(baseNoteIndex, n.BaseName, n.Modifier))
+		}
+		return spelled
+	}
+
+	preferred := map[int]Note{}
+	for _, pitchClass := range pitchClasses {
+		preferred[pitchClass] = candidates[pitchClass][0]
+	}
+
+	chord, ok := AnalyzeChord(spell(preferred), scale)
+	if !ok || stackedInThirds(chord) || len(pitchClasses) > maxRespelledPitchClasses {
+		return chord, ok
+	}
+
+	// alternatives are tried in order of the number of respelled notes, so the preferred spelling of most notes is kept
+	for respelled := 1; respelled <= len(pitchClasses); respelled++ {
+		for mask := 0; mask < 1<<uint(len(pitchClasses)); mask++ {
+			if bitCount(mask) != respelled {
+				continue
+			}
+
+			choice := map[int]Note{}
+			valid := true
+			for i, pitchClass := range pitchClasses {
+				choice[pitchClass] = candidates[pitchClass][0]
+				if mask&(1<<uint(i)) != 0 {
+					if len(candidates[pitchClass]) < 2 {
+						valid = false
+						break
+					}
+					choice[pitchClass] = candidates[pitchClass][1]
+				}
+			}
+			if !valid {
+				continue
+			}
+
+			if respelledChord, ok := AnalyzeChord(spell(choice), scale); ok && stackedInThirds(respelledChord) {
+				return respelledChord, true
+			}
+		}
+	}
+
+	return chord, ok
+}
+
+func bitCount(mask int) int {
+	count := 0
+	for ; mask > 0; mask >>= 1 {
+		count += mask & 1
+	}
+
+	return count
+}
@@ -0,0 +1,44 @@
+package notes
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSpellMIDI(t *testing.T) {
+	assert.Equal(t, "F♯4", SpellMIDI(66, GMajorScale).ScientificName())
+	assert.Equal(t, "G♭4", SpellMIDI(66, DFlatMajorScale).ScientificName())
+	assert.Equal(t, "F4", SpellMIDI(65, GMajorScale).ScientificName())
+	assert.Equal(t, "B♭3", SpellMIDI(58, CMajorScale).ScientificName())
+	assert.Equal(t, "G♯4", SpellMIDI(68, AMinorScale).ScientificName())
+
+	dMinor, err := ScaleForKeySignature(-1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, NoteModifierSharp, dMinor.ModifierOf("c"))
+	assert.Equal(t, "C♯5", SpellMIDI(73, dMinor).ScientificName())
+}
+
+func TestSpellChord(t *testing.T) {
+	chord, ok := SpellChord([]int{50, 57, 60, 66}, CMajorScale)
+	assert.True(t, ok)
+	assert.Equal(t, "D maj7", chord.Name())
+	assert.Equal(t, "II7", chord.RomanNumeral())
+	assert.Equal(t, NoteModifierSharp, chord.Notes[0].Modifier)
+
+	// A♭ C E♭ in c major is spelled with flats although it has no accidentals in the key
+	chord, ok = SpellChord([]int{56, 60, 63}, CMajorScale)
+	assert.True(t, ok)
+	assert.Equal(t, "A♭ maj", chord.Name())
+	assert.Equal(t, "♭VI", chord.RomanNumeral())
+
+	chord, ok = SpellChord([]int{52, 56, 59, 62}, AMinorScale)
+	assert.True(t, ok)
+	assert.Equal(t, "V7", chord.RomanNumeral())
+
+	chord, ok = SpellChord([]int{56, 59, 62, 65}, AMinorScale)
+	assert.True(t, ok)
+	assert.Equal(t, "vii°7", chord.RomanNumeral())
+
+	_, ok = SpellChord([]int{60, 62, 64}, CMajorScale)
+	assert.False(t, ok)
+}
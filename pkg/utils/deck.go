@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"flag"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/anki"
+	"strings"
+	"unicode/utf8"
+)
+
+type DeckFlags struct {
+	delimiter  *string
+	header     *bool
+	columns    *string
+	directions *string
+}
+
+// RegisterDeckFlags registers the flags of the deck file shared by all generators.
+func RegisterDeckFlags(fs *flag.FlagSet) *DeckFlags {
+	var columns []string
+	for _, column := range anki.DefaultDeckFile.Columns {
+		columns = append(columns, string(column))
+	}
+
+	return &DeckFlags{
+		delimiter: fs.String("deckDelimiter", string(anki.DefaultDeckFile.Delimiter), `delimiter of the deck file columns, "tab" for a tab separated file`),
+		header:    fs.Bool("deckHeader", false, "write the column names in the first line of the deck file instead of the Anki import options"),
+		columns: fs.String("deckColumns", strings.Join(columns, ","),
+			`comma separated columns of the deck file: "front", "back", "guid", "name", "tags", "scale", "roman", "chordType", "intervalSize", "audio", "sortKey", "direction"`),
+		directions: fs.String("directions", string(anki.DirectionNotation),
+			`comma separated card directions of every item: "notation" asks for the name, "name" asks to draw the notation, "roman", "quality", "root" and "size" ask only for that attribute`),
+	}
+}
+
+func (f *DeckFlags) DeckFile() (anki.DeckFile, error) {
+	delimiter := *f.delimiter
+	if strings.EqualFold(delimiter, "tab") || delimiter == `\t` {
+		delimiter = "\t"
+	}
+	if utf8.RuneCountInString(delimiter) != 1 {
+		return anki.DeckFile{}, fmt.Errorf("invalid deck delimiter: %q, expected a single character", *f.delimiter)
+	}
+
+	columns, err := anki.ParseColumns(*f.columns)
+	if err != nil {
+		return anki.DeckFile{}, err
+	}
+
+	deckFile := anki.DeckFile{Delimiter: []rune(delimiter)[0], Header: *f.header, Columns: columns}
+	if err := deckFile.Validate(); err != nil {
+		return anki.DeckFile{}, err
+	}
+
+	return deckFile, nil
+}
+
+// Directions returns the card directions, the ones in unsupported are rejected.
+func (f *DeckFlags) Directions(unsupported ...anki.Direction) ([]anki.Direction, error) {
+	directions, err := anki.ParseDirections(*f.directions)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, direction := range directions {
+		for _, u := range unsupported {
+			if direction == u {
+				return nil, fmt.Errorf("card direction %s is not supported here", direction)
+			}
+		}
+	}
+
+	return directions, nil
+}
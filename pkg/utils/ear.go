@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"flag"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/midi"
+	"github.com/lsierant/notes-gen/pkg/notes"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+)
+
+type ReferencePitch int
+
+const (
+	ReferencePitchNotated ReferencePitch = iota
+	ReferencePitchFixed
+	ReferencePitchRandom
+)
+
+var referencePitchNames = map[string]ReferencePitch{
+	"notated": ReferencePitchNotated,
+	"fixed":   ReferencePitchFixed,
+	"random":  ReferencePitchRandom,
+}
+
+var earPlaybackStyles = map[string]string{
+	"harmonic":   "block",
+	"ascending":  "up",
+	"descending": "down",
+}
+
+// lowest and highest MIDI note of the lowest note of a sonority transposed to a random reference pitch
+const (
+	randomReferenceLow  = 48
+	randomReferenceHigh = 67
+)
+
+// EarTraining configures decks where the question is audio and the answer is the notation.
+type EarTraining struct {
+	Reference     ReferencePitch
+	ReferenceNote notes.Note
+	Seed          int64
+	PerItem       int
+}
+
+// Transpose moves the sonority so its lowest note is the reference pitch. The random reference pitch
+// depends only on the seed and the key, so the same card gets the same audio between runs.
+func (e EarTraining) Transpose(key string, sonority midi.Sonority) midi.Sonority {
+	if e.Reference == ReferencePitchNotated || len(sonority.Notes) == 0 {
+		return sonority
+	}
+
+	lowest := sonority.Notes[0].MIDI()
+	for _, n := range sonority.Notes {
+		if n.MIDI() < lowest {
+			lowest = n.MIDI()
+		}
+	}
+
+	target := e.ReferenceNote.MIDI()
+	if e.Reference == ReferencePitchRandom {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key))
+		r := rand.New(rand.NewSource(e.Seed ^ int64(h.Sum64())))
+		target = randomReferenceLow + r.Intn(randomReferenceHigh-randomReferenceLow+1)
+	}
+
+	transposed := midi.Sonority{}
+	for _, n := range sonority.Notes {
+		transposed.Notes = append(transposed.Notes, notes.NoteFromMIDI(n.MIDI()+target-lowest, n.Modifier == notes.NoteModifierFlat))
+	}
+
+	return transposed
+}
+
+// Deduplicate returns indices of the first PerItem items for every key, so transpositions
+// of the same interval or chord don't dominate the deck.
+func (e EarTraining) Deduplicate(n int, key func(idx int) string) []int {
+	perItem := e.PerItem
+	if perItem <= 0 {
+		perItem = 1
+	}
+
+	counts := map[string]int{}
+	var indices []int
+	for i := 0; i < n; i++ {
+		k := key(i)
+		if counts[k] < perItem {
+			counts[k]++
+			indices = append(indices, i)
+		}
+	}
+
+	return indices
+}
+
+type EarTrainingFlags struct {
+	enabled       *bool
+	playback      *string
+	reference     *string
+	referenceNote *string
+	seed          *int64
+	perItem       *int
+}
+
+func RegisterEarTrainingFlags(fs *flag.FlagSet) *EarTrainingFlags {
+	return &EarTrainingFlags{
+		enabled:       fs.Bool("earTraining", false, "generate ear training cards with audio on the front and notation on the back"),
+		playback:      fs.String("earPlayback", "harmonic", `ear training playback: "harmonic", "ascending" or "descending"`),
+		reference:     fs.String("earReference", "notated", `pitch of the lowest note in ear training audio: "notated", "fixed" or "random"`),
+		referenceNote: fs.String("earReferenceNote", "C4", "lowest note of ear training audio for the fixed reference pitch"),
+		seed:          fs.Int64("earSeed", 1, "seed for random reference pitches"),
+		perItem:       fs.Int("earPerItem", 1, "number of transpositions of the same interval or chord in ear training decks"),
+	}
+}
+
+// Options returns nil when ear training is disabled. Otherwise it enables audio output in soundFlags
+// using the ear training playback style.
+func (f *EarTrainingFlags) Options(soundFlags *SoundFlags) (*EarTraining, error) {
+	if !*f.enabled {
+		return nil, nil
+	}
+
+	style, ok := earPlaybackStyles[strings.ToLower(*f.playback)]
+	if !ok {
+		return nil, fmt.Errorf("invalid ear training playback: %s, expected one of: harmonic, ascending, descending", *f.playback)
+	}
+
+	reference, ok := referencePitchNames[strings.ToLower(*f.reference)]
+	if !ok {
+		return nil, fmt.Errorf("invalid ear training reference pitch: %s, expected one of: notated, fixed, random", *f.reference)
+	}
+
+	referenceNote, err := notes.ParseNote(*f.referenceNote)
+	if err != nil {
+		return nil, err
+	}
+
+	*soundFlags.writeAudio = true
+	*soundFlags.audioStyle = style
+
+	return &EarTraining{
+		Reference:     reference,
+		ReferenceNote: referenceNote,
+		Seed:          *f.seed,
+		PerItem:       *f.perItem,
+	}, nil
+}
+
+// EarTrainingGUID returns the note GUID of the ear training card of an item, so it doesn't replace the notation card
+// with the item id on import.
+func EarTrainingGUID(id string) string {
+	return id + "-ear"
+}
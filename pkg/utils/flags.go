@@ -2,7 +2,11 @@ package utils
 
 import (
 	"fmt"
+	"github.com/lsierant/notes-gen/pkg/midi"
 	"github.com/lsierant/notes-gen/pkg/notes"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
 func FilterScales(scaleFlag string, accidentals int) ([]notes.Scale, error) {
@@ -27,3 +31,98 @@ func FilterScales(scaleFlag string, accidentals int) ([]notes.Scale, error) {
 
 	return scales, nil
 }
+
+func SplitList(flagValue string) []string {
+	var result []string
+	for _, item := range strings.Split(flagValue, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+func IntervalFilterFromFlags(sizes string, qualities string, clefs string, minDistance int, maxDistance int, limit int) (notes.IntervalFilter, error) {
+	filter := notes.IntervalFilter{
+		Sizes:       SplitList(sizes),
+		Qualities:   SplitList(qualities),
+		MinDistance: minDistance,
+		MaxDistance: maxDistance,
+		Limit:       limit,
+	}
+
+	for _, clef := range SplitList(clefs) {
+		layout, err := notes.ParseIntervalClefLayout(clef)
+		if err != nil {
+			return notes.IntervalFilter{}, err
+		}
+		filter.Layouts = append(filter.Layouts, layout)
+	}
+
+	if maxDistance > 0 && minDistance > maxDistance {
+		return notes.IntervalFilter{}, fmt.Errorf("invalid distance range: %d-%d", minDistance, maxDistance)
+	}
+
+	return filter, nil
+}
+
+func MidiOptionsFromFlags(tempo int, velocity int, program int, style string) (midi.Options, error) {
+	playbackStyle, err := midi.ParsePlaybackStyle(style)
+	if err != nil {
+		return midi.Options{}, err
+	}
+
+	if tempo <= 0 {
+		return midi.Options{}, fmt.Errorf("invalid midi tempo: %d", tempo)
+	}
+
+	if velocity < 1 || velocity > 127 {
+		return midi.Options{}, fmt.Errorf("invalid midi velocity: %d, expected 1-127", velocity)
+	}
+
+	if program < 0 || program > 127 {
+		return midi.Options{}, fmt.Errorf("invalid midi program: %d, expected 0-127", program)
+	}
+
+	opts := midi.DefaultOptions
+	opts.Tempo = tempo
+	opts.Velocity = velocity
+	opts.Program = program
+	opts.Style = playbackStyle
+
+	return opts, nil
+}
+
+func ReplaceExtension(path string, extension string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + extension
+}
+
+// ExistingPath returns the path when the file exists and an empty string otherwise.
+func ExistingPath(path string) string {
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+type MusicXMLOutput int
+
+const (
+	MusicXMLOutputNone MusicXMLOutput = iota
+	MusicXMLOutputAlso
+	MusicXMLOutputOnly
+)
+
+func ParseMusicXMLOutput(flagValue string) (MusicXMLOutput, error) {
+	switch strings.ToLower(flagValue) {
+	case "", "none":
+		return MusicXMLOutputNone, nil
+	case "also":
+		return MusicXMLOutputAlso, nil
+	case "only":
+		return MusicXMLOutputOnly, nil
+	default:
+		return MusicXMLOutputNone, fmt.Errorf("invalid musicxml output: %s, expected one of: none, also, only", flagValue)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -69,3 +70,52 @@ func RunInParallel(ctx context.Context, n int, parallel int, f func(idx int) err
 
 	return nil
 }
+
+// RunInBatches splits n items into batches of up to batchSize and runs f for the batches in parallel.
+// f returns an error for every item of the batch, nil when the item succeeded. Failed items don't stop
+// other batches, all of them are reported in the returned error.
+func RunInBatches(ctx context.Context, n int, batchSize int, parallel int, f func(indices []int) []error) error {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	var batches [][]int
+	for start := 0; start < n; start += batchSize {
+		var batch []int
+		for i := start; i < n && i < start+batchSize; i++ {
+			batch = append(batch, i)
+		}
+		batches = append(batches, batch)
+	}
+
+	mutex := sync.Mutex{}
+	var failedItems []int
+	itemErrors := map[int]error{}
+	err := RunInParallel(ctx, len(batches), parallel, func(idx int) error {
+		errs := f(batches[idx])
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		for i, err := range errs {
+			if err != nil {
+				failedItems = append(failedItems, batches[idx][i])
+				itemErrors[batches[idx][i]] = err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(failedItems) > 0 {
+		sort.Ints(failedItems)
+		var errors []string
+		for _, item := range failedItems {
+			errors = append(errors, fmt.Sprintf("item %d: %v", item, itemErrors[item]))
+		}
+		return fmt.Errorf("%d of %d items failed:\n%s", len(failedItems), n, strings.Join(errors, "\n"))
+	}
+
+	return nil
+}
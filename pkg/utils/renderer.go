@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"flag"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/cache"
+	"github.com/lsierant/notes-gen/pkg/lilypond"
+	"strings"
+)
+
+const (
+	DefaultCacheDir     = ".render-cache"
+	DefaultCacheMaxSize = 1024
+	megabyte            = 1024 * 1024
+)
+
+type RendererFlags struct {
+	renderer     *string
+	binary       *string
+	dockerImage  *string
+	dockerTag    *string
+	rendererArgs *string
+	batchSize    *int
+	cacheDir     *string
+	cacheMaxSize *int64
+	formats      *string
+	dpi          *int
+	transparent  *bool
+	debugDir     *string
+}
+
+// RegisterRendererFlags registers the flags selecting how lilypond sources are rendered to images.
+func RegisterRendererFlags(fs *flag.FlagSet) *RendererFlags {
+	return &RendererFlags{
+		renderer:     fs.String("renderer", "docker", `image renderer: "docker", "local" to run a lilypond binary, "native" to use the built-in engraver, "fake" for placeholder images`),
+		binary:       fs.String("lilypondBinary", lilypond.DefaultBinary, "lilypond binary used by the local renderer"),
+		dockerImage:  fs.String("dockerImage", lilypond.DefaultDockerImage, "lilypond image used by the docker renderer"),
+		dockerTag:    fs.String("dockerTag", lilypond.DefaultDockerTag, "tag of the lilypond image used by the docker renderer"),
+		rendererArgs: fs.String("rendererArgs", "", "space separated extra arguments, passed to docker run by the docker renderer and to lilypond by the local renderer"),
+		batchSize:    fs.Int("batchSize", 16, "number of images compiled in a single lilypond run"),
+		cacheDir:     fs.String("cacheDir", DefaultCacheDir, "directory of the render cache, empty disables the cache"),
+		cacheMaxSize: fs.Int64("cacheMaxSize", DefaultCacheMaxSize, "maximum size of the render cache in MB, least recently used images are evicted, 0 means no limit"),
+		formats:      fs.String("formats", "png", `comma separated artifacts written next to each other for every image: "png", "svg", "pdf", "eps", "midi", decks link the png`),
+		dpi:          fs.Int("dpi", lilypond.DefaultRenderOptions.DPI, "resolution of png images"),
+		transparent:  fs.Bool("transparent", false, "render images without the white background"),
+		debugDir:     fs.String("debugDir", "render-errors", "directory keeping the sources that failed to render with an index.txt of their errors, empty disables it"),
+	}
+}
+
+// Renderer returns the selected renderer, wrapped in the render cache unless it's disabled.
+func (f *RendererFlags) Renderer(workingDir string) (lilypond.Renderer, error) {
+	var renderer lilypond.Renderer
+	extraArgs := strings.Fields(*f.rendererArgs)
+	switch strings.ToLower(*f.renderer) {
+	case "", "docker":
+		renderer = &lilypond.DockerRenderer{WorkingDir: workingDir, Image: *f.dockerImage, Tag: *f.dockerTag, ExtraArgs: extraArgs}
+	case "local":
+		renderer = &lilypond.LocalRenderer{WorkingDir: workingDir, Binary: *f.binary, ExtraArgs: extraArgs}
+	case "native":
+		renderer = &lilypond.NativeRenderer{}
+	case "fake":
+		renderer = &lilypond.FakeRenderer{}
+	default:
+		return nil, fmt.Errorf("invalid renderer: %s, expected one of: docker, local, native, fake", *f.renderer)
+	}
+
+	if *f.cacheDir == "" {
+		return renderer, nil
+	}
+
+	if *f.cacheMaxSize < 0 {
+		return nil, fmt.Errorf("invalid cache size: %d", *f.cacheMaxSize)
+	}
+
+	renderCache, err := cache.Open(*f.cacheDir, *f.cacheMaxSize*megabyte)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lilypond.CachedRenderer{Renderer: renderer, Cache: renderCache}, nil
+}
+
+func (f *RendererFlags) BatchSize() int {
+	return *f.batchSize
+}
+
+// RenderOptions returns the artifacts selected with -formats, -dpi and -transparent.
+func (f *RendererFlags) RenderOptions() (lilypond.RenderOptions, error) {
+	formats, err := lilypond.ParseFormats(*f.formats)
+	if err != nil {
+		return lilypond.RenderOptions{}, err
+	}
+
+	if *f.dpi <= 0 {
+		return lilypond.RenderOptions{}, fmt.Errorf("invalid dpi: %d", *f.dpi)
+	}
+
+	return lilypond.RenderOptions{Formats: formats, DPI: *f.dpi, Transparent: *f.transparent}, nil
+}
+
+// DebugDir returns the directory keeping failed sources, nil when it's disabled.
+func (f *RendererFlags) DebugDir() *lilypond.DebugDir {
+	if *f.debugDir == "" {
+		return nil
+	}
+	return &lilypond.DebugDir{Path: *f.debugDir}
+}
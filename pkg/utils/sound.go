@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"flag"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/audio"
+	"github.com/lsierant/notes-gen/pkg/midi"
+	"github.com/lsierant/notes-gen/pkg/notes"
+)
+
+// SoundOutputs writes the optional .mid and .wav files next to card images. Nil options disable the output.
+type SoundOutputs struct {
+	MidiOptions  *midi.Options
+	AudioOptions *audio.Options
+	Instrument   audio.Instrument
+}
+
+func (o SoundOutputs) Write(imagePath string, sonorities []midi.Sonority, scale notes.Scale) error {
+	if o.MidiOptions != nil {
+		if err := midi.WriteFile(ReplaceExtension(imagePath, ".mid"), sonorities, o.MidiOptions.ForScale(scale)); err != nil {
+			return err
+		}
+	}
+
+	if o.AudioOptions != nil {
+		samples := audio.RenderSonorities(o.Instrument, sonorities, *o.AudioOptions)
+		if err := audio.WriteWAVFile(ReplaceExtension(imagePath, ".wav"), samples, o.AudioOptions.SampleRate); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func AudioOptionsFromFlags(sampleRate int, style string) (audio.Options, error) {
+	playbackStyle, err := midi.ParsePlaybackStyle(style)
+	if err != nil {
+		return audio.Options{}, err
+	}
+
+	if sampleRate < 8000 || sampleRate > 192000 {
+		return audio.Options{}, fmt.Errorf("invalid sample rate: %d, expected 8000-192000", sampleRate)
+	}
+
+	opts := audio.DefaultOptions
+	opts.SampleRate = sampleRate
+	opts.Style = playbackStyle
+
+	return opts, nil
+}
+
+type SoundFlags struct {
+	writeMidi        *bool
+	midiTempo        *int
+	midiVelocity     *int
+	midiProgram      *int
+	midiStyle        *string
+	writeAudio       *bool
+	soundFont        *string
+	soundFontBank    *int
+	soundFontProgram *int
+	sampleRate       *int
+	audioStyle       *string
+}
+
+// RegisterSoundFlags registers the midi and audio flags shared by all generators.
+func RegisterSoundFlags(fs *flag.FlagSet) *SoundFlags {
+	return &SoundFlags{
+		writeMidi:        fs.Bool("midi", false, "write a .mid file next to each image"),
+		midiTempo:        fs.Int("midiTempo", midi.DefaultOptions.Tempo, "tempo of generated midi files in BPM"),
+		midiVelocity:     fs.Int("midiVelocity", midi.DefaultOptions.Velocity, "velocity of notes in generated midi files, 1-127"),
+		midiProgram:      fs.Int("midiProgram", midi.DefaultOptions.Program, "General MIDI program (instrument) of generated midi files, 0-127"),
+		midiStyle:        fs.String("midiStyle", "block", `playback style of generated midi files: "block", "up" or "down"`),
+		writeAudio:       fs.Bool("audio", false, "write a .wav file next to each image"),
+		soundFont:        fs.String("soundFont", "", "path to a SF2 soundfont used for audio, the built-in synth is used when empty"),
+		soundFontBank:    fs.Int("soundFontBank", 0, "soundfont preset bank"),
+		soundFontProgram: fs.Int("soundFontProgram", 0, "soundfont preset program"),
+		sampleRate:       fs.Int("sampleRate", audio.DefaultOptions.SampleRate, "sample rate of generated audio files"),
+		audioStyle:       fs.String("audioStyle", "block", `playback style of generated audio files: "block", "up" or "down"`),
+	}
+}
+
+func (f *SoundFlags) Outputs() (SoundOutputs, error) {
+	outputs := SoundOutputs{}
+	if *f.writeMidi {
+		opts, err := MidiOptionsFromFlags(*f.midiTempo, *f.midiVelocity, *f.midiProgram, *f.midiStyle)
+		if err != nil {
+			return SoundOutputs{}, err
+		}
+		outputs.MidiOptions = &opts
+	}
+
+	if *f.writeAudio {
+		opts, err := AudioOptionsFromFlags(*f.sampleRate, *f.audioStyle)
+		if err != nil {
+			return SoundOutputs{}, err
+		}
+		instrument, err := audio.LoadInstrument(*f.soundFont, *f.soundFontBank, *f.soundFontProgram)
+		if err != nil {
+			return SoundOutputs{}, err
+		}
+		outputs.AudioOptions = &opts
+		outputs.Instrument = instrument
+	}
+
+	return outputs, nil
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"flag"
+	"fmt"
+	"github.com/lsierant/notes-gen/pkg/lilypond"
+	"io/ioutil"
+)
+
+type TemplateFlags struct {
+	templates       *string
+	lilypondVersion *string
+	staffSize       *float64
+	paperWidth      *string
+	font            *string
+	preamble        *string
+}
+
+// RegisterTemplateFlags registers the flags customizing the generated lilypond sources.
+func RegisterTemplateFlags(fs *flag.FlagSet) *TemplateFlags {
+	return &TemplateFlags{
+		templates:       fs.String("templates", "", `comma separated template files overriding the named blocks "version", "preamble", "paper", "music", the whole "document" or the "worksheet" document`),
+		lilypondVersion: fs.String("lilypondVersion", lilypond.DefaultLayout.LilypondVersion, `lilypond version written to the \version header`),
+		staffSize:       fs.Float64("staffSize", 0, "staff size in points, 0 keeps the lilypond default of 20"),
+		paperWidth:      fs.String("paperWidth", lilypond.DefaultLayout.PaperWidth, "line width of the paper"),
+		font:            fs.String("font", "", "font family of texts, empty keeps the lilypond default"),
+		preamble:        fs.String("preamble", "", "file with lilypond code inserted before the paper block"),
+	}
+}
+
+// Templates parses and validates the templates selected by the flags.
+func (f *TemplateFlags) Templates() (*lilypond.Templates, error) {
+	layout := lilypond.Layout{
+		LilypondVersion: *f.lilypondVersion,
+		StaffSize:       *f.staffSize,
+		PaperWidth:      *f.paperWidth,
+		Font:            *f.font,
+	}
+
+	if *f.preamble != "" {
+		preamble, err := ioutil.ReadFile(*f.preamble)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read preamble: %v", err)
+		}
+		layout.Preamble = string(preamble)
+	}
+
+	return lilypond.NewTemplates(layout, SplitList(*f.templates)...)
+}